@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-syar/avro-schema-bq/table"
+)
+
+// runApply implements the "apply" subcommand, which creates every table
+// listed in a manifest file. It returns the process exit code.
+func runApply(args []string) int {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	manifestPath := fs.String("manifest", "", "path to the YAML or JSON table manifest")
+	serviceAccount := fs.String("credentials", "", "service account credentials file (default: application default credentials)")
+	concurrency := fs.Int("concurrency", table.DefaultConcurrency, "maximum number of tables created in parallel")
+	continueOnError := fs.Bool("continue-on-error", false, "keep applying the remaining tables after a failure")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *manifestPath == "" {
+		fmt.Fprintln(os.Stderr, "apply: -manifest is required")
+		fs.Usage()
+		return 2
+	}
+
+	manifest, err := table.LoadManifest(*manifestPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading manifest:", err)
+		return 2
+	}
+
+	report, err := table.ApplyManifest(context.Background(), manifest, table.ApplyOptions{
+		ServiceAccount:  *serviceAccount,
+		Concurrency:     *concurrency,
+		ContinueOnError: *continueOnError,
+	})
+	if werr := report.WriteSummary(os.Stdout); werr != nil {
+		fmt.Fprintln(os.Stderr, "Error writing summary:", werr)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
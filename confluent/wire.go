@@ -0,0 +1,185 @@
+// Package confluent decodes Kafka messages in the Confluent wire format,
+// a magic byte and a schema ID followed by an Avro binary record, into
+// BigQuery rows.
+package confluent
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/row"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// MagicByte starts every message in the Confluent wire format.
+const MagicByte = 0
+
+// HeaderSize is the length of the magic byte and the big-endian schema ID
+// that precede the Avro payload.
+const HeaderSize = 5
+
+// ParseHeader returns the schema ID of msg and the Avro payload that
+// follows it.
+func ParseHeader(msg []byte) (int, []byte, error) {
+	if len(msg) < HeaderSize {
+		return 0, nil, fmt.Errorf("message of %d bytes is shorter than the wire format header", len(msg))
+	}
+	if msg[0] != MagicByte {
+		return 0, nil, fmt.Errorf("unknown magic byte %#x", msg[0])
+	}
+	id := binary.BigEndian.Uint32(msg[1:HeaderSize])
+	if id > 1<<31-1 {
+		return 0, nil, fmt.Errorf("invalid schema ID %d", id)
+	}
+	return int(id), msg[HeaderSize:], nil
+}
+
+// AppendHeader appends the wire format header of schema ID id to b.
+func AppendHeader(b []byte, id int) []byte {
+	var header [HeaderSize]byte
+	header[0] = MagicByte
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	return append(b, header[:]...)
+}
+
+// SchemaSource looks up writer schemas by schema ID, such as a schema
+// registry client.
+type SchemaSource interface {
+	SchemaByID(ctx context.Context, id int) (map[string]interface{}, error)
+}
+
+// SchemaSourceFunc adapts a function to a SchemaSource.
+type SchemaSourceFunc func(ctx context.Context, id int) (map[string]interface{}, error)
+
+// SchemaByID calls f.
+func (f SchemaSourceFunc) SchemaByID(ctx context.Context, id int) (map[string]interface{}, error) {
+	return f(ctx, id)
+}
+
+// StaticSource is a SchemaSource of a fixed set of schemas by ID.
+type StaticSource map[int]map[string]interface{}
+
+// SchemaByID returns the schema of ID id.
+func (s StaticSource) SchemaByID(_ context.Context, id int) (map[string]interface{}, error) {
+	avroSchema, ok := s[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema ID %d", id)
+	}
+	return avroSchema, nil
+}
+
+// Decoder decodes messages in the Confluent wire format into rows. The
+// writer schema of a message is looked up by its ID in a SchemaSource and
+// converted with the decoder's options; conversions and their row
+// decoders are cached by schema ID. Failed lookups are not cached, so a
+// transient error is retried by the next message. A Decoder is safe for
+// concurrent use.
+type Decoder struct {
+	source SchemaSource
+	opts   schema.ConvertOptions
+
+	mu    sync.Mutex
+	cache map[int]*cacheEntry
+}
+
+// cacheEntry is the conversion of a schema ID, ready once done is closed.
+type cacheEntry struct {
+	done       chan struct{}
+	conversion *schema.Conversion
+	decoder    *row.Decoder
+	err        error
+}
+
+// NewDecoder returns a decoder of messages whose writer schemas are looked
+// up in source and converted with opts. Flattening and normalization are
+// not supported.
+func NewDecoder(source SchemaSource, opts schema.ConvertOptions) *Decoder {
+	return &Decoder{source: source, opts: opts, cache: make(map[int]*cacheEntry)}
+}
+
+// Decode decodes msg into a row of the BigQuery schema converted from its
+// writer schema, and returns the schema ID.
+func (d *Decoder) Decode(ctx context.Context, msg []byte) (row.Row, int, error) {
+	id, payload, err := ParseHeader(msg)
+	if err != nil {
+		return nil, 0, err
+	}
+	e, err := d.entry(ctx, id)
+	if err != nil {
+		return nil, id, err
+	}
+	r, err := e.decoder.Decode(payload)
+	if err != nil {
+		return nil, id, fmt.Errorf("schema %d: %w", id, err)
+	}
+	return r, id, nil
+}
+
+// Conversion returns the conversion of the writer schema of ID id, such as
+// to create the table its messages are inserted into.
+func (d *Decoder) Conversion(ctx context.Context, id int) (*schema.Conversion, error) {
+	e, err := d.entry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return e.conversion, nil
+}
+
+// Schema returns the BigQuery schema of the rows of messages written with
+// the schema of ID id.
+func (d *Decoder) Schema(ctx context.Context, id int) (bigquery.Schema, error) {
+	c, err := d.Conversion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return c.Schema, nil
+}
+
+// entry returns the cached conversion of schema ID id, looking it up and
+// converting it on first use. Concurrent callers wait for a single lookup.
+func (d *Decoder) entry(ctx context.Context, id int) (*cacheEntry, error) {
+	d.mu.Lock()
+	e, ok := d.cache[id]
+	if !ok {
+		e = &cacheEntry{done: make(chan struct{})}
+		d.cache[id] = e
+		d.mu.Unlock()
+		e.conversion, e.decoder, e.err = d.convert(ctx, id)
+		if e.err != nil {
+			d.mu.Lock()
+			delete(d.cache, id)
+			d.mu.Unlock()
+		}
+		close(e.done)
+		return e, e.err
+	}
+	d.mu.Unlock()
+	select {
+	case <-e.done:
+		return e, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (d *Decoder) convert(ctx context.Context, id int) (*schema.Conversion, *row.Decoder, error) {
+	avroSchema, err := d.source.SchemaByID(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("looking up schema %d: %w", id, err)
+	}
+	if avroSchema["type"] != "record" && avroSchema["type"] != "error" {
+		return nil, nil, fmt.Errorf("schema %d is not a record schema", id)
+	}
+	c, err := schema.ConvertAvroToBigQueryWithOptions(avroSchema, d.opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("converting schema %d: %w", id, err)
+	}
+	decoder, err := row.NewConversionDecoder(c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	return c, decoder, nil
+}
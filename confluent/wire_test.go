@@ -0,0 +1,136 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/row"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// appendLong appends the zig-zag varint encoding of n.
+func appendLong(b []byte, n int64) []byte {
+	u := uint64(n<<1) ^ uint64(n>>63)
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendLong(b, int64(len(s))), s...)
+}
+
+var orderSchema = map[string]interface{}{
+	"type": "record",
+	"name": "Order",
+	"fields": []interface{}{
+		map[string]interface{}{"name": "orderId", "type": "string"},
+		map[string]interface{}{"name": "quantity", "type": "int"},
+	},
+}
+
+func TestParseHeader(t *testing.T) {
+	msg := AppendHeader(nil, 42)
+	msg = append(msg, 1, 2)
+	id, payload, err := ParseHeader(msg)
+	if err != nil || id != 42 || !reflect.DeepEqual(payload, []byte{1, 2}) {
+		t.Fatalf("Expected schema 42 and payload [1 2], but got %d and %v (%v)", id, payload, err)
+	}
+
+	invalid := map[string][]byte{
+		"short":      {0, 0, 0},
+		"magic":      {1, 0, 0, 0, 42},
+		"negative":   {0, 0x80, 0, 0, 0},
+		"empty":      nil,
+		"magic only": {0},
+	}
+	for name, msg := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := ParseHeader(msg); err == nil {
+				t.Fatalf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	var lookups int32
+	source := SchemaSourceFunc(func(ctx context.Context, id int) (map[string]interface{}, error) {
+		atomic.AddInt32(&lookups, 1)
+		return StaticSource{7: orderSchema}.SchemaByID(ctx, id)
+	})
+	d := NewDecoder(source, schema.ConvertOptions{Naming: schema.NamingSnakeCase})
+	msg := appendLong(appendString(AppendHeader(nil, 7), "o-1"), 3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, id, err := d.Decode(context.Background(), msg)
+			if err != nil {
+				t.Errorf("Error decoding message: %v", err)
+				return
+			}
+			want := row.Row{"order_id": "o-1", "quantity": int64(3)}
+			if id != 7 || !reflect.DeepEqual(r, want) {
+				t.Errorf("Expected %v with schema 7, but got %v with schema %d", want, r, id)
+			}
+		}()
+	}
+	wg.Wait()
+	if lookups != 1 {
+		t.Fatalf("Expected the schema to be looked up once, but it was looked up %d times", lookups)
+	}
+
+	s, err := d.Schema(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("Error getting schema: %v", err)
+	}
+	if len(s) != 2 || s[0].Name != "order_id" || s[1].Type != bigquery.IntegerFieldType {
+		t.Fatalf("Unexpected schema %v", s)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		if _, _, err := d.Decode(context.Background(), AppendHeader(nil, 8)); err == nil {
+			t.Fatalf("Expected an error for an unknown schema")
+		}
+		if _, _, err := d.Decode(context.Background(), msg[:len(msg)-1]); err == nil {
+			t.Fatalf("Expected an error for a truncated payload")
+		}
+		if _, _, err := d.Decode(context.Background(), append(msg, 0)); err == nil {
+			t.Fatalf("Expected an error for trailing bytes")
+		}
+	})
+}
+
+func TestDecoderRetriesFailedLookups(t *testing.T) {
+	fail := true
+	source := SchemaSourceFunc(func(ctx context.Context, id int) (map[string]interface{}, error) {
+		if fail {
+			return nil, errors.New("registry unavailable")
+		}
+		return orderSchema, nil
+	})
+	d := NewDecoder(source, schema.ConvertOptions{})
+	msg := appendLong(appendString(AppendHeader(nil, 1), "o-2"), 1)
+	if _, _, err := d.Decode(context.Background(), msg); err == nil {
+		t.Fatalf("Expected the lookup error")
+	}
+	fail = false
+	if _, _, err := d.Decode(context.Background(), msg); err != nil {
+		t.Fatalf("Expected the lookup to be retried, but got %v", err)
+	}
+
+	d = NewDecoder(StaticSource{2: {"type": "string"}}, schema.ConvertOptions{})
+	if _, err := d.Conversion(context.Background(), 2); err == nil {
+		t.Fatalf("Expected an error for a non-record schema")
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-syar/avro-schema-bq/idl"
+	"github.com/go-syar/avro-schema-bq/registry"
+	"github.com/go-syar/avro-schema-bq/schema"
+	"github.com/go-syar/avro-schema-bq/table"
+)
+
+// runConvert implements the "convert" subcommand, which converts an Avro
+// schema file to a BigQuery JSON schema written to stdout. It returns the
+// process exit code.
+func runConvert(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	profile := fs.String("profile", "", "mapping profile: "+strings.Join(schema.ProfileNames(), ", "))
+	naming := fs.String("naming", "as-is", "column naming strategy: as-is, snake_case or lowerCamel")
+	sanitize := fs.Bool("sanitize", false, "rewrite field names BigQuery would reject and resolve collisions")
+	mappingPath := fs.String("mapping", "", "write the Avro field to column mapping to this file")
+	flatten := fs.Bool("flatten", false, "turn nested records into top-level columns")
+	separator := fs.String("flatten-separator", schema.DefaultFlattenSeparator, "separator joining flattened column names")
+	arrays := fs.String("flatten-arrays", string(schema.ArraysKeep), "flattened array handling: keep or json")
+	overridesPath := fs.String("overrides", "", "YAML or JSON file of per-field type, mode, description, maxLength and policyTag overrides")
+	taxonomy := fs.String("policy-tag-taxonomy", "", "taxonomy resource name qualifying policy tags given by ID")
+	pii := fs.Bool("pii", false, "flag fields that likely hold personal data")
+	piiRules := fs.String("pii-rules", "", "YAML or JSON file of classification rules replacing the default ones (implies -pii)")
+	piiTags := fs.Bool("pii-policy-tags", false, "attach the policy tags of the classification rules to flagged columns")
+	piiReport := fs.String("pii-report", "", "write the classification report to this file (default: stderr)")
+	enumMode := fs.String("enum", string(schema.EnumAsString), "enum mapping: string, symbols, ordinal or check")
+	enumDefaults := fs.Bool("enum-defaults", false, "carry enum field defaults into default value expressions")
+	enumDocs := fs.Bool("enum-symbol-docs", false, "add enum symbol docs to column descriptions")
+	enumSQLPath := fs.String("enum-sql", "", "write the enum validation query to this file (with -enum check)")
+	enumTable := fs.String("enum-sql-table", "project.dataset.table", "qualified table name used in the enum validation query")
+	registryURL := fs.String("registry-url", "", "read the schema from this schema registry instead of a file")
+	subject := fs.String("subject", "", "registry subject of the schema, such as orders-value")
+	version := fs.String("version", registry.Latest, "registry schema version: a number or latest")
+	registryAuth := fs.String("registry-auth", "", "registry basic auth credentials as user:password, read from $SCHEMA_REGISTRY_AUTH when not given")
+	registryCA := fs.String("registry-ca", "", "PEM file of CA certificates trusted for the registry")
+	registryCert := fs.String("registry-cert", "", "PEM client certificate presented to the registry")
+	registryKey := fs.String("registry-key", "", "PEM key of the registry client certificate")
+	strictAnnotations := fs.Bool("strict-annotations", false, "fail on unknown bq.* annotations instead of ignoring them with a warning")
+	rootType := fs.String("type", "", "convert this named type of the schema files, IDL files and directories given, which may reference each other's types")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	authSet := false
+	fs.Visit(func(f *flag.Flag) { authSet = authSet || f.Name == "registry-auth" })
+	if !authSet {
+		*registryAuth = os.Getenv("SCHEMA_REGISTRY_AUTH")
+	}
+	var usage bool
+	switch {
+	case *registryURL != "":
+		usage = fs.NArg() != 0 || *subject == "" || *rootType != ""
+	case *rootType != "":
+		usage = fs.NArg() == 0
+	default:
+		usage = fs.NArg() != 1
+	}
+	if usage {
+		fmt.Fprintln(os.Stderr, "usage: convert [flags] schema.avsc|schema.avdl|data.avro")
+		fmt.Fprintln(os.Stderr, "       convert -type NAME [flags] schema.avsc|schema.avdl|dir...")
+		fmt.Fprintln(os.Stderr, "       convert -registry-url URL -subject SUBJECT [flags]")
+		fs.PrintDefaults()
+		return 2
+	}
+
+	opts := schema.ConvertOptions{PolicyTagTaxonomy: *taxonomy, StrictAnnotations: *strictAnnotations}
+	if *profile != "" {
+		p, err := schema.ProfileByName(*profile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 2
+		}
+		opts.Profile = p
+	}
+	strategy, err := schema.NamingStrategyByName(*naming)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	opts.Naming = strategy
+	if *sanitize {
+		opts.Sanitize = &schema.SanitizeOptions{}
+	}
+	if *flatten {
+		opts.Flatten = &schema.FlattenOptions{Separator: *separator, Arrays: schema.ArrayHandling(*arrays)}
+		if opts.Flatten.Arrays != schema.ArraysKeep && opts.Flatten.Arrays != schema.ArraysAsJSON {
+			fmt.Fprintf(os.Stderr, "Error: unknown -flatten-arrays value %q\n", *arrays)
+			return 2
+		}
+	}
+	mode, err := schema.EnumModeByName(*enumMode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	if mode != schema.EnumAsString || *enumDefaults || *enumDocs {
+		opts.Enums = &schema.EnumOptions{Mode: mode, Defaults: *enumDefaults, SymbolDocs: *enumDocs}
+	}
+	if *overridesPath != "" {
+		overrides, err := schema.LoadOverrides(*overridesPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading overrides:", err)
+			return 1
+		}
+		opts.Overrides = overrides
+	}
+	if *pii || *piiRules != "" || *piiTags {
+		opts.Classify = &schema.ClassifyOptions{PolicyTags: *piiTags}
+		if *piiRules != "" {
+			rules, err := schema.LoadClassificationRules(*piiRules)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error reading classification rules:", err)
+				return 1
+			}
+			opts.Classify.Rules = rules
+		}
+	}
+
+	var avroSchema map[string]interface{}
+	if *registryURL != "" {
+		cfg := registry.Config{URL: *registryURL}
+		if *registryAuth != "" {
+			var ok bool
+			if cfg.Username, cfg.Password, ok = strings.Cut(*registryAuth, ":"); !ok {
+				fmt.Fprintln(os.Stderr, "Error: -registry-auth must be user:password")
+				return 2
+			}
+		}
+		if *registryCA != "" || *registryCert != "" || *registryKey != "" {
+			if cfg.TLS, err = registry.TLSConfig(*registryCA, *registryCert, *registryKey); err != nil {
+				fmt.Fprintln(os.Stderr, "Error reading registry TLS files:", err)
+				return 1
+			}
+		}
+		avroSchema, err = fetchRegistrySchema(cfg, *subject, *version)
+	} else if *rootType != "" {
+		avroSchema, err = resolveSchemaFiles(fs.Args(), *rootType)
+	} else {
+		avroSchema, err = table.ReadAvroSchemaFile(fs.Arg(0))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading Avro schema:", err)
+		return 1
+	}
+	conversion, err := schema.ConvertAvroToBigQueryWithOptions(avroSchema, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	for _, w := range conversion.Warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+
+	if *mappingPath != "" {
+		f, err := os.Create(*mappingPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing mapping file:", err)
+			return 1
+		}
+		err = conversion.WriteMapping(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing mapping file:", err)
+			return 1
+		}
+	}
+
+	if opts.Classify != nil {
+		if err := writeClassifications(*piiReport, conversion.Classifications); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing classification report:", err)
+			return 1
+		}
+	}
+
+	if *enumSQLPath != "" {
+		query := conversion.EnumValidationSQL(*enumTable)
+		if query != "" {
+			query += "\n"
+		}
+		if err := ioutil.WriteFile(*enumSQLPath, []byte(query), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing enum validation query:", err)
+			return 1
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(conversion.Schema, "", "    ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error marshaling BigQuery schema to JSON:", err)
+		return 1
+	}
+	fmt.Println(string(jsonData))
+	return 0
+}
+
+// writeClassifications writes one line per flagged field to the file at
+// path, or to stderr when path is empty.
+func writeClassifications(path string, classifications []schema.Classification) error {
+	var b strings.Builder
+	for _, c := range classifications {
+		fmt.Fprintf(&b, "%s\t%s\t%s", c.ColumnPath, c.Category, c.Reason)
+		if c.PolicyTag != "" {
+			fmt.Fprintf(&b, "\t%s", c.PolicyTag)
+		}
+		b.WriteByte('\n')
+	}
+	if path == "" {
+		_, err := io.WriteString(os.Stderr, b.String())
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+// fetchRegistrySchema reads the schema of subject at version from the
+// registry configured by cfg, with its references resolved.
+func fetchRegistrySchema(cfg registry.Config, subject, version string) (map[string]interface{}, error) {
+	c, err := registry.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	s, err := c.SchemaVersion(ctx, subject, version)
+	if err != nil {
+		return nil, err
+	}
+	return c.Resolve(ctx, s)
+}
+
+// resolveSchemaFiles loads the named types of the Avro schema and IDL
+// files and directories at paths and resolves the type name.
+func resolveSchemaFiles(paths []string, name string) (map[string]interface{}, error) {
+	set := schema.NewSchemaSet()
+	for _, path := range paths {
+		if !isIDLFile(path) {
+			if err := set.Load(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		f, err := idl.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.AddTo(set); err != nil {
+			return nil, err
+		}
+	}
+	if err := set.Check(); err != nil {
+		return nil, err
+	}
+	return set.Resolve(name)
+}
+
+func isIDLFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".avdl")
+}
@@ -17,6 +17,82 @@ Required arguments projectID, datasetID, tableID, serviceAccount, schemaFilePath
 -- table.CreateBQTableWithSA(projectID string, datasetID string, tableID string, serviceAccount string, schemaFilePath string) error
 // service account := "service-account.json"
 
+schemaFilePath may also be an Avro data file (.avro); its schema is read from the file header
+
+-- ocf.ReadFileHeader(path string) (*ocf.Header, error)
+
+# Create BQ Tables from a manifest
+
+List project, dataset, table, schema file and table options in a YAML or JSON manifest and apply it
+
+-- avro-schema-bq apply -manifest tables.yaml -credentials service-account.json -concurrency 8 -continue-on-error
+
+-- table.ApplyManifest(ctx context.Context, m *table.Manifest, opts table.ApplyOptions) (*table.ApplyReport, error)
+
+# Detect schema drift
+
+Compare live table schemas with their Avro schemas; exits 0 when clean, 1 on drift and 2 on errors
+
+-- avro-schema-bq drift -manifest tables.yaml -format json
+
+-- table.DetectDrift(ctx context.Context, client *bigquery.Client, datasetID, tableID string, avroSchema map[string]interface{}) ([]schema.SchemaDifference, error)
+
+# Normalize arrays of records
+
+Create a root table and one child table per array of records, linked by primary and foreign keys
+
+-- table.CreateNormalizedTables(ctx context.Context, client *bigquery.Client, datasetID string, avroSchema map[string]interface{}, opts schema.NormalizeOptions, tableOpts *table.TableOptions) ([]schema.TableSchema, error)
+
+# Read Avro data files
+
+Decode the records of an Avro data file into BigQuery rows, skipping corrupt blocks, and stream them into a table
+
+-- avro-schema-bq rows -skip-corrupt data.avro
+
+-- ocf.OpenFile(path string, opts ocf.ReaderOptions) (*ocf.Reader, error)
+
+-- table.InsertContainerFile(ctx context.Context, client *bigquery.Client, datasetID, tableID, path string, opts ocf.ReaderOptions, batchSize int) (int, []*ocf.CorruptBlockError, error)
+
+Records in the Avro JSON encoding, one per line, decode into the same rows
+
+-- avro-schema-bq rows -schema schema.avsc records.ndjson
+
+-- table.InsertJSONFile(ctx context.Context, client *bigquery.Client, datasetID, tableID, path string, avroSchema map[string]interface{}, convertOpts schema.ConvertOptions, batchSize int) (int, error)
+
+# Decode Kafka messages
+
+Decode messages in the Confluent wire format, looking up writer schemas by ID and caching their conversions
+
+-- confluent.NewDecoder(source confluent.SchemaSource, opts schema.ConvertOptions) *confluent.Decoder
+
+# Schemas split across files
+
+Load .avsc files and directories into one set of named types and convert a root type
+
+-- avro-schema-bq convert -type com.shop.Order schemas/
+
+-- schema.LoadSchemaSet(paths ...string) (*schema.SchemaSet, error)
+
+# Avro IDL
+
+Parse Avro IDL (.avdl) files, in the protocol or the schema syntax, with their imports; table creation, manifests and the convert and rows subcommands accept .avdl files too
+
+-- avro-schema-bq convert schema.avdl
+
+-- idl.ParseFile(path string) (*idl.File, error)
+
+-- idl.LoadSchema(path string) (map[string]interface{}, error)
+
+# Read schemas from a schema registry
+
+Fetch a subject version with basic auth and TLS, inlining the types it references
+
+-- avro-schema-bq convert -registry-url https://registry:8081 -subject orders-value -version latest
+
+-- registry.NewClient(cfg registry.Config) (*registry.Client, error)
+
+-- schema.InlineReferences(avroSchema map[string]interface{}, types map[string]map[string]interface{}) (map[string]interface{}, error)
+
 # Avro Schema (avsc) to BQ Schema (json)
 
 schema.ConvertAvroToBigQuery(avroSchema map[string]interface{}) ([]*bigquery.FieldSchema, error)
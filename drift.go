@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-syar/avro-schema-bq/table"
+)
+
+// Exit codes of the "drift" subcommand.
+const (
+	driftExitClean = 0
+	driftExitDrift = 1
+	driftExitError = 2
+)
+
+// runDrift implements the "drift" subcommand, which compares the live
+// schema of one table, or of every table in a manifest, with its Avro
+// schema. It exits with 0 when no drift is found, 1 when at least one
+// table drifted and 2 when a table could not be checked.
+func runDrift(args []string) int {
+	fs := flag.NewFlagSet("drift", flag.ContinueOnError)
+	manifestPath := fs.String("manifest", "", "path to the YAML or JSON table manifest")
+	projectID := fs.String("project", "", "project of a single table to check")
+	datasetID := fs.String("dataset", "", "dataset of a single table to check")
+	tableID := fs.String("table", "", "single table to check")
+	schemaFilePath := fs.String("schema", "", "Avro schema (.avsc) file of a single table to check")
+	serviceAccount := fs.String("credentials", "", "service account credentials file (default: application default credentials)")
+	concurrency := fs.Int("concurrency", table.DefaultConcurrency, "maximum number of tables checked in parallel")
+	format := fs.String("format", "text", "report format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return driftExitError
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "drift: unknown format %q\n", *format)
+		return driftExitError
+	}
+
+	var manifest *table.Manifest
+	var err error
+	switch {
+	case *manifestPath != "":
+		manifest, err = table.LoadManifest(*manifestPath)
+	case *projectID != "" && *datasetID != "" && *tableID != "" && *schemaFilePath != "":
+		manifest = &table.Manifest{Tables: []table.ManifestEntry{{
+			Project: *projectID, Dataset: *datasetID, Table: *tableID, Schema: *schemaFilePath,
+		}}}
+	default:
+		err = fmt.Errorf("either -manifest or -project, -dataset, -table and -schema are required")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "drift:", err)
+		return driftExitError
+	}
+
+	report := table.DetectManifestDrift(context.Background(), manifest, table.ApplyOptions{
+		ServiceAccount: *serviceAccount,
+		Concurrency:    *concurrency,
+	})
+	if *format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "drift:", err)
+		return driftExitError
+	}
+
+	switch {
+	case report.HasErrors():
+		return driftExitError
+	case report.HasDrift():
+		return driftExitDrift
+	}
+	return driftExitClean
+}
@@ -0,0 +1,213 @@
+// Package idl parses Avro IDL (.avdl) files into the JSON form of Avro
+// schemas used by the schema package, so that IDL-defined records can be
+// converted to BigQuery schemas.
+//
+// Both the protocol syntax and the schema syntax ("namespace" and
+// "schema" declarations) are supported, with records, errors, enums,
+// fixed types, unions, optional types ("string?"), logical type
+// shorthands such as decimal(10,2) and timestamp_ms, annotations such as
+// @namespace, @aliases and @logicalType, doc comments, and imports of
+// IDL, schema (.avsc) and protocol (.avpr) files. Protocol messages are
+// skipped.
+package idl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// File is a parsed IDL file.
+type File struct {
+	Path string
+	// Protocol is the name of the protocol, empty in the schema syntax.
+	Protocol string
+	// Namespace is the namespace of the protocol, or the one declared in
+	// the schema syntax.
+	Namespace string
+	// Doc is the doc comment of the protocol.
+	Doc string
+	// Types holds the named types declared in the file and those of the
+	// files it imports, in declaration order.
+	Types []map[string]interface{}
+	// Schema is the main schema declared with "schema" in the schema
+	// syntax, or nil.
+	Schema interface{}
+
+	// sources holds the file each type was read from.
+	sources []string
+}
+
+// ParseFile parses the IDL file at path and the files it imports.
+func ParseFile(path string) (*File, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, src)
+}
+
+// Parse parses src, the content of the IDL file at path. Imports are
+// read relative to the directory of path; a file imported twice is only
+// read once.
+func Parse(path string, src []byte) (*File, error) {
+	return parse(path, src, make(map[string]bool))
+}
+
+func parse(path string, src []byte, imported map[string]bool) (*File, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		imported[abs] = true
+	}
+	f := &File{Path: path}
+	p := &parser{
+		lex: &lexer{src: string(src), line: 1, col: 1},
+		f:   f,
+		load: func(kind, name string) error {
+			return f.load(kind, filepath.Join(filepath.Dir(path), name), imported)
+		},
+	}
+	if err := p.parseFile(); err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	return f, nil
+}
+
+// load adds the types of the file of kind idl, schema or protocol at path
+// to f.
+func (f *File) load(kind, path string, imported map[string]bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if imported[abs] {
+		return nil
+	}
+	imported[abs] = true
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "idl":
+		sub, err := parse(path, src, imported)
+		if err != nil {
+			return err
+		}
+		f.Types = append(f.Types, sub.Types...)
+		f.sources = append(f.sources, sub.sources...)
+	case "schema":
+		var avroSchema interface{}
+		if err := json.Unmarshal(src, &avroSchema); err != nil {
+			return err
+		}
+		schemas, ok := avroSchema.([]interface{})
+		if !ok {
+			schemas = []interface{}{avroSchema}
+		}
+		for _, s := range schemas {
+			if err := f.addImported(path, s, ""); err != nil {
+				return err
+			}
+		}
+	case "protocol":
+		var protocol struct {
+			Namespace string        `json:"namespace"`
+			Types     []interface{} `json:"types"`
+		}
+		if err := json.Unmarshal(src, &protocol); err != nil {
+			return err
+		}
+		for _, s := range protocol.Types {
+			if err := f.addImported(path, s, protocol.Namespace); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addImported adds the named type t read from path, in namespace ns
+// unless it declares its own.
+func (f *File) addImported(path string, t interface{}, ns string) error {
+	def, ok := t.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: top-level schema is not a named type", path)
+	}
+	name, _ := def["name"].(string)
+	if _, explicit := def["namespace"]; !explicit && ns != "" && !strings.Contains(name, ".") {
+		def["namespace"] = ns
+	}
+	f.Types = append(f.Types, def)
+	f.sources = append(f.sources, path)
+	return nil
+}
+
+// AddTo adds the types of f to set, naming the file each was read from in
+// errors.
+func (f *File) AddTo(set *schema.SchemaSet) error {
+	for i, t := range f.Types {
+		if err := set.Add(f.sources[i], t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve returns the named type name of f, a full name or a name unique
+// among its types, as a standalone schema (see schema.SchemaSet.Resolve).
+// An empty name stands for the main schema of the schema syntax or, when
+// there is none, the only record declared in the file itself.
+func (f *File) Resolve(name string) (map[string]interface{}, error) {
+	set := schema.NewSchemaSet()
+	if err := f.AddTo(set); err != nil {
+		return nil, err
+	}
+	if err := set.Check(); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		var err error
+		if name, err = f.mainName(); err != nil {
+			return nil, err
+		}
+	}
+	return set.Resolve(name)
+}
+
+// mainName returns the full name of the main schema of f.
+func (f *File) mainName() (string, error) {
+	if f.Schema != nil {
+		name, ok := f.Schema.(string)
+		if !ok || primitiveTypes[name] {
+			return "", fmt.Errorf("%s: the main schema is not a named type", f.Path)
+		}
+		if !strings.Contains(name, ".") && f.Namespace != "" {
+			name = f.Namespace + "." + name
+		}
+		return name, nil
+	}
+	var records []string
+	for i, t := range f.Types {
+		if f.sources[i] == f.Path && (t["type"] == "record" || t["type"] == "error") {
+			records = append(records, schema.SchemaFullName(t))
+		}
+	}
+	if len(records) != 1 {
+		return "", fmt.Errorf("%s declares %d records and no main schema; name the type to use", f.Path, len(records))
+	}
+	return records[0], nil
+}
+
+// LoadSchema parses the IDL file at path and returns its main schema, as
+// Resolve with an empty name does.
+func LoadSchema(path string) (map[string]interface{}, error) {
+	f, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.Resolve("")
+}
@@ -0,0 +1,282 @@
+package idl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+const shopIDL = `// Orders of the shop.
+
+/**
+ * The shop protocol.
+ */
+@namespace("com.shop")
+protocol Shop {
+  import idl "common.avdl";
+  import schema "money.avsc";
+  import protocol "crm.avpr";
+
+  /** Lifecycle of an order. */
+  enum Status { NEW, PAID, SHIPPED } = NEW;
+
+  fixed MD5(16);
+
+  /** An order. */
+  @aliases(["Purchase"])
+  record Order {
+    /** Order identifier. */
+    uuid id;
+    Status status = "NEW";
+    com.crm.Customer customer;
+    com.common.Address? shipping = null;
+    union { null, com.common.Address } billing = null;
+    array<Line> lines = [];
+    map<string> labels = {};
+    decimal(10, 2) total;
+    @logicalType("timestamp-micros") long created;
+    timestamp_ms updated;
+    date due;
+    time_ms cutoff;
+    string @aliases(["note", "remark"]) comment, ` + "`error`" + ` = "none";
+    MD5? checksum;
+    int? priority = 3;
+  }
+
+  record Line {
+    string sku;
+    int quantity = 1;
+    double price = -1.5e2;
+  }
+
+  error OrderRejected { string reason; }
+
+  Order place(Order order) throws OrderRejected;
+  void ping() oneway;
+}
+`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func field(t *testing.T, record map[string]interface{}, name string) map[string]interface{} {
+	t.Helper()
+	for _, f := range record["fields"].([]interface{}) {
+		if f := f.(map[string]interface{}); f["name"] == name {
+			return f
+		}
+	}
+	t.Fatalf("Expected a field %s in %v", name, record["name"])
+	return nil
+}
+
+func TestParseFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"shop.avdl": shopIDL,
+		"common.avdl": `@namespace("com.common") protocol Common {
+			record Address { string city; string? zip; }
+		}`,
+		"money.avsc": `{"type": "record", "name": "Money", "namespace": "com.common", "fields": [{"name": "units", "type": "long"}]}`,
+		"crm.avpr": `{"protocol": "CRM", "namespace": "com.crm", "types": [
+			{"type": "record", "name": "Customer", "fields": [{"name": "name", "type": "string"}]}
+		], "messages": {}}`,
+	})
+
+	f, err := ParseFile(filepath.Join(dir, "shop.avdl"))
+	if err != nil {
+		t.Fatalf("Error parsing IDL: %v", err)
+	}
+	if f.Protocol != "Shop" || f.Namespace != "com.shop" || f.Doc != "The shop protocol." {
+		t.Fatalf("Expected protocol com.shop.Shop with its doc, but got %s %s %q", f.Namespace, f.Protocol, f.Doc)
+	}
+	var names []string
+	for _, def := range f.Types {
+		names = append(names, schema.SchemaFullName(def))
+	}
+	expected := []string{"com.common.Address", "com.common.Money", "com.crm.Customer", "com.shop.Status", "com.shop.MD5", "com.shop.Order", "com.shop.Line", "com.shop.OrderRejected"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected types %v, but got %v", expected, names)
+	}
+
+	status := f.Types[3]
+	if !reflect.DeepEqual(status["symbols"], []interface{}{"NEW", "PAID", "SHIPPED"}) || status["default"] != "NEW" || status["doc"] != "Lifecycle of an order." {
+		t.Fatalf("Unexpected enum %v", status)
+	}
+	if f.Types[4]["size"] != 16.0 {
+		t.Fatalf("Expected a fixed of size 16, but got %v", f.Types[4])
+	}
+
+	order := f.Types[5]
+	if order["doc"] != "An order." || !reflect.DeepEqual(order["aliases"], []interface{}{"Purchase"}) {
+		t.Fatalf("Expected the doc and aliases of Order, but got %v", order)
+	}
+	tests := []struct {
+		field    string
+		expected map[string]interface{}
+	}{
+		{"id", map[string]interface{}{"name": "id", "doc": "Order identifier.", "type": map[string]interface{}{"type": "string", "logicalType": "uuid"}}},
+		{"status", map[string]interface{}{"name": "status", "type": "Status", "default": "NEW"}},
+		{"shipping", map[string]interface{}{"name": "shipping", "type": []interface{}{"null", "com.common.Address"}, "default": nil}},
+		{"billing", map[string]interface{}{"name": "billing", "type": []interface{}{"null", "com.common.Address"}, "default": nil}},
+		{"lines", map[string]interface{}{"name": "lines", "type": map[string]interface{}{"type": "array", "items": "Line"}, "default": []interface{}{}}},
+		{"labels", map[string]interface{}{"name": "labels", "type": map[string]interface{}{"type": "map", "values": "string"}, "default": map[string]interface{}{}}},
+		{"total", map[string]interface{}{"name": "total", "type": map[string]interface{}{"type": "bytes", "logicalType": "decimal", "precision": 10.0, "scale": 2.0}}},
+		{"created", map[string]interface{}{"name": "created", "type": map[string]interface{}{"type": "long", "logicalType": "timestamp-micros"}}},
+		{"updated", map[string]interface{}{"name": "updated", "type": map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}}},
+		{"due", map[string]interface{}{"name": "due", "type": map[string]interface{}{"type": "int", "logicalType": "date"}}},
+		{"cutoff", map[string]interface{}{"name": "cutoff", "type": map[string]interface{}{"type": "int", "logicalType": "time-millis"}}},
+		{"comment", map[string]interface{}{"name": "comment", "type": "string", "aliases": []interface{}{"note", "remark"}}},
+		{"error", map[string]interface{}{"name": "error", "type": "string", "default": "none"}},
+		{"checksum", map[string]interface{}{"name": "checksum", "type": []interface{}{"null", "MD5"}}},
+		{"priority", map[string]interface{}{"name": "priority", "type": []interface{}{"int", "null"}, "default": 3.0}},
+	}
+	for _, test := range tests {
+		t.Run(test.field, func(t *testing.T) {
+			if got := field(t, order, test.field); !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("Expected %v, but got %v", test.expected, got)
+			}
+		})
+	}
+	if price := field(t, f.Types[6], "price"); price["default"] != -150.0 {
+		t.Fatalf("Expected a default of -150, but got %v", price["default"])
+	}
+	if f.Types[7]["type"] != "error" {
+		t.Fatalf("Expected an error type, but got %v", f.Types[7])
+	}
+
+	if _, err := f.Resolve(""); err == nil {
+		t.Fatalf("Expected an error for a file of several records without a main schema")
+	}
+	resolved, err := f.Resolve("Order")
+	if err != nil {
+		t.Fatalf("Error resolving Order: %v", err)
+	}
+	profile, err := schema.ProfileByName(schema.ProfileBQLoadLogicalTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := schema.ConvertAvroToBigQueryWithOptions(resolved, schema.ConvertOptions{Profile: profile})
+	if err != nil {
+		t.Fatalf("Error converting Order: %v", err)
+	}
+	s := c.Schema
+	columns := map[string]*bigquery.FieldSchema{}
+	for _, col := range s {
+		columns[col.Name] = col
+	}
+	if col := columns["customer"]; col == nil || col.Type != bigquery.RecordFieldType || !col.Required {
+		t.Fatalf("Expected a required customer record, but got %v", col)
+	}
+	if col := columns["shipping"]; col == nil || col.Type != bigquery.RecordFieldType || col.Required {
+		t.Fatalf("Expected a nullable shipping record, but got %v", col)
+	}
+	if col := columns["lines"]; col == nil || !col.Repeated || col.Type != bigquery.RecordFieldType {
+		t.Fatalf("Expected repeated line records, but got %v", col)
+	}
+	if col := columns["total"]; col == nil || col.Type != bigquery.NumericFieldType {
+		t.Fatalf("Expected NUMERIC, but got %v", col)
+	}
+	if col := columns["created"]; col == nil || col.Type != bigquery.TimestampFieldType {
+		t.Fatalf("Expected a TIMESTAMP, but got %v", col)
+	}
+}
+
+func TestParseSchemaSyntax(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"event.avdl": `namespace com.events;
+schema Event;
+
+import idl "common.avdl";
+
+/** An event. */
+record Event {
+  string id;
+  com.common.Address? place;
+}
+
+enum Kind { A, B }
+`,
+		"common.avdl": `namespace com.common;
+record Address { string city; }
+`,
+	})
+
+	f, err := ParseFile(filepath.Join(dir, "event.avdl"))
+	if err != nil {
+		t.Fatalf("Error parsing IDL: %v", err)
+	}
+	if f.Protocol != "" || f.Namespace != "com.events" || f.Schema != "Event" {
+		t.Fatalf("Expected the main schema Event in com.events, but got %q %q %v", f.Protocol, f.Namespace, f.Schema)
+	}
+	event, err := LoadSchema(filepath.Join(dir, "event.avdl"))
+	if err != nil {
+		t.Fatalf("Error loading main schema: %v", err)
+	}
+	if event["name"] != "Event" || event["namespace"] != "com.events" || event["doc"] != "An event." {
+		t.Fatalf("Expected the Event record, but got %v", event)
+	}
+	s, err := schema.ConvertAvroToBigQuery(event)
+	if err != nil {
+		t.Fatalf("Error converting Event: %v", err)
+	}
+	if len(s) != 2 || s[1].Type != bigquery.RecordFieldType || s[1].Required {
+		t.Fatalf("Expected a nullable place record, but got %v", s)
+	}
+
+	address, err := LoadSchema(filepath.Join(dir, "common.avdl"))
+	if err != nil || address["name"] != "Address" {
+		t.Fatalf("Expected the only record of the file, but got %v (%v)", address, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"missing semicolon", "protocol P { record R { string a } }", "1:34: expected \";\""},
+		{"unknown annotation target", "@namespace(\"a\") record R {}", "expected \"protocol\""},
+		{"duplicate field", "protocol P { record R { string a; int a; } }", "field a of R is declared twice"},
+		{"duplicate symbol", "protocol P { enum E { A, A } }", "symbol A of E is declared twice"},
+		{"bad enum default", "protocol P { enum E { A } = B; }", "default B is not a symbol of E"},
+		{"bad decimal", "protocol P { record R { decimal(2, 3) d; } }", "invalid decimal(2,3)"},
+		{"nested union", "protocol P { record R { union { null, union { int } } u; } }", "unions cannot be nested"},
+		{"unterminated comment", "protocol P { /* }", "unterminated comment"},
+		{"unterminated string", "protocol P { record R { string a = \"x; } }", "unterminated string"},
+		{"trailing tokens", "protocol P { } record R {}", "after the protocol"},
+		{"missing import", "protocol P { import idl \"missing.avdl\"; }", "import \"missing.avdl\""},
+		{"bad aliases", "protocol P { @aliases(\"A\") record R {} }", "@aliases of R is not a list of strings"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(filepath.Join(t.TempDir(), "p.avdl"), []byte(test.src))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Fatalf("Expected an error containing %q, but got %v", test.expected, err)
+			}
+		})
+	}
+
+	t.Run("unknown type", func(t *testing.T) {
+		f, err := Parse("p.avdl", []byte("protocol P { record R { Missing m; } }"))
+		if err != nil {
+			t.Fatalf("Error parsing IDL: %v", err)
+		}
+		if _, err := f.Resolve("R"); err == nil || !strings.Contains(err.Error(), "Missing (referenced by R in p.avdl)") {
+			t.Fatalf("Expected an unknown type error, but got %v", err)
+		}
+	})
+}
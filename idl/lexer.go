@@ -0,0 +1,184 @@
+package idl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind is the kind of a token of an IDL file.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+// token is a token of an IDL file. Doc holds the doc comment that
+// precedes it, if any.
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	col  int
+	doc  string
+	// quoted is set for identifiers in backquotes, which are never
+	// keywords.
+	quoted bool
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenString:
+		return t.text
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexer splits an IDL file into tokens, skipping comments.
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func (l *lexer) errorf(line, col int, format string, args ...interface{}) error {
+	return fmt.Errorf("%d:%d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+// next returns the next token.
+func (l *lexer) next() (token, error) {
+	doc, err := l.skipSpace()
+	if err != nil {
+		return token{}, err
+	}
+	t := token{line: l.line, col: l.col, doc: doc}
+	if l.pos >= len(l.src) {
+		return t, nil
+	}
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case c == '"':
+		l.advance()
+		for {
+			if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+				return token{}, l.errorf(t.line, t.col, "unterminated string")
+			}
+			c := l.src[l.pos]
+			l.advance()
+			if c == '\\' && l.pos < len(l.src) {
+				l.advance()
+			} else if c == '"' {
+				break
+			}
+		}
+		t.kind = tokenString
+	case c == '`':
+		l.advance()
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.advance()
+		}
+		if l.pos >= len(l.src) || l.src[l.pos] != '`' || l.pos == start+1 {
+			return token{}, l.errorf(t.line, t.col, "invalid quoted identifier")
+		}
+		l.advance()
+		t.kind, t.text, t.quoted = tokenIdent, l.src[start+1:l.pos-1], true
+		return t, nil
+	case c == '-' || c >= '0' && c <= '9':
+		l.advance()
+		for l.pos < len(l.src) && (isIdentChar(l.src[l.pos]) || l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			if (l.src[l.pos] == '+' || l.src[l.pos] == '-') && l.src[l.pos-1] != 'e' && l.src[l.pos-1] != 'E' {
+				break
+			}
+			l.advance()
+		}
+		t.kind = tokenNumber
+	case isIdentStart(c):
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.advance()
+		}
+		t.kind = tokenIdent
+	case strings.IndexByte("{}()[]<>,;=?@:", c) >= 0:
+		l.advance()
+		t.kind = tokenPunct
+	default:
+		r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+		return token{}, l.errorf(t.line, t.col, "unexpected character %q", r)
+	}
+	t.text = l.src[start:l.pos]
+	return t, nil
+}
+
+// skipSpace skips white space and comments, and returns the text of the
+// last doc comment skipped.
+func (l *lexer) skipSpace() (string, error) {
+	var doc string
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f':
+			l.advance()
+		case strings.HasPrefix(l.src[l.pos:], "//"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance()
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			line, col := l.line, l.col
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return "", l.errorf(line, col, "unterminated comment")
+			}
+			comment := l.src[l.pos : l.pos+2+end+2]
+			for range comment {
+				l.advance()
+			}
+			if strings.HasPrefix(comment, "/**") && comment != "/**/" {
+				doc = docText(comment[3 : len(comment)-2])
+			}
+		default:
+			return doc, nil
+		}
+	}
+	return doc, nil
+}
+
+// advance moves past the current byte, tracking lines and columns.
+func (l *lexer) advance() {
+	if l.src[l.pos] == '\n' {
+		l.line++
+		l.col = 1
+	} else if l.src[l.pos]&0xC0 != 0x80 {
+		l.col++
+	}
+	l.pos++
+}
+
+// docText returns the text of a doc comment body, without the leading
+// asterisks of its lines.
+func docText(body string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		if strings.HasPrefix(line, "*") {
+			line = strings.TrimPrefix(line[1:], " ")
+		}
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '.' || c == '-'
+}
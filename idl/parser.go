@@ -0,0 +1,692 @@
+package idl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// primitiveTypes are the primitive Avro types.
+var primitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// logicalTypes are the shorthands of logical types.
+var logicalTypes = map[string]map[string]interface{}{
+	"date":               {"type": "int", "logicalType": "date"},
+	"time_ms":            {"type": "int", "logicalType": "time-millis"},
+	"timestamp_ms":       {"type": "long", "logicalType": "timestamp-millis"},
+	"local_timestamp_ms": {"type": "long", "logicalType": "local-timestamp-millis"},
+	"uuid":               {"type": "string", "logicalType": "uuid"},
+}
+
+// parser parses the tokens of an IDL file into f.
+type parser struct {
+	lex  *lexer
+	tok  token
+	f    *File
+	load func(kind, path string) error
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%d:%d: %s", t.line, t.col, fmt.Sprintf(format, args...))
+}
+
+// next moves to the next token.
+func (p *parser) next() error {
+	t, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+// is reports whether the current token is the punctuation or keyword s.
+func (p *parser) is(s string) bool {
+	return (p.tok.kind == tokenPunct || p.tok.kind == tokenIdent && !p.tok.quoted) && p.tok.text == s
+}
+
+// expect consumes the punctuation or keyword s.
+func (p *parser) expect(s string) error {
+	if !p.is(s) {
+		return p.errorf(p.tok, "expected %q, found %s", s, p.tok)
+	}
+	return p.next()
+}
+
+// ident consumes an identifier and returns it.
+func (p *parser) ident(what string) (string, error) {
+	if p.tok.kind != tokenIdent {
+		return "", p.errorf(p.tok, "expected %s, found %s", what, p.tok)
+	}
+	name := p.tok.text
+	return name, p.next()
+}
+
+// parseFile parses a file in the protocol or the schema syntax.
+func (p *parser) parseFile() error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	doc := p.tok.doc
+	props, err := p.annotations()
+	if err != nil {
+		return err
+	}
+	if p.is("protocol") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.f.Protocol, err = p.ident("protocol name"); err != nil {
+			return err
+		}
+		p.f.Doc = doc
+		if ns, ok := props["namespace"].(string); ok {
+			p.f.Namespace = ns
+		}
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		for !p.is("}") {
+			if err := p.declaration(true); err != nil {
+				return err
+			}
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.tok.kind != tokenEOF {
+			return p.errorf(p.tok, "unexpected %s after the protocol", p.tok)
+		}
+		return nil
+	}
+	if len(props) > 0 {
+		return p.errorf(p.tok, "expected \"protocol\" after annotations, found %s", p.tok)
+	}
+
+	if p.is("namespace") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.f.Namespace, err = p.ident("namespace"); err != nil {
+			return err
+		}
+		if err := p.expect(";"); err != nil {
+			return err
+		}
+	}
+	if p.is("schema") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.f.Schema, err = p.parseType(); err != nil {
+			return err
+		}
+		if err := p.expect(";"); err != nil {
+			return err
+		}
+	}
+	for p.tok.kind != tokenEOF {
+		if err := p.declaration(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// declaration parses an import, a named type declaration or, in a
+// protocol, a message, which is skipped.
+func (p *parser) declaration(inProtocol bool) error {
+	if p.is("import") {
+		return p.parseImport()
+	}
+	start := p.tok
+	props, err := p.annotations()
+	if err != nil {
+		return err
+	}
+	var def map[string]interface{}
+	switch {
+	case p.is("record") || p.is("error"):
+		def, err = p.parseRecord(props)
+	case p.is("enum"):
+		def, err = p.parseEnum(props)
+	case p.is("fixed"):
+		def, err = p.parseFixed(props)
+	case inProtocol:
+		return p.skipMessage()
+	default:
+		return p.errorf(p.tok, "expected a named type declaration, found %s", p.tok)
+	}
+	if err != nil {
+		return err
+	}
+	if start.doc != "" {
+		def["doc"] = start.doc
+	}
+	p.f.Types = append(p.f.Types, def)
+	p.f.sources = append(p.f.sources, p.f.Path)
+	return nil
+}
+
+// parseImport parses an import of an IDL, schema or protocol file.
+func (p *parser) parseImport() error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	kind := p.tok
+	if !p.is("idl") && !p.is("schema") && !p.is("protocol") {
+		return p.errorf(kind, "expected idl, schema or protocol, found %s", kind)
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	path, err := p.stringLiteral()
+	if err != nil {
+		return err
+	}
+	if err := p.load(kind.text, path); err != nil {
+		return fmt.Errorf("%d:%d: import %q: %w", kind.line, kind.col, path, err)
+	}
+	return p.expect(";")
+}
+
+// stringLiteral consumes a string literal and returns its value.
+func (p *parser) stringLiteral() (string, error) {
+	if p.tok.kind != tokenString {
+		return "", p.errorf(p.tok, "expected a string, found %s", p.tok)
+	}
+	var s string
+	if err := json.Unmarshal([]byte(p.tok.text), &s); err != nil {
+		return "", p.errorf(p.tok, "invalid string %s", p.tok.text)
+	}
+	return s, p.next()
+}
+
+// skipMessage skips a message declaration up to its closing semicolon.
+func (p *parser) skipMessage() error {
+	start := p.tok
+	depth := 0
+	for {
+		switch {
+		case p.tok.kind == tokenEOF:
+			return p.errorf(start, "unterminated message declaration")
+		case p.is("(") || p.is("{") || p.is("["):
+			depth++
+		case p.is(")") || p.is("}") || p.is("]"):
+			depth--
+		case p.is(";") && depth == 0:
+			return p.next()
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+}
+
+// annotations parses a list of annotations such as @namespace("a.b")
+// into their values by name.
+func (p *parser) annotations() (map[string]interface{}, error) {
+	props := make(map[string]interface{})
+	for p.is("@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		nameTok := p.tok
+		name, err := p.ident("annotation name")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		v, err := p.parseJSON()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if _, ok := props[name]; ok {
+			return nil, p.errorf(nameTok, "duplicate annotation @%s", name)
+		}
+		props[name] = v
+	}
+	return props, nil
+}
+
+// named returns the definition of a named type of kind typ, name and
+// annotations props, in the namespace of the file unless annotated.
+func (p *parser) named(typ, name string, props map[string]interface{}) (map[string]interface{}, error) {
+	def := map[string]interface{}{"type": typ, "name": name}
+	ns := p.f.Namespace
+	for k, v := range props {
+		switch k {
+		case "namespace":
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("@namespace of %s is not a string", name)
+			}
+			ns = s
+		case "aliases":
+			if !isStringList(v) {
+				return nil, fmt.Errorf("@aliases of %s is not a list of strings", name)
+			}
+			def[k] = v
+		default:
+			def[k] = v
+		}
+	}
+	if ns != "" && !strings.Contains(name, ".") {
+		def["namespace"] = ns
+	}
+	return def, nil
+}
+
+func isStringList(v interface{}) bool {
+	list, ok := v.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, e := range list {
+		if _, ok := e.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRecord parses a record or error declaration.
+func (p *parser) parseRecord(props map[string]interface{}) (map[string]interface{}, error) {
+	typ := p.tok.text
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	nameTok := p.tok
+	name, err := p.ident("record name")
+	if err != nil {
+		return nil, err
+	}
+	def, err := p.named(typ, name, props)
+	if err != nil {
+		return nil, p.errorf(nameTok, "%v", err)
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	fields := []interface{}{}
+	names := make(map[string]bool)
+	for !p.is("}") {
+		declared, err := p.parseFields()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range declared {
+			fieldName := f["name"].(string)
+			if names[fieldName] {
+				return nil, p.errorf(nameTok, "field %s of %s is declared twice", fieldName, name)
+			}
+			names[fieldName] = true
+			fields = append(fields, f)
+		}
+	}
+	def["fields"] = fields
+	return def, p.next()
+}
+
+// parseFields parses a field declaration, which declares one or more
+// fields of the same type.
+func (p *parser) parseFields() ([]map[string]interface{}, error) {
+	doc := p.tok.doc
+	t, optional, err := p.parseOptionalType()
+	if err != nil {
+		return nil, err
+	}
+	var fields []map[string]interface{}
+	for {
+		field := map[string]interface{}{}
+		props, err := p.annotations()
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range props {
+			if k == "aliases" && !isStringList(v) {
+				return nil, p.errorf(p.tok, "@aliases is not a list of strings")
+			}
+			field[k] = v
+		}
+		if field["name"], err = p.ident("field name"); err != nil {
+			return nil, err
+		}
+		fieldType := t
+		if p.is("=") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			def, err := p.parseJSON()
+			if err != nil {
+				return nil, err
+			}
+			field["default"] = def
+			if optional && def != nil {
+				// An optional type with a non-null default lists null last.
+				fieldType = []interface{}{t.([]interface{})[1], "null"}
+			}
+		}
+		field["type"] = fieldType
+		if doc != "" {
+			field["doc"] = doc
+		}
+		fields = append(fields, field)
+		if !p.is(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return fields, p.expect(";")
+}
+
+// parseEnum parses an enum declaration and its optional default.
+func (p *parser) parseEnum(props map[string]interface{}) (map[string]interface{}, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	nameTok := p.tok
+	name, err := p.ident("enum name")
+	if err != nil {
+		return nil, err
+	}
+	def, err := p.named("enum", name, props)
+	if err != nil {
+		return nil, p.errorf(nameTok, "%v", err)
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	symbols := []interface{}{}
+	seen := make(map[string]bool)
+	for !p.is("}") {
+		symTok := p.tok
+		symbol, err := p.ident("enum symbol")
+		if err != nil {
+			return nil, err
+		}
+		if seen[symbol] {
+			return nil, p.errorf(symTok, "symbol %s of %s is declared twice", symbol, name)
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+		if !p.is(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	def["symbols"] = symbols
+	if p.is("=") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		defTok := p.tok
+		symbol, err := p.ident("enum default")
+		if err != nil {
+			return nil, err
+		}
+		if !seen[symbol] {
+			return nil, p.errorf(defTok, "default %s is not a symbol of %s", symbol, name)
+		}
+		def["default"] = symbol
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+	}
+	return def, nil
+}
+
+// parseFixed parses a fixed declaration.
+func (p *parser) parseFixed(props map[string]interface{}) (map[string]interface{}, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	nameTok := p.tok
+	name, err := p.ident("fixed name")
+	if err != nil {
+		return nil, err
+	}
+	def, err := p.named("fixed", name, props)
+	if err != nil {
+		return nil, p.errorf(nameTok, "%v", err)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	size, err := p.integer()
+	if err != nil {
+		return nil, err
+	}
+	def["size"] = float64(size)
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return def, p.expect(";")
+}
+
+// integer consumes a non-negative integer literal.
+func (p *parser) integer() (int, error) {
+	n, err := strconv.Atoi(p.tok.text)
+	if p.tok.kind != tokenNumber || err != nil || n < 0 {
+		return 0, p.errorf(p.tok, "expected a non-negative integer, found %s", p.tok)
+	}
+	return n, p.next()
+}
+
+// parseType parses a type with its annotations, optionally followed by
+// "?" for a union with null.
+func (p *parser) parseType() (interface{}, error) {
+	t, _, err := p.parseOptionalType()
+	return t, err
+}
+
+// parseOptionalType is parseType, also reporting whether the type was
+// made optional with "?".
+func (p *parser) parseOptionalType() (interface{}, bool, error) {
+	props, err := p.annotations()
+	if err != nil {
+		return nil, false, err
+	}
+	t, err := p.parseBareType()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(props) > 0 {
+		switch bare := t.(type) {
+		case string:
+			wrapped := map[string]interface{}{"type": bare}
+			for k, v := range props {
+				wrapped[k] = v
+			}
+			t = wrapped
+		case map[string]interface{}:
+			for k, v := range props {
+				bare[k] = v
+			}
+		case []interface{}:
+			return nil, false, p.errorf(p.tok, "unions cannot be annotated")
+		}
+	}
+	if p.is("?") {
+		if _, ok := t.([]interface{}); ok {
+			return nil, false, p.errorf(p.tok, "a union cannot be made optional")
+		}
+		return []interface{}{"null", t}, true, p.next()
+	}
+	return t, false, nil
+}
+
+// parseBareType parses a type without annotations.
+func (p *parser) parseBareType() (interface{}, error) {
+	t := p.tok
+	if t.kind != tokenIdent {
+		return nil, p.errorf(t, "expected a type, found %s", t)
+	}
+	if t.quoted {
+		return t.text, p.next()
+	}
+	switch t.text {
+	case "array", "map":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		nested, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		key := "items"
+		if t.text == "map" {
+			key = "values"
+		}
+		return map[string]interface{}{"type": t.text, key: nested}, p.expect(">")
+	case "union":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		branches := []interface{}{}
+		for {
+			branch, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := branch.([]interface{}); ok {
+				return nil, p.errorf(t, "unions cannot be nested")
+			}
+			branches = append(branches, branch)
+			if !p.is(",") {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		return branches, p.expect("}")
+	case "decimal":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		precision, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		scale, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		if precision == 0 || scale > precision {
+			return nil, p.errorf(t, "invalid decimal(%d,%d)", precision, scale)
+		}
+		return map[string]interface{}{
+			"type": "bytes", "logicalType": "decimal",
+			"precision": float64(precision), "scale": float64(scale),
+		}, p.expect(")")
+	case "record", "error", "enum", "fixed", "protocol", "import", "void":
+		return nil, p.errorf(t, "expected a type, found %s", t)
+	}
+	if logical, ok := logicalTypes[t.text]; ok {
+		return copyMap(logical), p.next()
+	}
+	return t.text, p.next()
+}
+
+// parseJSON parses a JSON value, as in defaults and annotations. Numbers
+// are float64, as from encoding/json.
+func (p *parser) parseJSON() (interface{}, error) {
+	t := p.tok
+	switch {
+	case t.kind == tokenString:
+		return p.stringLiteral()
+	case t.kind == tokenNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %s", t.text)
+		}
+		return f, p.next()
+	case p.is("true"):
+		return true, p.next()
+	case p.is("false"):
+		return false, p.next()
+	case p.is("null"):
+		return nil, p.next()
+	case p.is("["):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.is("]") {
+			v, err := p.parseJSON()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if !p.is(",") {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		return list, p.expect("]")
+	case p.is("{"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		for !p.is("}") {
+			key, err := p.stringLiteral()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[key], err = p.parseJSON(); err != nil {
+				return nil, err
+			}
+			if !p.is(",") {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.expect("}")
+	}
+	return nil, p.errorf(t, "expected a JSON value, found %s", t)
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
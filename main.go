@@ -6,12 +6,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 
 	"github.com/go-syar/avro-schema-bq/schema"
 )
 
 // main is the entry point of the program.
 func main() {
+	// Dispatch to a subcommand when one is given.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "apply":
+			os.Exit(runApply(os.Args[2:]))
+		case "drift":
+			os.Exit(runDrift(os.Args[2:]))
+		case "convert":
+			os.Exit(runConvert(os.Args[2:]))
+		case "rows":
+			os.Exit(runRows(os.Args[2:]))
+		}
+	}
+
 	// schemaFilePath contains the path to the Avro schema file.
 	schemaFilePath := "schema/test_data/testfile.avsc"
 
@@ -0,0 +1,117 @@
+package ocf
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// maxBlockSize bounds the size of a data block, compressed and
+// uncompressed, so that a corrupt size or a decompression bomb does not
+// exhaust memory.
+const maxBlockSize = 256 << 20
+
+// decompressor decompresses the data of a block.
+type decompressor func(data []byte) ([]byte, error)
+
+var codecs = map[string]decompressor{
+	"null":      func(data []byte) ([]byte, error) { return data, nil },
+	"deflate":   decompressDeflate,
+	"snappy":    decompressSnappy,
+	"zstandard": decompressZstandard,
+	"bzip2":     decompressBzip2,
+	"xz":        decompressXZ,
+}
+
+// Codecs returns the names of the supported codecs, sorted.
+func Codecs() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func decompressorOf(codec string) (decompressor, error) {
+	d, ok := codecs[codec]
+	if !ok {
+		return nil, fmt.Errorf("unsupported codec %q", codec)
+	}
+	return d, nil
+}
+
+// readAllLimited reads r to the end, failing when it holds more than
+// maxBlockSize bytes.
+func readAllLimited(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxBlockSize {
+		return nil, fmt.Errorf("uncompressed block exceeds %d bytes", maxBlockSize)
+	}
+	return b, nil
+}
+
+// decompressDeflate decompresses raw deflate data, without zlib header.
+func decompressDeflate(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return readAllLimited(r)
+}
+
+// decompressSnappy decompresses a snappy block followed by the big-endian
+// CRC-32 of the uncompressed data.
+func decompressSnappy(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errors.New("snappy block has no checksum")
+	}
+	compressed, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxBlockSize {
+		return nil, fmt.Errorf("uncompressed block exceeds %d bytes", maxBlockSize)
+	}
+	b, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(b) != checksum {
+		return nil, errors.New("snappy checksum mismatch")
+	}
+	return b, nil
+}
+
+func decompressZstandard(data []byte) ([]byte, error) {
+	r, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxBlockSize))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readAllLimited(r)
+}
+
+func decompressBzip2(data []byte) ([]byte, error) {
+	return readAllLimited(bzip2.NewReader(bytes.NewReader(data)))
+}
+
+func decompressXZ(data []byte) ([]byte, error) {
+	r, err := xz.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return readAllLimited(r)
+}
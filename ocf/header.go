@@ -0,0 +1,211 @@
+// Package ocf reads Avro Object Container Files, the .avro data files that
+// embed the schema of their records in a header.
+package ocf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Magic starts every Avro Object Container File.
+var Magic = [4]byte{'O', 'b', 'j', 1}
+
+// SyncSize is the length of the sync marker that ends the header and every
+// data block.
+const SyncSize = 16
+
+// Reserved metadata keys.
+const (
+	MetaSchema = "avro.schema"
+	MetaCodec  = "avro.codec"
+)
+
+// maxMetaSize bounds the size of a single metadata value, so that a
+// corrupt length does not exhaust memory.
+const maxMetaSize = 64 << 20
+
+// Header is the header of an Object Container File.
+type Header struct {
+	// Meta holds the file metadata, including avro.schema and avro.codec.
+	Meta map[string][]byte
+	// Sync is the marker written after every data block.
+	Sync [SyncSize]byte
+}
+
+// IsContainer reports whether data starts with the magic of an Object
+// Container File.
+func IsContainer(data []byte) bool {
+	return bytes.HasPrefix(data, Magic[:])
+}
+
+// ReadHeader reads the header of an Object Container File from r: the
+// magic, the metadata map and the sync marker. It reads no further than
+// the end of the header, so the data blocks can be read from r afterwards.
+func ReadHeader(r io.Reader) (*Header, error) {
+	br := asByteReader(r)
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return nil, fmt.Errorf("reading magic: %w", err)
+	}
+	if magic != Magic {
+		return nil, errors.New("not an Avro object container file")
+	}
+
+	h := &Header{Meta: make(map[string][]byte)}
+	for {
+		count, err := readLong(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading metadata: %w", err)
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			// A negative count is followed by the size of the block in bytes.
+			count = -count
+			if _, err := readLong(br); err != nil {
+				return nil, fmt.Errorf("reading metadata: %w", err)
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			key, err := readBytes(br)
+			if err != nil {
+				return nil, fmt.Errorf("reading metadata key: %w", err)
+			}
+			value, err := readBytes(br)
+			if err != nil {
+				return nil, fmt.Errorf("reading metadata %s: %w", key, err)
+			}
+			h.Meta[string(key)] = value
+		}
+	}
+	if _, err := io.ReadFull(br, h.Sync[:]); err != nil {
+		return nil, fmt.Errorf("reading sync marker: %w", err)
+	}
+	if _, ok := h.Meta[MetaSchema]; !ok {
+		return nil, fmt.Errorf("header has no %s", MetaSchema)
+	}
+	return h, nil
+}
+
+// Codec returns the compression codec of the data blocks, "null" when the
+// header does not name one.
+func (h *Header) Codec() string {
+	if codec, ok := h.Meta[MetaCodec]; ok && len(codec) > 0 {
+		return string(codec)
+	}
+	return "null"
+}
+
+// Schema parses the writer schema stored in the header. Only record
+// schemas, which map to BigQuery tables, are accepted.
+func (h *Header) Schema() (map[string]interface{}, error) {
+	var avroSchema interface{}
+	if err := json.Unmarshal(h.Meta[MetaSchema], &avroSchema); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", MetaSchema, err)
+	}
+	record, ok := avroSchema.(map[string]interface{})
+	if !ok || (record["type"] != "record" && record["type"] != "error") {
+		return nil, fmt.Errorf("%s is not a record schema", MetaSchema)
+	}
+	return record, nil
+}
+
+// ReadFileHeader reads the header of the Object Container File at path.
+func ReadFileHeader(path string) (*Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h, err := ReadHeader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return h, nil
+}
+
+// IsContainerFile reports whether the file at path starts with the magic
+// of an Object Container File.
+func IsContainerFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var magic [len(Magic)]byte
+	n, err := io.ReadFull(f, magic[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return IsContainer(magic[:n]), nil
+}
+
+// byteReader reads one byte at a time from a reader that is not an
+// io.ByteReader, so that nothing past the header is consumed.
+type byteReader struct {
+	io.Reader
+	buf [1]byte
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r.Reader, r.buf[:]); err != nil {
+		return 0, err
+	}
+	return r.buf[0], nil
+}
+
+type readByteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func asByteReader(r io.Reader) readByteReader {
+	if br, ok := r.(readByteReader); ok {
+		return br
+	}
+	return &byteReader{Reader: r}
+}
+
+// readLong reads a zig-zag encoded variable-length long.
+func readLong(r io.ByteReader) (int64, error) {
+	var u uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && shift > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		u |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+	}
+	return 0, errors.New("long is longer than 10 bytes")
+}
+
+// readBytes reads a long length followed by that many bytes.
+func readBytes(r readByteReader) ([]byte, error) {
+	n, err := readLong(r)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > maxMetaSize {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
@@ -0,0 +1,125 @@
+package ocf
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// appendLong appends the zig-zag varint encoding of n.
+func appendLong(b []byte, n int64) []byte {
+	u := uint64(n<<1) ^ uint64(n>>63)
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
+
+func appendBytes(b, data []byte) []byte {
+	return append(appendLong(b, int64(len(data))), data...)
+}
+
+// testHeader encodes a header with the given metadata in one block, with a
+// negative count and byte size when sized is set.
+func testHeader(meta map[string]string, sized bool, sync []byte) []byte {
+	var block []byte
+	for k, v := range meta {
+		block = appendBytes(block, []byte(k))
+		block = appendBytes(block, []byte(v))
+	}
+	b := append([]byte(nil), Magic[:]...)
+	if sized {
+		b = appendLong(b, -int64(len(meta)))
+		b = appendLong(b, int64(len(block)))
+	} else {
+		b = appendLong(b, int64(len(meta)))
+	}
+	b = append(b, block...)
+	b = appendLong(b, 0)
+	return append(b, sync...)
+}
+
+const testSchema = `{"type": "record", "name": "User", "fields": [{"name": "id", "type": "long"}]}`
+
+func TestReadHeader(t *testing.T) {
+	sync := []byte("0123456789abcdef")
+	for _, sized := range []bool{false, true} {
+		data := testHeader(map[string]string{MetaSchema: testSchema, MetaCodec: "deflate", "app": "x"}, sized, sync)
+		data = append(data, "block"...)
+		r := bytes.NewReader(data)
+
+		h, err := ReadHeader(r)
+		if err != nil {
+			t.Fatalf("Error reading header: %v", err)
+		}
+		if h.Codec() != "deflate" || string(h.Meta["app"]) != "x" || !bytes.Equal(h.Sync[:], sync) {
+			t.Fatalf("Unexpected header: %+v", h)
+		}
+		rest, _ := io.ReadAll(r)
+		if string(rest) != "block" {
+			t.Fatalf("Expected the reader to stop after the header, but %q is left", rest)
+		}
+		s, err := h.Schema()
+		if err != nil || s["name"] != "User" {
+			t.Fatalf("Expected the User schema, but got %v (%v)", s, err)
+		}
+	}
+
+	t.Run("default codec", func(t *testing.T) {
+		h, err := ReadHeader(bytes.NewReader(testHeader(map[string]string{MetaSchema: testSchema}, false, sync)))
+		if err != nil || h.Codec() != "null" {
+			t.Fatalf("Expected the null codec, got %+v (%v)", h, err)
+		}
+	})
+
+	invalid := map[string][]byte{
+		"bad magic":        append([]byte("Obj\x02"), testHeader(map[string]string{MetaSchema: testSchema}, false, sync)[4:]...),
+		"no schema":        testHeader(map[string]string{"app": "x"}, false, sync),
+		"truncated sync":   testHeader(map[string]string{MetaSchema: testSchema}, false, sync[:8]),
+		"truncated value":  testHeader(map[string]string{MetaSchema: testSchema}, false, nil)[:20],
+		"negative length":  appendLong(appendLong(Magic[:], 1), -5),
+		"overlong varint":  append(Magic[:], bytes.Repeat([]byte{0xff}, 11)...),
+		"non-record":       testHeader(map[string]string{MetaSchema: `"string"`}, false, sync),
+		"malformed schema": testHeader(map[string]string{MetaSchema: `{`}, false, sync),
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			h, err := ReadHeader(bytes.NewReader(data))
+			if err == nil {
+				_, err = h.Schema()
+			}
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestReadFileHeader(t *testing.T) {
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "users.avro")
+	if err := os.WriteFile(dataPath, testHeader(map[string]string{MetaSchema: testSchema}, false, make([]byte, SyncSize)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	schemaPath := filepath.Join(dir, "users.avsc")
+	if err := os.WriteFile(schemaPath, []byte(testSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := IsContainerFile(dataPath); err != nil || !ok {
+		t.Fatalf("Expected %s to be a container file, got %v (%v)", dataPath, ok, err)
+	}
+	if ok, err := IsContainerFile(schemaPath); err != nil || ok {
+		t.Fatalf("Expected %s not to be a container file, got %v (%v)", schemaPath, ok, err)
+	}
+	h, err := ReadFileHeader(dataPath)
+	if err != nil {
+		t.Fatalf("Error reading header: %v", err)
+	}
+	if s, err := h.Schema(); err != nil || s["name"] != "User" {
+		t.Fatalf("Expected the User schema, but got %v (%v)", s, err)
+	}
+}
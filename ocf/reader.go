@@ -0,0 +1,364 @@
+package ocf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/row"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// ReaderOptions configure how a Reader turns records into rows.
+type ReaderOptions struct {
+	// Convert holds the options converting the writer schema to the
+	// BigQuery schema of the rows. Flattening and normalization are not
+	// supported.
+	Convert schema.ConvertOptions
+	// Schema, when set, is the BigQuery schema of the rows instead of the
+	// converted one, such as the schema of an existing table. Convert
+	// still applies its naming strategy and sanitization to the writer
+	// schema so that fields match the columns.
+	Schema bigquery.Schema
+	// SkipCorrupt skips corrupt blocks instead of failing: reading resumes
+	// at the next sync marker, and the skipped blocks are reported by
+	// Reader.Corruptions.
+	SkipCorrupt bool
+}
+
+// CorruptBlockError reports a data block that could not be read: a block
+// with an invalid count or size, a missing sync marker, data that does
+// not decompress, or records that do not decode.
+type CorruptBlockError struct {
+	// Offset is the offset of the block in the file.
+	Offset int64
+	Err    error
+}
+
+func (e *CorruptBlockError) Error() string {
+	return fmt.Sprintf("corrupt block at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *CorruptBlockError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the records of an Object Container File as BigQuery rows.
+type Reader struct {
+	// Header is the header of the file.
+	Header *Header
+
+	opts       ReaderOptions
+	src        *source
+	closer     io.Closer
+	schema     bigquery.Schema
+	decoder    *row.Decoder
+	decompress decompressor
+
+	// block holds the undecoded data of the current block, of which
+	// remaining records are left. blockOffset is the offset of the block.
+	block       []byte
+	remaining   int64
+	blockOffset int64
+
+	corruptions []*CorruptBlockError
+	err         error
+}
+
+// NewReader reads the header of the Object Container File read from r
+// and returns a reader of its records. The writer schema in the header
+// must be a record schema; rows follow its conversion with opts.Convert,
+// or opts.Schema when set.
+func NewReader(r io.Reader, opts ReaderOptions) (*Reader, error) {
+	src := &source{r: bufio.NewReader(r)}
+	h, err := ReadHeader(src)
+	if err != nil {
+		return nil, err
+	}
+	decompress, err := decompressorOf(h.Codec())
+	if err != nil {
+		return nil, err
+	}
+	avroSchema, err := h.Schema()
+	if err != nil {
+		return nil, err
+	}
+	c, err := schema.ConvertAvroToBigQueryWithOptions(avroSchema, opts.Convert)
+	if err != nil {
+		return nil, err
+	}
+	var d *row.Decoder
+	s := opts.Schema
+	if s != nil {
+		d, err = row.NewDecoder(c.AvroSchema, s)
+	} else {
+		s = c.Schema
+		d, err = row.NewConversionDecoder(c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		Header:     h,
+		opts:       opts,
+		src:        src,
+		schema:     s,
+		decoder:    d,
+		decompress: decompress,
+	}, nil
+}
+
+// OpenFile opens the Object Container File at path for reading. The
+// reader must be closed.
+func OpenFile(path string, opts ReaderOptions) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f, opts)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r.closer = f
+	return r, nil
+}
+
+// Close closes the file of a reader returned by OpenFile. It does nothing
+// for readers returned by NewReader.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Schema returns the BigQuery schema of the rows.
+func (r *Reader) Schema() bigquery.Schema {
+	return r.schema
+}
+
+// Corruptions returns the corrupt blocks skipped so far with
+// ReaderOptions.SkipCorrupt.
+func (r *Reader) Corruptions() []*CorruptBlockError {
+	return r.corruptions
+}
+
+// Read returns the next row, or io.EOF after the last one. Without
+// ReaderOptions.SkipCorrupt, a corrupt block ends reading with a
+// *CorruptBlockError; the rows of the block read before the corruption
+// was found have been returned already.
+func (r *Reader) Read() (row.Row, error) {
+	for r.err == nil {
+		if r.remaining == 0 {
+			if len(r.block) > 0 {
+				r.corrupt(fmt.Errorf("%d bytes after the last record", len(r.block)), false)
+				continue
+			}
+			r.nextBlock()
+			continue
+		}
+		rw, n, err := r.decoder.DecodeNext(r.block)
+		if err != nil {
+			r.corrupt(fmt.Errorf("decoding record: %w", err), false)
+			continue
+		}
+		r.block = r.block[n:]
+		r.remaining--
+		return rw, nil
+	}
+	return nil, r.err
+}
+
+// ReadAll reads the remaining rows.
+func (r *Reader) ReadAll() ([]row.Row, error) {
+	var rows []row.Row
+	for {
+		rw, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, rw)
+	}
+}
+
+// nextBlock reads and decompresses the next data block, setting r.err at
+// the end of the file or on a read error.
+func (r *Reader) nextBlock() {
+	r.blockOffset = r.src.offset
+	r.src.record()
+
+	count, err := readLong(r.src)
+	if err == io.EOF {
+		r.err = io.EOF
+		return
+	}
+	if err != nil {
+		r.readError(err)
+		return
+	}
+	size, err := readLong(r.src)
+	if err != nil {
+		r.readError(err)
+		return
+	}
+	if count < 0 || size < 0 || size > maxBlockSize {
+		r.corrupt(fmt.Errorf("invalid block count %d and size %d", count, size), true)
+		return
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.src, data); err != nil {
+		r.readError(err)
+		return
+	}
+	var sync [SyncSize]byte
+	if _, err := io.ReadFull(r.src, sync[:]); err != nil {
+		r.readError(err)
+		return
+	}
+	if sync != r.Header.Sync {
+		r.corrupt(errors.New("sync marker mismatch"), true)
+		return
+	}
+	r.src.stop()
+	block, err := r.decompress(data)
+	if err != nil {
+		r.corrupt(fmt.Errorf("%s: %w", r.Header.Codec(), err), false)
+		return
+	}
+	r.block, r.remaining = block, count
+}
+
+// readError handles an error reading a block: a truncated block is
+// corrupt, other errors end reading.
+func (r *Reader) readError(err error) {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		r.corrupt(io.ErrUnexpectedEOF, true)
+		return
+	}
+	r.src.stop()
+	r.err = err
+}
+
+// corrupt reports the current block as corrupt. When corrupt blocks are
+// skipped, the rest of the block is dropped and, when its framing is
+// broken, reading resumes after the next sync marker.
+func (r *Reader) corrupt(err error, framing bool) {
+	e := &CorruptBlockError{Offset: r.blockOffset, Err: err}
+	r.block, r.remaining = nil, 0
+	if !r.opts.SkipCorrupt {
+		r.src.stop()
+		r.err = e
+		return
+	}
+	r.corruptions = append(r.corruptions, e)
+	if framing {
+		if err := r.resync(); err != nil {
+			r.err = err
+		}
+	}
+}
+
+// resync moves past the next sync marker after the start of the current
+// block. The bytes read for the block are searched first, since a corrupt
+// size may have swallowed the blocks that follow it.
+func (r *Reader) resync() error {
+	read := r.src.stop()
+	if len(read) > 0 {
+		read = read[1:]
+	}
+	if i := bytes.Index(read, r.Header.Sync[:]); i >= 0 {
+		r.src.unread(read[i+SyncSize:])
+		return nil
+	}
+	var window []byte
+	if len(read) >= SyncSize {
+		window = append(window, read[len(read)-SyncSize+1:]...)
+	} else {
+		window = append(window, read...)
+	}
+	for {
+		b, err := r.src.ReadByte()
+		if err != nil {
+			return err
+		}
+		window = append(window, b)
+		if len(window) > SyncSize {
+			window = window[len(window)-SyncSize:]
+		}
+		if bytes.Equal(window, r.Header.Sync[:]) {
+			return nil
+		}
+	}
+}
+
+// source reads a file, tracking the offset of the next byte. It can
+// record the bytes read and push bytes back, so that the bytes of a
+// corrupt block can be searched for a sync marker and read again.
+type source struct {
+	r         *bufio.Reader
+	offset    int64
+	pending   []byte
+	recording bool
+	recorded  []byte
+}
+
+func (s *source) Read(p []byte) (int, error) {
+	var n int
+	var err error
+	if len(s.pending) > 0 {
+		n = copy(p, s.pending)
+		s.pending = s.pending[n:]
+	} else {
+		n, err = s.r.Read(p)
+	}
+	s.offset += int64(n)
+	if s.recording {
+		s.recorded = append(s.recorded, p[:n]...)
+	}
+	return n, err
+}
+
+func (s *source) ReadByte() (byte, error) {
+	var b byte
+	if len(s.pending) > 0 {
+		b = s.pending[0]
+		s.pending = s.pending[1:]
+	} else {
+		var err error
+		if b, err = s.r.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	s.offset++
+	if s.recording {
+		s.recorded = append(s.recorded, b)
+	}
+	return b, nil
+}
+
+// record starts recording the bytes read.
+func (s *source) record() {
+	s.recording, s.recorded = true, s.recorded[:0]
+}
+
+// stop stops recording and returns the bytes recorded. They are valid
+// until the next call to record.
+func (s *source) stop() []byte {
+	s.recording = false
+	return s.recorded
+}
+
+// unread pushes back b, which must be the last bytes read, to be read
+// again.
+func (s *source) unread(b []byte) {
+	s.pending = append(append([]byte(nil), b...), s.pending...)
+	s.offset -= int64(len(b))
+}
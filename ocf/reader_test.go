@@ -0,0 +1,221 @@
+package ocf
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// bzip2Records is the bzip2 compression of the records with ids 1, 2 and
+// 3, since the standard library has no bzip2 writer.
+const bzip2Records = "425a68393141592653591488b7ea0000004000150020002198198461772453850901488b7ea0"
+
+func compress(t *testing.T, codec string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch codec {
+	case "null":
+		return data
+	case "deflate":
+		w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		w.Write(data)
+		w.Close()
+	case "snappy":
+		var checksum [4]byte
+		binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(data))
+		return append(snappy.Encode(nil, data), checksum[:]...)
+	case "zstandard":
+		w, _ := zstd.NewWriter(nil)
+		defer w.Close()
+		return w.EncodeAll(data, nil)
+	case "bzip2":
+		if !bytes.Equal(data, []byte{2, 4, 6}) {
+			t.Fatalf("No bzip2 data for %x", data)
+		}
+		b, _ := hex.DecodeString(bzip2Records)
+		return b
+	case "xz":
+		w, err := xz.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+		w.Close()
+	default:
+		t.Fatalf("Unknown codec %s", codec)
+	}
+	return buf.Bytes()
+}
+
+var testSync = []byte("0123456789abcdef")
+
+// testBlock encodes a data block of the records with the given ids.
+func testBlock(t *testing.T, codec string, ids ...int64) []byte {
+	var data []byte
+	for _, id := range ids {
+		data = appendLong(data, id)
+	}
+	data = compress(t, codec, data)
+	b := appendLong(nil, int64(len(ids)))
+	b = appendLong(b, int64(len(data)))
+	return append(append(b, data...), testSync...)
+}
+
+func testFile(codec string, blocks ...[]byte) []byte {
+	b := testHeader(map[string]string{MetaSchema: testSchema, MetaCodec: codec}, false, testSync)
+	for _, block := range blocks {
+		b = append(b, block...)
+	}
+	return b
+}
+
+// readIDs reads the ids of the rows of data up to the first error.
+func readIDs(t *testing.T, data []byte, opts ReaderOptions) ([]int64, *Reader, error) {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatalf("Error creating reader: %v", err)
+	}
+	rows, err := r.ReadAll()
+	var ids []int64
+	for _, rw := range rows {
+		ids = append(ids, rw["id"].(int64))
+	}
+	return ids, r, err
+}
+
+func TestReaderCodecs(t *testing.T) {
+	for _, codec := range Codecs() {
+		t.Run(codec, func(t *testing.T) {
+			ids, r, err := readIDs(t, testFile(codec, testBlock(t, codec, 1, 2, 3)), ReaderOptions{})
+			if err != nil {
+				t.Fatalf("Error reading rows: %v", err)
+			}
+			if !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+				t.Fatalf("Expected ids 1, 2 and 3, but got %v", ids)
+			}
+			if len(r.Corruptions()) != 0 {
+				t.Fatalf("Expected no corruption, but got %v", r.Corruptions())
+			}
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		if _, err := NewReader(bytes.NewReader(testFile("lzo")), ReaderOptions{}); err == nil {
+			t.Fatalf("Expected an error for an unsupported codec")
+		}
+	})
+	t.Run("snappy checksum", func(t *testing.T) {
+		block := testBlock(t, "snappy", 1)
+		block[len(block)-SyncSize-1] ^= 0xff
+		if _, _, err := readIDs(t, testFile("snappy", block), ReaderOptions{}); err == nil {
+			t.Fatalf("Expected a checksum error")
+		}
+	})
+}
+
+func TestReaderCorruption(t *testing.T) {
+	first, middle, last := testBlock(t, "null", 1, 2), testBlock(t, "null", 4, 5), testBlock(t, "null", 6, 7)
+	corrupt := map[string][]byte{
+		// The sync marker of the block is damaged.
+		"sync marker": func() []byte {
+			b := testBlock(t, "null", 3)
+			b[len(b)-1] ^= 0xff
+			return b
+		}(),
+		// The size covers the rest of the file.
+		"size": func() []byte {
+			b := testBlock(t, "null", 3)
+			b[1] = 0x7e
+			return b
+		}(),
+		// The block holds fewer records than its count.
+		"record": append(appendLong(appendLong(nil, 2), 1), append([]byte{6}, testSync...)...),
+		// The block holds more data than its records.
+		"trailing data": append(appendLong(appendLong(nil, 1), 2), append([]byte{6, 6}, testSync...)...),
+	}
+	for name, block := range corrupt {
+		t.Run(name, func(t *testing.T) {
+			data := testFile("null", first, block, middle, last)
+
+			ids, _, err := readIDs(t, data, ReaderOptions{})
+			var corruptErr *CorruptBlockError
+			if !errors.As(err, &corruptErr) || corruptErr.Offset != int64(len(testFile("null", first))) {
+				t.Fatalf("Expected a corrupt block error at the second block, but got %v", err)
+			}
+			if len(ids) < 2 || !reflect.DeepEqual(ids[:2], []int64{1, 2}) {
+				t.Fatalf("Expected the rows of the first block, but got %v", ids)
+			}
+
+			ids, r, err := readIDs(t, data, ReaderOptions{SkipCorrupt: true})
+			if err != nil {
+				t.Fatalf("Error reading rows: %v", err)
+			}
+			// Reading resumes after the next intact sync marker, which
+			// ends the middle block when the corrupt one lost its own.
+			if ids[len(ids)-2] != 6 || ids[len(ids)-1] != 7 {
+				t.Fatalf("Expected reading to resume before the last block, but got %v", ids)
+			}
+			if len(r.Corruptions()) != 1 {
+				t.Fatalf("Expected one corruption, but got %v", r.Corruptions())
+			}
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		data := testFile("null", first, last)
+		ids, r, err := readIDs(t, data[:len(data)-4], ReaderOptions{SkipCorrupt: true})
+		if err != nil {
+			t.Fatalf("Error reading rows: %v", err)
+		}
+		if !reflect.DeepEqual(ids, []int64{1, 2}) || len(r.Corruptions()) != 1 {
+			t.Fatalf("Expected the rows of the first block and one corruption, but got %v and %v", ids, r.Corruptions())
+		}
+	})
+}
+
+func TestReaderOptions(t *testing.T) {
+	data := testFile("null", testBlock(t, "null", 7))
+	s := bigquery.Schema{{Name: "ID", Type: bigquery.IntegerFieldType}}
+	r, err := NewReader(bytes.NewReader(data), ReaderOptions{Schema: s, Convert: schema.ConvertOptions{Naming: schema.NamingSnakeCase}})
+	if err != nil {
+		t.Fatalf("Error creating reader: %v", err)
+	}
+	if !reflect.DeepEqual(r.Schema(), s) {
+		t.Fatalf("Expected the given schema, but got %v", r.Schema())
+	}
+	rw, err := r.Read()
+	if err != nil || rw["ID"] != int64(7) {
+		t.Fatalf("Expected a row with ID 7, but got %v (%v)", rw, err)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("Expected io.EOF, but got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "users.avro")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFile(path, ReaderOptions{})
+	if err != nil {
+		t.Fatalf("Error opening file: %v", err)
+	}
+	defer f.Close()
+	if rows, err := f.ReadAll(); err != nil || len(rows) != 1 || rows[0]["id"] != int64(7) {
+		t.Fatalf("Expected a row with id 7, but got %v (%v)", rows, err)
+	}
+}
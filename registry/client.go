@@ -0,0 +1,301 @@
+// Package registry is a client of the Confluent Schema Registry REST API,
+// used to convert schemas straight from the registry.
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// DefaultTimeout bounds every registry request when Config.Timeout is
+// zero.
+const DefaultTimeout = 30 * time.Second
+
+// Latest is the version of the most recent schema of a subject.
+const Latest = "latest"
+
+// Config configures a Client.
+type Config struct {
+	// URL is the base URL of the registry, such as
+	// https://registry.example.com:8081.
+	URL string
+	// Username and Password enable HTTP basic authentication.
+	Username string
+	Password string
+	// TLS configures HTTPS connections, e.g. with TLSConfig; nil uses the
+	// system roots.
+	TLS *tls.Config
+	// Timeout bounds every request; zero means DefaultTimeout.
+	Timeout time.Duration
+}
+
+// TLSConfig returns a TLS configuration trusting the PEM certificates in
+// caFile in addition to the system roots, and presenting the client
+// certificate in certFile and keyFile. Every argument is optional.
+func TLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s holds no PEM certificate", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// Client reads subjects and schemas from a schema registry.
+type Client struct {
+	base     *url.URL
+	http     *http.Client
+	username string
+	password string
+}
+
+// NewClient returns a client of the registry configured by cfg.
+func NewClient(cfg Config) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("registry URL %q is not an http or https URL", cfg.URL)
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLS != nil {
+		transport.TLSClientConfig = cfg.TLS
+	}
+	return &Client{
+		base:     base,
+		http:     &http.Client{Transport: transport, Timeout: timeout},
+		username: cfg.Username,
+		password: cfg.Password,
+	}, nil
+}
+
+// Reference is a reference of a schema to a named type registered under
+// another subject.
+type Reference struct {
+	// Name is the full name of the referenced type.
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Schema is a schema registered in the registry. Subject and Version are
+// only set for schemas read by subject.
+type Schema struct {
+	Subject    string      `json:"subject,omitempty"`
+	Version    int         `json:"version,omitempty"`
+	ID         int         `json:"id,omitempty"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	Schema     string      `json:"schema"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// Error is an error response of the registry.
+type Error struct {
+	StatusCode int
+	// Code is the registry error code, such as 40401 for an unknown
+	// subject.
+	Code    int    `json:"error_code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("schema registry: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("schema registry: %s (error %d)", e.Message, e.Code)
+}
+
+// Subjects returns the registered subjects.
+func (c *Client) Subjects(ctx context.Context) ([]string, error) {
+	var subjects []string
+	err := c.get(ctx, []string{"subjects"}, &subjects)
+	return subjects, err
+}
+
+// Versions returns the registered versions of subject.
+func (c *Client) Versions(ctx context.Context, subject string) ([]int, error) {
+	var versions []int
+	err := c.get(ctx, []string{"subjects", subject, "versions"}, &versions)
+	return versions, err
+}
+
+// SchemaVersion returns the schema of subject at version, a version
+// number or Latest.
+func (c *Client) SchemaVersion(ctx context.Context, subject, version string) (*Schema, error) {
+	if version != Latest {
+		if n, err := strconv.Atoi(version); err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid version %q: want a positive number or %q", version, Latest)
+		}
+	}
+	var s Schema
+	if err := c.get(ctx, []string{"subjects", subject, "versions", version}, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Schema returns the schema registered with ID id.
+func (c *Client) Schema(ctx context.Context, id int) (*Schema, error) {
+	var s Schema
+	if err := c.get(ctx, []string{"schemas", "ids", strconv.Itoa(id)}, &s); err != nil {
+		return nil, err
+	}
+	s.ID = id
+	return &s, nil
+}
+
+// SchemaByID returns the Avro record schema registered with ID id, with
+// its references resolved (see Resolve). It makes a Client a
+// confluent.SchemaSource.
+func (c *Client) SchemaByID(ctx context.Context, id int) (map[string]interface{}, error) {
+	s, err := c.Schema(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return c.Resolve(ctx, s)
+}
+
+// Resolve parses the Avro schema s, which must be a record, and inlines
+// the definitions of the types it references, fetched with their own
+// references from their subjects, with schema.InlineReferences.
+func (c *Client) Resolve(ctx context.Context, s *Schema) (map[string]interface{}, error) {
+	avroSchema, err := parseAvro(s)
+	if err != nil {
+		return nil, err
+	}
+	if avroSchema["type"] != "record" && avroSchema["type"] != "error" {
+		return nil, errors.New("schema is not an Avro record schema")
+	}
+	types := make(map[string]map[string]interface{})
+	if err := c.collectReferences(ctx, s.References, types, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+	if len(types) == 0 {
+		return avroSchema, nil
+	}
+	return schema.InlineReferences(avroSchema, types)
+}
+
+// collectReferences fetches the referenced types, and theirs in turn,
+// into types by full name. seen holds the subject versions fetched.
+func (c *Client) collectReferences(ctx context.Context, refs []Reference, types map[string]map[string]interface{}, seen map[string]bool) error {
+	for _, ref := range refs {
+		key := ref.Subject + "@" + strconv.Itoa(ref.Version)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		s, err := c.SchemaVersion(ctx, ref.Subject, strconv.Itoa(ref.Version))
+		if err != nil {
+			return fmt.Errorf("reference %s: %w", ref.Name, err)
+		}
+		def, err := parseAvro(s)
+		if err != nil {
+			return fmt.Errorf("reference %s: %w", ref.Name, err)
+		}
+		if prev, ok := types[ref.Name]; ok && !sameDefinition(prev, def) {
+			return fmt.Errorf("reference %s resolves to different definitions", ref.Name)
+		}
+		types[ref.Name] = def
+		if err := c.collectReferences(ctx, s.References, types, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameDefinition(a, b map[string]interface{}) bool {
+	ca, errA := schema.CanonicalForm(a)
+	cb, errB := schema.CanonicalForm(b)
+	return errA == nil && errB == nil && string(ca) == string(cb)
+}
+
+// parseAvro parses the schema text of s, which must be an Avro schema.
+func parseAvro(s *Schema) (map[string]interface{}, error) {
+	if s.SchemaType != "" && s.SchemaType != "AVRO" {
+		return nil, fmt.Errorf("%s schemas are not supported", s.SchemaType)
+	}
+	var avroSchema map[string]interface{}
+	if err := json.Unmarshal([]byte(s.Schema), &avroSchema); err != nil {
+		return nil, fmt.Errorf("parsing schema: %w", err)
+	}
+	return avroSchema, nil
+}
+
+// get sends a GET request for the path made of the escaped segments and
+// decodes the JSON response into v.
+func (c *Client) get(ctx context.Context, segments []string, v interface{}) error {
+	u := *c.base
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = url.PathEscape(s)
+	}
+	u.RawPath = u.EscapedPath() + "/" + strings.Join(escaped, "/")
+	u.Path = u.Path + "/" + strings.Join(segments, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, e) != nil {
+			e.Message = ""
+		}
+		return e
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("schema registry: invalid response: %w", err)
+	}
+	return nil
+}
+
+// maxResponseSize bounds the size of a registry response.
+const maxResponseSize = 16 << 20
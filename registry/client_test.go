@@ -0,0 +1,252 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// fakeRegistry mimics the schema registry REST API for the schemas it
+// holds by subject, one per version.
+type fakeRegistry struct {
+	subjects map[string][]Schema
+	ids      map[int]Schema
+	requests int
+}
+
+func newFakeRegistry() *fakeRegistry {
+	r := &fakeRegistry{subjects: make(map[string][]Schema), ids: make(map[int]Schema)}
+	r.register("address-value", `{"type": "record", "name": "Address", "namespace": "com.common", "fields": [{"name": "city", "type": "string"}]}`)
+	r.register("customer-value", `{"type": "record", "name": "Customer", "namespace": "com.crm", "fields": [
+		{"name": "name", "type": "string"},
+		{"name": "home", "type": "com.common.Address"}
+	]}`, Reference{Name: "com.common.Address", Subject: "address-value", Version: 1})
+	r.register("orders-value", `{"type": "record", "name": "Order", "fields": [{"name": "id", "type": "string"}]}`)
+	r.register("orders-value", `{"type": "record", "name": "Order", "namespace": "com.shop", "fields": [
+		{"name": "id", "type": "string"},
+		{"name": "shipping", "type": "com.common.Address"},
+		{"name": "customer", "type": "com.crm.Customer"}
+	]}`,
+		Reference{Name: "com.common.Address", Subject: "address-value", Version: 1},
+		Reference{Name: "com.crm.Customer", Subject: "customer-value", Version: 1})
+	return r
+}
+
+func (r *fakeRegistry) register(subject, text string, refs ...Reference) {
+	s := Schema{
+		Subject:    subject,
+		Version:    len(r.subjects[subject]) + 1,
+		ID:         len(r.ids) + 1,
+		Schema:     text,
+		References: refs,
+	}
+	r.subjects[subject] = append(r.subjects[subject], s)
+	r.ids[s.ID] = s
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.requests++
+	if user, password, ok := req.BasicAuth(); !ok || user != "svc" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 401, "message": "Unauthorized"})
+		return
+	}
+	notFound := func(code int, message string) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error_code": code, "message": message})
+	}
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "subjects":
+		var subjects []string
+		for s := range r.subjects {
+			subjects = append(subjects, s)
+		}
+		json.NewEncoder(w).Encode(subjects)
+	case len(parts) >= 3 && parts[0] == "subjects" && parts[2] == "versions":
+		versions, ok := r.subjects[parts[1]]
+		if !ok {
+			notFound(40401, "Subject '"+parts[1]+"' not found.")
+			return
+		}
+		if len(parts) == 3 {
+			var numbers []int
+			for _, s := range versions {
+				numbers = append(numbers, s.Version)
+			}
+			json.NewEncoder(w).Encode(numbers)
+			return
+		}
+		i := len(versions) - 1
+		if parts[3] != "latest" {
+			for i = range versions {
+				if versions[i].Version == atoi(parts[3]) {
+					break
+				}
+			}
+			if versions[i].Version != atoi(parts[3]) {
+				notFound(40402, "Version "+parts[3]+" not found.")
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(versions[i])
+	case len(parts) == 3 && parts[0] == "schemas" && parts[1] == "ids":
+		s, ok := r.ids[atoi(parts[2])]
+		if !ok {
+			notFound(40403, "Schema "+parts[2]+" not found")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"schema": s.Schema, "references": s.References})
+	default:
+		notFound(404, "HTTP 404 Not Found")
+	}
+}
+
+func atoi(s string) int {
+	n := 0
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return -1
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
+
+func TestClient(t *testing.T) {
+	fake := newFakeRegistry()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	ctx := context.Background()
+
+	c, err := NewClient(Config{URL: server.URL + "/", Username: "svc", Password: "secret"})
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	subjects, err := c.Subjects(ctx)
+	if err != nil || len(subjects) != 3 {
+		t.Fatalf("Expected 3 subjects, but got %v (%v)", subjects, err)
+	}
+	versions, err := c.Versions(ctx, "orders-value")
+	if err != nil || !reflect.DeepEqual(versions, []int{1, 2}) {
+		t.Fatalf("Expected versions 1 and 2, but got %v (%v)", versions, err)
+	}
+	first, err := c.SchemaVersion(ctx, "orders-value", "1")
+	if err != nil || first.Version != 1 || len(first.References) != 0 {
+		t.Fatalf("Expected version 1, but got %+v (%v)", first, err)
+	}
+
+	latest, err := c.SchemaVersion(ctx, "orders-value", Latest)
+	if err != nil || latest.Version != 2 || len(latest.References) != 2 {
+		t.Fatalf("Expected version 2 with two references, but got %+v (%v)", latest, err)
+	}
+	fake.requests = 0
+	resolved, err := c.Resolve(ctx, latest)
+	if err != nil {
+		t.Fatalf("Error resolving references: %v", err)
+	}
+	if fake.requests != 2 {
+		t.Fatalf("Expected each referenced subject version to be fetched once, but got %d requests", fake.requests)
+	}
+	s, err := schema.ConvertAvroToBigQuery(resolved)
+	if err != nil {
+		t.Fatalf("Error converting schema: %v", err)
+	}
+	if s[1].Type != bigquery.RecordFieldType || s[2].Type != bigquery.RecordFieldType {
+		t.Fatalf("Expected shipping and customer to be records, but got %v and %v", s[1].Type, s[2].Type)
+	}
+	if home := s[2].Schema[1]; home.Type != bigquery.RecordFieldType || !reflect.DeepEqual(home.Schema, s[1].Schema) {
+		t.Fatalf("Expected the home address of customer to be an address record, but got %v", home)
+	}
+
+	byID, err := c.SchemaByID(ctx, latest.ID)
+	if err != nil || !reflect.DeepEqual(byID, resolved) {
+		t.Fatalf("Expected schema %d to resolve like its subject version, but got %v (%v)", latest.ID, byID, err)
+	}
+
+	t.Run("type referenced twice", func(t *testing.T) {
+		fake.register("invoices-value", `{"type": "record", "name": "Invoice", "namespace": "com.billing", "fields": [
+			{"name": "billing", "type": "com.common.Address"},
+			{"name": "shipping", "type": ["null", "com.common.Address"]}
+		]}`, Reference{Name: "com.common.Address", Subject: "address-value", Version: 1})
+		invoice, err := c.SchemaVersion(ctx, "invoices-value", Latest)
+		if err != nil {
+			t.Fatalf("Error reading invoice schema: %v", err)
+		}
+		resolved, err := c.Resolve(ctx, invoice)
+		if err != nil {
+			t.Fatalf("Error resolving references: %v", err)
+		}
+		s, err := schema.ConvertAvroToBigQuery(resolved)
+		if err != nil {
+			t.Fatalf("Error converting schema: %v", err)
+		}
+		for _, col := range s {
+			if col.Type != bigquery.RecordFieldType || len(col.Schema) != 1 || col.Schema[0].Name != "city" {
+				t.Fatalf("Expected %s to be an address record, but got %v", col.Name, col)
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var regErr *Error
+		if _, err := c.SchemaVersion(ctx, "missing-value", Latest); !errors.As(err, &regErr) || regErr.Code != 40401 {
+			t.Fatalf("Expected error 40401, but got %v", err)
+		}
+		if _, err := c.SchemaVersion(ctx, "orders-value", "9"); !errors.As(err, &regErr) || regErr.Code != 40402 {
+			t.Fatalf("Expected error 40402, but got %v", err)
+		}
+		if _, err := c.SchemaVersion(ctx, "orders-value", "first"); err == nil {
+			t.Fatalf("Expected an error for an invalid version")
+		}
+		if _, err := c.SchemaByID(ctx, 99); !errors.As(err, &regErr) || regErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected a not found error, but got %v", err)
+		}
+		anonymous, _ := NewClient(Config{URL: server.URL})
+		if _, err := anonymous.Subjects(ctx); !errors.As(err, &regErr) || regErr.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("Expected an unauthorized error, but got %v", err)
+		}
+		if _, err := NewClient(Config{URL: "registry:8081"}); err == nil {
+			t.Fatalf("Expected an error for a URL without scheme")
+		}
+	})
+}
+
+func TestClientTLS(t *testing.T) {
+	server := httptest.NewTLSServer(newFakeRegistry())
+	defer server.Close()
+	ctx := context.Background()
+
+	untrusted, _ := NewClient(Config{URL: server.URL, Username: "svc", Password: "secret"})
+	if _, err := untrusted.Subjects(ctx); err == nil {
+		t.Fatalf("Expected a certificate error without the test CA")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := TLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatalf("Error loading CA: %v", err)
+	}
+	c, err := NewClient(Config{URL: server.URL, Username: "svc", Password: "secret", TLS: tlsConfig})
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	if _, err := c.Subjects(ctx); err != nil {
+		t.Fatalf("Error listing subjects over TLS: %v", err)
+	}
+}
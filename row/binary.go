@@ -0,0 +1,138 @@
+package row
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+// reader reads values in the Avro binary encoding from a buffer.
+type reader struct {
+	buf []byte
+	pos int
+}
+
+var errOverlongVarint = errors.New("varint is longer than 10 bytes")
+
+func (r *reader) readLong() (int64, error) {
+	var u uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if r.pos >= len(r.buf) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		b := r.buf[r.pos]
+		r.pos++
+		u |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+	}
+	return 0, errOverlongVarint
+}
+
+func (r *reader) readInt() (int64, error) {
+	n, err := r.readLong()
+	if err == nil && (n < math.MinInt32 || n > math.MaxInt32) {
+		err = fmt.Errorf("int %d out of range", n)
+	}
+	return n, err
+}
+
+func (r *reader) readBoolean() (bool, error) {
+	b, err := r.readFixed(1)
+	if err != nil {
+		return false, err
+	}
+	switch b[0] {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid boolean byte %#x", b[0])
+}
+
+func (r *reader) readFloat() (float64, error) {
+	b, err := r.readFixed(4)
+	if err != nil {
+		return 0, err
+	}
+	return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+}
+
+func (r *reader) readDouble() (float64, error) {
+	b, err := r.readFixed(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// readFixed returns the next n bytes. The result aliases the buffer.
+func (r *reader) readFixed(n int) ([]byte, error) {
+	if n < 0 || n > len(r.buf)-r.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// readBytes returns a copy of the next length-prefixed bytes.
+func (r *reader) readBytes() ([]byte, error) {
+	n, err := r.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > int64(len(r.buf)-r.pos) {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+	b, _ := r.readFixed(int(n))
+	return append([]byte(nil), b...), nil
+}
+
+func (r *reader) readString() (string, error) {
+	n, err := r.readLong()
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n > int64(len(r.buf)-r.pos) {
+		return "", fmt.Errorf("invalid length %d", n)
+	}
+	b, _ := r.readFixed(int(n))
+	if !utf8.Valid(b) {
+		return "", errors.New("string is not valid UTF-8")
+	}
+	return string(b), nil
+}
+
+// readBlockCount reads the item count of the next block of an array or
+// map, skipping the byte size that follows a negative count.
+func (r *reader) readBlockCount() (int64, error) {
+	n, err := r.readLong()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		if n == math.MinInt64 {
+			return 0, errors.New("invalid block count")
+		}
+		n = -n
+		if _, err := r.readLong(); err != nil {
+			return 0, err
+		}
+	}
+	// Every item takes at least one byte, except nulls, which are
+	// bounded by the same limit to reject corrupt counts early.
+	if n > int64(len(r.buf)-r.pos)+maxEmptyItems {
+		return 0, fmt.Errorf("invalid block count %d", n)
+	}
+	return n, nil
+}
+
+// maxEmptyItems bounds the number of zero-length items, such as nulls, in
+// an array or map block.
+const maxEmptyItems = 1 << 20
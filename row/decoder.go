@@ -0,0 +1,874 @@
+// Package row decodes Avro data into BigQuery rows that match the schemas
+// converted by the schema package, for streaming inserts and inspection.
+package row
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// Row is a BigQuery row decoded from an Avro record, keyed by column name.
+// Nested records are map[string]bigquery.Value and repeated columns
+// []bigquery.Value.
+type Row map[string]bigquery.Value
+
+// Save implements bigquery.ValueSaver, leaving the insert ID to the
+// client. Values are sent as decoded, which the BigQuery API rejects for
+// NUMERIC and BIGNUMERIC columns; insert with Saver instead when the row
+// may hold decimals.
+func (r Row) Save() (map[string]bigquery.Value, string, error) {
+	return r, "", nil
+}
+
+// Saver returns a bigquery.ValueSaver of r, a row of s, which formats
+// values by their column the way the BigQuery client formats those of a
+// bigquery.ValuesSaver: *big.Rat values of NUMERIC and BIGNUMERIC columns
+// as decimal strings, and civil.Time and civil.DateTime values of TIME and
+// DATETIME columns in the BigQuery format.
+func Saver(r Row, s bigquery.Schema) bigquery.ValueSaver {
+	return schemaRow{r: r, s: s}
+}
+
+type schemaRow struct {
+	r Row
+	s bigquery.Schema
+}
+
+func (sr schemaRow) Save() (map[string]bigquery.Value, string, error) {
+	return uploadRecord(sr.r, sr.s), "", nil
+}
+
+// uploadRecord returns the values of a record of s formatted for upload.
+func uploadRecord(values map[string]bigquery.Value, s bigquery.Schema) map[string]bigquery.Value {
+	out := make(map[string]bigquery.Value, len(values))
+	for name, v := range values {
+		var col *bigquery.FieldSchema
+		for _, f := range s {
+			if f.Name == name {
+				col = f
+				break
+			}
+		}
+		out[name] = uploadValue(v, col)
+	}
+	return out
+}
+
+func uploadValue(v bigquery.Value, col *bigquery.FieldSchema) bigquery.Value {
+	if col == nil {
+		return v
+	}
+	switch v := v.(type) {
+	case []bigquery.Value:
+		values := make([]bigquery.Value, len(v))
+		for i, e := range v {
+			values[i] = uploadValue(e, col)
+		}
+		return values
+	case map[string]bigquery.Value:
+		return uploadRecord(v, col.Schema)
+	case *big.Rat:
+		if col.Type == bigquery.BigNumericFieldType {
+			return bigquery.BigNumericString(v)
+		}
+		return bigquery.NumericString(v)
+	case civil.Time:
+		return bigquery.CivilTimeString(v)
+	case civil.DateTime:
+		return bigquery.CivilDateTimeString(v)
+	}
+	return v
+}
+
+// Decoder decodes Avro records written with one schema into rows of a
+// BigQuery schema converted from it. Values are shaped by the same rules
+// as the converter: the non-null branch of a nullable union fills the
+// column, a union converted to a RECORD fills the field named after its
+// branch, an array of non-record elements fills the single nested column
+// of its REPEATED RECORD (or the REPEATED column itself with native
+// arrays), a map becomes repeated key and value records, and an enum
+// becomes its symbol, or its ordinal in an INTEGER column. Records, arrays
+// and maps converted to a STRING or JSON column are stored as JSON.
+// Logical types become the Go types the BigQuery client expects for their
+// column: *big.Rat for decimals in NUMERIC and BIGNUMERIC columns,
+// civil.Date, civil.Time and civil.DateTime for dates, times and local
+// timestamps, and time.Time for timestamps. Decimals that overflow their
+// column and dates out of the BigQuery range are errors.
+//
+// Fields are matched with columns by name, ignoring case; fields without
+// a column are decoded and dropped. A Decoder is safe for concurrent use.
+type Decoder struct {
+	decode decodeFunc
+	encode encodeFunc
+}
+
+// NewDecoder returns a decoder of records of the Avro record schema
+// avroSchema into rows of s. When s was converted with a naming strategy
+// or sanitization, pass the renamed Conversion.AvroSchema, or use
+// NewConversionDecoder.
+func NewDecoder(avroSchema map[string]interface{}, s bigquery.Schema) (*Decoder, error) {
+	c := &compiler{
+		names:    make(map[string]named),
+		generic:  make(map[string]*decodeFunc),
+		encoders: make(map[string]*encodeFunc),
+	}
+	if err := c.collect(avroSchema, ""); err != nil {
+		return nil, err
+	}
+	record := &bigquery.FieldSchema{Type: bigquery.RecordFieldType, Schema: s}
+	decode, err := c.compile(avroSchema, "", record)
+	if err != nil {
+		return nil, err
+	}
+	encode, err := c.compileEncoder(avroSchema, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{decode: decode, encode: encode}, nil
+}
+
+// NewConversionDecoder returns a decoder producing rows of c.Schema. The
+// writer schema must be the schema c was converted from. Flattened and
+// normalized conversions are not supported.
+func NewConversionDecoder(c *schema.Conversion) (*Decoder, error) {
+	if c.Flattened != nil || c.Tables != nil {
+		return nil, errors.New("flattened and normalized conversions are not supported")
+	}
+	return NewDecoder(c.AvroSchema, c.Schema)
+}
+
+// Decode decodes data holding exactly one record.
+func (d *Decoder) Decode(data []byte) (Row, error) {
+	row, n, err := d.DecodeNext(data)
+	if err == nil && n != len(data) {
+		err = fmt.Errorf("%d trailing bytes after record", len(data)-n)
+	}
+	return row, err
+}
+
+// DecodeNext decodes the record at the start of data and returns it with
+// the number of bytes it took.
+func (d *Decoder) DecodeNext(data []byte) (Row, int, error) {
+	r := &reader{buf: data}
+	v, err := d.decode(r)
+	if err != nil {
+		return nil, r.pos, err
+	}
+	return Row(v.(map[string]bigquery.Value)), r.pos, nil
+}
+
+// decodeFunc decodes one value.
+type decodeFunc func(r *reader) (bigquery.Value, error)
+
+// named is a named type definition with its full name and the namespace
+// that names inside it resolve against.
+type named struct {
+	def       map[string]interface{}
+	full      string
+	namespace string
+}
+
+// compiler builds the decodeFuncs of a schema.
+type compiler struct {
+	// names holds the named types by full name.
+	names map[string]named
+	// generic holds the generic decoders of named types, which may be
+	// recursive, by full name.
+	generic map[string]*decodeFunc
+	// encoders holds the encoders of named types from the Avro JSON
+	// encoding to the binary one, by full name.
+	encoders map[string]*encodeFunc
+}
+
+var primitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// fullName returns the full name of a name used in namespace ns.
+func fullName(name, ns string) string {
+	if strings.Contains(name, ".") || ns == "" {
+		return name
+	}
+	return ns + "." + name
+}
+
+// definitionName returns the full name of the named type defined by t in
+// namespace ns.
+func definitionName(t map[string]interface{}, ns string) string {
+	name, _ := t["name"].(string)
+	if namespace, ok := t["namespace"].(string); ok && !strings.Contains(name, ".") {
+		ns = namespace
+	}
+	return fullName(name, ns)
+}
+
+// namespaceOf returns the namespace of a full name.
+func namespaceOf(full string) string {
+	if i := strings.LastIndex(full, "."); i >= 0 {
+		return full[:i]
+	}
+	return ""
+}
+
+// collect registers every named type defined in t.
+func (c *compiler) collect(t interface{}, ns string) error {
+	switch t := t.(type) {
+	case []interface{}:
+		for _, branch := range t {
+			if err := c.collect(branch, ns); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		typ, _ := t["type"].(string)
+		switch typ {
+		case "record", "error", "enum", "fixed":
+			if _, ok := t["name"].(string); !ok {
+				return fmt.Errorf("%s without a name", typ)
+			}
+			full := definitionName(t, ns)
+			if _, dup := c.names[full]; dup {
+				return fmt.Errorf("%s is defined twice", full)
+			}
+			ns = namespaceOf(full)
+			c.names[full] = named{def: t, full: full, namespace: ns}
+		}
+		switch typ {
+		case "record", "error":
+			fields, _ := t["fields"].([]interface{})
+			for _, f := range fields {
+				field, _ := f.(map[string]interface{})
+				if err := c.collect(fieldType(field), ns); err != nil {
+					return err
+				}
+			}
+		case "array":
+			return c.collect(t["items"], ns)
+		case "map":
+			return c.collect(t["values"], ns)
+		default:
+			if nested, ok := t["type"].(map[string]interface{}); ok {
+				return c.collect(nested, ns)
+			}
+		}
+	}
+	return nil
+}
+
+// fieldType returns the type of a record field. Like the converter, it
+// accepts a record declared directly on the field.
+func fieldType(field map[string]interface{}) interface{} {
+	if field["type"] == "record" {
+		return field
+	}
+	return field["type"]
+}
+
+// lookup resolves a reference to a named type.
+func (c *compiler) lookup(name, ns string) (named, error) {
+	if n, ok := c.names[fullName(name, ns)]; ok {
+		return n, nil
+	}
+	if n, ok := c.names[name]; ok {
+		return n, nil
+	}
+	return named{}, fmt.Errorf("unknown type %s", name)
+}
+
+// compile returns the decoder of values of the Avro type t, defined in
+// namespace ns, into the column col. The mode of col matters: only a
+// REPEATED column takes an array.
+func (c *compiler) compile(t interface{}, ns string, col *bigquery.FieldSchema) (decodeFunc, error) {
+	switch t := t.(type) {
+	case []interface{}:
+		return c.compileUnion(t, ns, col)
+	case string:
+		if primitives[t] {
+			return c.compilePrimitive(t, nil, col)
+		}
+		n, err := c.lookup(t, ns)
+		if err != nil {
+			return nil, err
+		}
+		return c.compile(n.def, n.namespace, col)
+	case map[string]interface{}:
+		typ, ok := t["type"].(string)
+		if !ok {
+			if nested, isType := t["type"].(map[string]interface{}); isType {
+				return c.compile(nested, ns, col)
+			}
+			return nil, errors.New("invalid avro type")
+		}
+		switch typ {
+		case "record", "error":
+			ns = namespaceOf(definitionName(t, ns))
+			if isRecordColumn(col) && !col.Repeated {
+				return c.compileRecord(t, ns, col.Schema)
+			}
+			return c.compileJSON(t, ns, col)
+		case "array":
+			if col.Repeated {
+				return c.compileArray(t, ns, col)
+			}
+			return c.compileJSON(t, ns, col)
+		case "map":
+			if col.Repeated && isRecordColumn(col) {
+				return c.compileMap(t, ns, col)
+			}
+			return c.compileJSON(t, ns, col)
+		case "enum":
+			return c.compileEnum(t, col)
+		case "fixed":
+			return c.compilePrimitive(typ, t, col)
+		}
+		if primitives[typ] {
+			return c.compilePrimitive(typ, t, col)
+		}
+		return c.compile(typ, ns, col)
+	}
+	return nil, errors.New("invalid avro type")
+}
+
+func (c *compiler) compileRecord(t map[string]interface{}, ns string, columns bigquery.Schema) (decodeFunc, error) {
+	fields, ok := t["fields"].([]interface{})
+	if !ok {
+		return nil, errors.New("invalid avro record fields")
+	}
+	type fieldDecoder struct {
+		column string
+		decode decodeFunc
+	}
+	decoders := make([]fieldDecoder, len(fields))
+	for i, f := range fields {
+		field, ok := f.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("invalid avro record field")
+		}
+		name, _ := field["name"].(string)
+		col := findColumn(columns, name)
+		var err error
+		if col == nil {
+			decoders[i].decode, err = c.compileGeneric(fieldType(field), ns)
+		} else {
+			decoders[i].column = col.Name
+			decoders[i].decode, err = c.compile(fieldType(field), ns, col)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return func(r *reader) (bigquery.Value, error) {
+		row := make(map[string]bigquery.Value, len(decoders))
+		for _, d := range decoders {
+			v, err := d.decode(r)
+			if err != nil {
+				return nil, err
+			}
+			if d.column != "" {
+				row[d.column] = v
+			}
+		}
+		return row, nil
+	}, nil
+}
+
+func (c *compiler) compileUnion(branches []interface{}, ns string, col *bigquery.FieldSchema) (decodeFunc, error) {
+	var nonNull int
+	for _, b := range branches {
+		if b != "null" {
+			nonNull++
+		}
+	}
+	// A union converted to a RECORD with one field per branch.
+	asRecord := nonNull > 1 && isRecordColumn(col) && !col.Repeated
+	decoders := make([]decodeFunc, len(branches))
+	for i, b := range branches {
+		var err error
+		switch {
+		case b == "null":
+			decoders[i] = func(*reader) (bigquery.Value, error) { return nil, nil }
+		case asRecord:
+			name := unionBranchName(b)
+			sub := findColumn(col.Schema, name)
+			if sub == nil {
+				return nil, fmt.Errorf("no column for union branch %s", name)
+			}
+			decode, err := c.compile(b, ns, sub)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			column := sub.Name
+			decoders[i] = func(r *reader) (bigquery.Value, error) {
+				v, err := decode(r)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]bigquery.Value{column: v}, nil
+			}
+		default:
+			decoders[i], err = c.compile(b, ns, col)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func(r *reader) (bigquery.Value, error) {
+		i, err := r.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(decoders)) {
+			return nil, fmt.Errorf("union branch %d out of range", i)
+		}
+		return decoders[i](r)
+	}, nil
+}
+
+func (c *compiler) compileArray(t map[string]interface{}, ns string, col *bigquery.FieldSchema) (decodeFunc, error) {
+	items := t["items"]
+	element := *col
+	element.Repeated = false
+	var decode decodeFunc
+	var err error
+	switch {
+	case !isRecordColumn(col), c.isRecordOf(items, ns, col.Schema):
+		// A native REPEATED column, or a REPEATED RECORD of records.
+		decode, err = c.compile(items, ns, &element)
+	case len(col.Schema) == 1:
+		// The single nested column of an array of non-record elements.
+		sub := col.Schema[0]
+		var elementDecode decodeFunc
+		if elementDecode, err = c.compile(items, ns, sub); err == nil {
+			column := sub.Name
+			decode = func(r *reader) (bigquery.Value, error) {
+				v, err := elementDecode(r)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]bigquery.Value{column: v}, nil
+			}
+		}
+	default:
+		return nil, fmt.Errorf("array does not match column %s", col.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(r *reader) (bigquery.Value, error) {
+		values := []bigquery.Value{}
+		for {
+			n, err := r.readBlockCount()
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return values, nil
+			}
+			for i := int64(0); i < n; i++ {
+				v, err := decode(r)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, v)
+			}
+		}
+	}, nil
+}
+
+// isRecordOf reports whether t, or the only non-null branch of the union
+// t, is a record whose fields are the columns of s.
+func (c *compiler) isRecordOf(t interface{}, ns string, s bigquery.Schema) bool {
+	for {
+		switch u := t.(type) {
+		case []interface{}:
+			var branch interface{}
+			for _, b := range u {
+				if b == "null" {
+					continue
+				}
+				if branch != nil {
+					return false
+				}
+				branch = b
+			}
+			t = branch
+			continue
+		case string:
+			if primitives[u] {
+				return false
+			}
+			n, err := c.lookup(u, ns)
+			if err != nil {
+				return false
+			}
+			t, ns = n.def, n.namespace
+			continue
+		case map[string]interface{}:
+			if u["type"] != "record" && u["type"] != "error" {
+				if nested, ok := u["type"].(map[string]interface{}); ok {
+					t = nested
+					continue
+				}
+				return false
+			}
+			fields, _ := u["fields"].([]interface{})
+			if len(fields) != len(s) {
+				return false
+			}
+			for _, f := range fields {
+				field, _ := f.(map[string]interface{})
+				name, _ := field["name"].(string)
+				if findColumn(s, name) == nil {
+					return false
+				}
+			}
+			return true
+		}
+		return false
+	}
+}
+
+func (c *compiler) compileMap(t map[string]interface{}, ns string, col *bigquery.FieldSchema) (decodeFunc, error) {
+	keyCol, valueCol := findColumn(col.Schema, "key"), findColumn(col.Schema, "value")
+	if keyCol == nil || valueCol == nil {
+		return nil, fmt.Errorf("map does not match column %s", col.Name)
+	}
+	decodeValue, err := c.compile(t["values"], ns, valueCol)
+	if err != nil {
+		return nil, err
+	}
+	key, value := keyCol.Name, valueCol.Name
+	return func(r *reader) (bigquery.Value, error) {
+		entries := []bigquery.Value{}
+		for {
+			n, err := r.readBlockCount()
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return entries, nil
+			}
+			for i := int64(0); i < n; i++ {
+				k, err := r.readString()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeValue(r)
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, map[string]bigquery.Value{key: k, value: v})
+			}
+		}
+	}, nil
+}
+
+func (c *compiler) compileEnum(t map[string]interface{}, col *bigquery.FieldSchema) (decodeFunc, error) {
+	symbols, ok := t["symbols"].([]interface{})
+	if !ok {
+		return nil, errors.New("invalid enum symbols")
+	}
+	if isRecordColumn(col) || col.Repeated {
+		return nil, fmt.Errorf("enum cannot fill %s column %s", col.Type, col.Name)
+	}
+	ordinal := col.Type == bigquery.IntegerFieldType
+	return func(r *reader) (bigquery.Value, error) {
+		i, err := r.readInt()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(symbols)) {
+			return nil, fmt.Errorf("enum index %d out of range", i)
+		}
+		if ordinal {
+			return i, nil
+		}
+		return symbols[i], nil
+	}, nil
+}
+
+// compilePrimitive returns the decoder of a primitive or fixed type, whose
+// type object, if any, is props, converting logical types to the values
+// BigQuery expects for col (see logicalConverter).
+func (c *compiler) compilePrimitive(typ string, props map[string]interface{}, col *bigquery.FieldSchema) (decodeFunc, error) {
+	if isRecordColumn(col) || col.Repeated {
+		mode := string(col.Type)
+		if col.Repeated {
+			mode = "REPEATED " + mode
+		}
+		return nil, fmt.Errorf("%s cannot fill %s column %s", typ, mode, col.Name)
+	}
+	decode, err := primitiveDecoder(typ, props)
+	if err != nil {
+		return nil, err
+	}
+	logicalType, _ := props["logicalType"].(string)
+	convert, err := logicalConverter(typ, logicalType, props, col)
+	if err != nil || convert == nil {
+		return decode, err
+	}
+	return func(r *reader) (bigquery.Value, error) {
+		v, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+		return convert(v)
+	}, nil
+}
+
+// primitiveDecoder returns the decoder of the Avro value of a primitive or
+// fixed type.
+func primitiveDecoder(typ string, props map[string]interface{}) (decodeFunc, error) {
+	switch typ {
+	case "null":
+		return func(*reader) (bigquery.Value, error) { return nil, nil }, nil
+	case "boolean":
+		return func(r *reader) (bigquery.Value, error) { return r.readBoolean() }, nil
+	case "int":
+		return func(r *reader) (bigquery.Value, error) { return r.readInt() }, nil
+	case "long":
+		return func(r *reader) (bigquery.Value, error) { return r.readLong() }, nil
+	case "float":
+		return func(r *reader) (bigquery.Value, error) { return r.readFloat() }, nil
+	case "double":
+		return func(r *reader) (bigquery.Value, error) { return r.readDouble() }, nil
+	case "bytes":
+		return func(r *reader) (bigquery.Value, error) { return r.readBytes() }, nil
+	case "string":
+		return func(r *reader) (bigquery.Value, error) { return r.readString() }, nil
+	case "fixed":
+		size, ok := props["size"].(float64)
+		if !ok || size < 0 || size != float64(int(size)) {
+			return nil, fmt.Errorf("invalid fixed size %v", props["size"])
+		}
+		n := int(size)
+		return func(r *reader) (bigquery.Value, error) {
+			b, err := r.readFixed(n)
+			return append([]byte(nil), b...), err
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown avro type %s", typ)
+}
+
+// compileJSON returns a decoder storing a record, array or map as JSON in
+// a STRING or JSON column.
+func (c *compiler) compileJSON(t map[string]interface{}, ns string, col *bigquery.FieldSchema) (decodeFunc, error) {
+	if col.Repeated || (col.Type != bigquery.StringFieldType && col.Type != bigquery.JSONFieldType) {
+		return nil, fmt.Errorf("%s cannot fill %s column %s", t["type"], col.Type, col.Name)
+	}
+	decode, err := c.compileGeneric(t, ns)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *reader) (bigquery.Value, error) {
+		v, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}, nil
+}
+
+// compileGeneric returns a decoder of t that is independent of any
+// column: records and maps become map[string]interface{}, arrays
+// []interface{}, enums their symbol, and unions the value of their branch.
+func (c *compiler) compileGeneric(t interface{}, ns string) (decodeFunc, error) {
+	switch t := t.(type) {
+	case []interface{}:
+		decoders := make([]decodeFunc, len(t))
+		for i, b := range t {
+			var err error
+			if decoders[i], err = c.compileGeneric(b, ns); err != nil {
+				return nil, err
+			}
+		}
+		return func(r *reader) (bigquery.Value, error) {
+			i, err := r.readLong()
+			if err != nil {
+				return nil, err
+			}
+			if i < 0 || i >= int64(len(decoders)) {
+				return nil, fmt.Errorf("union branch %d out of range", i)
+			}
+			return decoders[i](r)
+		}, nil
+	case string:
+		if primitives[t] {
+			return primitiveDecoder(t, nil)
+		}
+		n, err := c.lookup(t, ns)
+		if err != nil {
+			return nil, err
+		}
+		return c.compileGenericNamed(n)
+	case map[string]interface{}:
+		typ, ok := t["type"].(string)
+		if !ok {
+			if nested, isType := t["type"].(map[string]interface{}); isType {
+				return c.compileGeneric(nested, ns)
+			}
+			return nil, errors.New("invalid avro type")
+		}
+		switch typ {
+		case "record", "error", "enum", "fixed":
+			n, err := c.lookup(definitionName(t, ns), "")
+			if err != nil {
+				return nil, err
+			}
+			return c.compileGenericNamed(n)
+		case "array":
+			decode, err := c.compileGeneric(t["items"], ns)
+			if err != nil {
+				return nil, err
+			}
+			return func(r *reader) (bigquery.Value, error) {
+				values := []interface{}{}
+				for {
+					n, err := r.readBlockCount()
+					if err != nil || n == 0 {
+						return values, err
+					}
+					for i := int64(0); i < n; i++ {
+						v, err := decode(r)
+						if err != nil {
+							return nil, err
+						}
+						values = append(values, v)
+					}
+				}
+			}, nil
+		case "map":
+			decode, err := c.compileGeneric(t["values"], ns)
+			if err != nil {
+				return nil, err
+			}
+			return func(r *reader) (bigquery.Value, error) {
+				values := map[string]interface{}{}
+				for {
+					n, err := r.readBlockCount()
+					if err != nil || n == 0 {
+						return values, err
+					}
+					for i := int64(0); i < n; i++ {
+						k, err := r.readString()
+						if err != nil {
+							return nil, err
+						}
+						if values[k], err = decode(r); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}, nil
+		}
+		if primitives[typ] {
+			return primitiveDecoder(typ, t)
+		}
+		return c.compileGeneric(typ, ns)
+	}
+	return nil, errors.New("invalid avro type")
+}
+
+// compileGenericNamed returns the generic decoder of a named type. The
+// decoder is shared and built once, so recursive types terminate.
+func (c *compiler) compileGenericNamed(n named) (decodeFunc, error) {
+	if decode, ok := c.generic[n.full]; ok {
+		return func(r *reader) (bigquery.Value, error) { return (*decode)(r) }, nil
+	}
+	decode := new(decodeFunc)
+	c.generic[n.full] = decode
+	var err error
+	switch n.def["type"] {
+	case "record", "error":
+		*decode, err = c.compileGenericRecord(n.def, n.namespace)
+	case "enum":
+		*decode, err = c.compileEnum(n.def, &bigquery.FieldSchema{Type: bigquery.StringFieldType})
+	case "fixed":
+		*decode, err = primitiveDecoder("fixed", n.def)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(r *reader) (bigquery.Value, error) { return (*decode)(r) }, nil
+}
+
+func (c *compiler) compileGenericRecord(t map[string]interface{}, ns string) (decodeFunc, error) {
+	fields, ok := t["fields"].([]interface{})
+	if !ok {
+		return nil, errors.New("invalid avro record fields")
+	}
+	names := make([]string, len(fields))
+	decoders := make([]decodeFunc, len(fields))
+	for i, f := range fields {
+		field, ok := f.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("invalid avro record field")
+		}
+		names[i], _ = field["name"].(string)
+		var err error
+		if decoders[i], err = c.compileGeneric(fieldType(field), ns); err != nil {
+			return nil, fmt.Errorf("%s: %w", names[i], err)
+		}
+	}
+	return func(r *reader) (bigquery.Value, error) {
+		record := make(map[string]interface{}, len(fields))
+		for i, decode := range decoders {
+			v, err := decode(r)
+			if err != nil {
+				return nil, err
+			}
+			record[names[i]] = v
+		}
+		return record, nil
+	}, nil
+}
+
+// findColumn returns the column of s named name, ignoring case, or nil.
+func findColumn(s bigquery.Schema, name string) *bigquery.FieldSchema {
+	for _, f := range s {
+		if f.Name == name {
+			return f
+		}
+	}
+	for _, f := range s {
+		if strings.EqualFold(f.Name, name) {
+			return f
+		}
+	}
+	return nil
+}
+
+func isRecordColumn(col *bigquery.FieldSchema) bool {
+	return col.Type == bigquery.RecordFieldType || strings.EqualFold(string(col.Type), "STRUCT")
+}
+
+// unionBranchName returns the column name the converter gives a union
+// branch: the simple name of a named type, and the type name otherwise.
+func unionBranchName(branch interface{}) string {
+	name, _ := branch.(string)
+	if m, ok := branch.(map[string]interface{}); ok {
+		name, _ = m["type"].(string)
+		switch name {
+		case "record", "error", "enum", "fixed":
+			if typeName, ok := m["name"].(string); ok {
+				name = typeName
+			}
+		}
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,216 @@
+package row
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// encoder builds Avro binary data in tests.
+type encoder []byte
+
+func (e encoder) long(n int64) encoder {
+	u := uint64(n<<1) ^ uint64(n>>63)
+	for u >= 0x80 {
+		e = append(e, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(e, byte(u))
+}
+
+func (e encoder) str(s string) encoder {
+	return append(e.long(int64(len(s))), s...)
+}
+
+func (e encoder) bytes(b []byte) encoder {
+	return append(e.long(int64(len(b))), b...)
+}
+
+func (e encoder) boolean(b bool) encoder {
+	if b {
+		return append(e, 1)
+	}
+	return append(e, 0)
+}
+
+func (e encoder) double(f float64) encoder {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+	return append(e, b[:]...)
+}
+
+func (e encoder) float(f float32) encoder {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], math.Float32bits(f))
+	return append(e, b[:]...)
+}
+
+func (e encoder) fixed(b ...byte) encoder {
+	return append(e, b...)
+}
+
+func field(name string, typ interface{}) map[string]interface{} {
+	return map[string]interface{}{"name": name, "type": typ}
+}
+
+func record(name string, fields ...interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "record", "name": name, "fields": fields}
+}
+
+func convert(t *testing.T, avroSchema map[string]interface{}, opts schema.ConvertOptions) *Decoder {
+	t.Helper()
+	c, err := schema.ConvertAvroToBigQueryWithOptions(avroSchema, opts)
+	if err != nil {
+		t.Fatalf("Error converting schema: %v", err)
+	}
+	d, err := NewConversionDecoder(c)
+	if err != nil {
+		t.Fatalf("Error creating decoder: %v", err)
+	}
+	return d
+}
+
+func TestDecoder(t *testing.T) {
+	address := record("Address", field("city", "string"), field("zip", []interface{}{"null", "string"}))
+	avroSchema := map[string]interface{}{
+		"type":      "record",
+		"name":      "User",
+		"namespace": "com.example",
+		"fields": []interface{}{
+			field("id", "long"),
+			field("age", "int"),
+			field("active", "boolean"),
+			field("score", "float"),
+			field("ratio", "double"),
+			field("avatar", "bytes"),
+			field("email", []interface{}{"null", "string"}),
+			field("status", map[string]interface{}{"type": "enum", "name": "Status", "symbols": []interface{}{"ACTIVE", "BANNED"}}),
+			field("address", address),
+			field("previous", []interface{}{"null", "com.example.Address"}),
+			field("tags", map[string]interface{}{"type": "array", "items": "string", "name": "tag"}),
+			field("orders", map[string]interface{}{"type": "array", "items": record("Order", field("sku", "string"), field("qty", "int"))}),
+			field("hash", map[string]interface{}{"type": "fixed", "name": "Hash", "size": float64(2)}),
+		},
+	}
+
+	data := encoder(nil).
+		long(42).long(-7).boolean(true).float(1.5).double(0.25).bytes([]byte{1, 2}).
+		long(1).str("a@example.com").
+		long(1).
+		str("Paris").long(1).str("75001").
+		long(1).str("Lyon").long(0).
+		long(2).str("x").str("y").long(0).
+		long(-1).long(4).str("s1").long(3).long(0).
+		fixed(0xca, 0xfe)
+
+	d := convert(t, avroSchema, schema.ConvertOptions{})
+	row, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Error decoding record: %v", err)
+	}
+	want := Row{
+		"id":       int64(42),
+		"age":      int64(-7),
+		"active":   true,
+		"score":    1.5,
+		"ratio":    0.25,
+		"avatar":   []byte{1, 2},
+		"email":    "a@example.com",
+		"status":   "BANNED",
+		"address":  map[string]bigquery.Value{"city": "Paris", "zip": "75001"},
+		"previous": map[string]bigquery.Value{"city": "Lyon", "zip": nil},
+		"tags":     []bigquery.Value{map[string]bigquery.Value{"tag": "x"}, map[string]bigquery.Value{"tag": "y"}},
+		"orders":   []bigquery.Value{map[string]bigquery.Value{"sku": "s1", "qty": int64(3)}},
+		"hash":     []byte{0xca, 0xfe},
+	}
+	if !reflect.DeepEqual(row, want) {
+		t.Fatalf("Expected %v, but got %v", want, row)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := d.Decode(append(data, 0)); err == nil {
+			t.Fatalf("Expected an error for trailing bytes")
+		}
+		if _, err := d.Decode(data[:len(data)-1]); err == nil {
+			t.Fatalf("Expected an error for truncated data")
+		}
+	})
+}
+
+func TestDecoderConversionOptions(t *testing.T) {
+	avroSchema := record("Event",
+		field("eventId", "string"),
+		field("kind", map[string]interface{}{"type": "enum", "name": "Kind", "symbols": []interface{}{"A", "B", "C"}}),
+		field("labels", map[string]interface{}{"type": "array", "items": "string"}),
+		field("attributes", map[string]interface{}{"type": "map", "values": "long"}),
+		field("payload", []interface{}{"null", "string", "long"}),
+	)
+	data := encoder(nil).
+		str("e1").
+		long(2).
+		long(1).str("l").long(0).
+		long(1).str("k").long(5).long(0).
+		long(2).long(9)
+
+	profile, err := schema.ProfileByName(schema.ProfileKafkaConnect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := convert(t, avroSchema, schema.ConvertOptions{
+		Profile: profile,
+		Naming:  schema.NamingSnakeCase,
+		Enums:   &schema.EnumOptions{Mode: schema.EnumAsOrdinal},
+	})
+	row, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Error decoding record: %v", err)
+	}
+	want := Row{
+		"event_id":   "e1",
+		"kind":       int64(2),
+		"labels":     []bigquery.Value{"l"},
+		"attributes": []bigquery.Value{map[string]bigquery.Value{"key": "k", "value": int64(5)}},
+		"payload":    map[string]bigquery.Value{"long": int64(9)},
+	}
+	if !reflect.DeepEqual(row, want) {
+		t.Fatalf("Expected %v, but got %v", want, row)
+	}
+	if _, _, err := row.Save(); err != nil {
+		t.Fatalf("Error saving row: %v", err)
+	}
+}
+
+func TestDecodeNext(t *testing.T) {
+	d := convert(t, record("R", field("n", "long")), schema.ConvertOptions{})
+	data := encoder(nil).long(1).long(2)
+	var got []bigquery.Value
+	for len(data) > 0 {
+		row, n, err := d.DecodeNext(data)
+		if err != nil {
+			t.Fatalf("Error decoding record: %v", err)
+		}
+		got = append(got, row["n"])
+		data = data[n:]
+	}
+	if !reflect.DeepEqual(got, []bigquery.Value{int64(1), int64(2)}) {
+		t.Fatalf("Expected rows 1 and 2, but got %v", got)
+	}
+}
+
+func TestNewDecoderMismatch(t *testing.T) {
+	avroSchema := record("R", field("n", "long"), field("r", record("Inner", field("x", "int"))))
+	s := bigquery.Schema{
+		{Name: "n", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{{Name: "x", Type: bigquery.IntegerFieldType}}},
+	}
+	if _, err := NewDecoder(avroSchema, s); err == nil {
+		t.Fatalf("Expected an error for a long in a RECORD column")
+	}
+	s = bigquery.Schema{{Name: "r", Type: bigquery.IntegerFieldType}}
+	if _, err := NewDecoder(avroSchema, s); err == nil {
+		t.Fatalf("Expected an error for a record in an INTEGER column")
+	}
+}
@@ -0,0 +1,633 @@
+package row
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DecodeJSON decodes data holding exactly one record in the Avro JSON
+// encoding into the same row as its binary encoding: unions other than
+// null are objects keyed by the name of their branch, as in
+// {"string": "x"}, bytes and fixed values are strings of code points up to
+// U+00FF, and missing fields take their default.
+func (d *Decoder) DecodeJSON(data []byte) (Row, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := parseJSON(dec)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("trailing data after record")
+	}
+	return d.decodeJSONValue(v)
+}
+
+// decodeJSONValue transcodes a parsed JSON record to the binary encoding,
+// so that it is decoded by the same rules as binary data.
+func (d *Decoder) decodeJSONValue(v interface{}) (Row, error) {
+	b, err := d.encode(nil, v, false)
+	if err != nil {
+		return nil, err
+	}
+	return d.Decode(b)
+}
+
+// JSONReader reads a stream of records in the Avro JSON encoding, such as
+// newline-delimited JSON, as rows.
+type JSONReader struct {
+	decoder *Decoder
+	json    *json.Decoder
+	n       int
+}
+
+// NewJSONReader returns a reader of the JSON records read from r, decoded
+// with d.
+func NewJSONReader(r io.Reader, d *Decoder) *JSONReader {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &JSONReader{decoder: d, json: dec}
+}
+
+// Read returns the next row, or io.EOF after the last one. Errors name the
+// record, counting from 1.
+func (r *JSONReader) Read() (Row, error) {
+	v, err := parseJSON(r.json)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	r.n++
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", r.n, err)
+	}
+	row, err := r.decoder.decodeJSONValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", r.n, err)
+	}
+	return row, nil
+}
+
+// object is a JSON object that keeps the order of its keys, which is the
+// order of the entries of a map.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// parseJSON reads the next JSON value from dec, with objects as *object
+// and numbers as json.Number.
+func parseJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		o := &object{values: make(map[string]interface{})}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			key := tok.(string)
+			if _, dup := o.values[key]; dup {
+				return nil, fmt.Errorf("duplicate key %q", key)
+			}
+			if o.values[key], err = parseJSON(dec); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			o.keys = append(o.keys, key)
+		}
+		_, err := dec.Token()
+		return o, unexpectedEOF(err)
+	case json.Delim('['):
+		values := []interface{}{}
+		for dec.More() {
+			v, err := parseJSON(dec)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			values = append(values, v)
+		}
+		_, err := dec.Token()
+		return values, unexpectedEOF(err)
+	}
+	return tok, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ordered returns v, a value decoded by encoding/json such as a field
+// default, with objects as *object with sorted keys.
+func ordered(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		o := &object{values: make(map[string]interface{}, len(v))}
+		for k, e := range v {
+			o.keys = append(o.keys, k)
+			o.values[k] = ordered(e)
+		}
+		sort.Strings(o.keys)
+		return o
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = ordered(e)
+		}
+		return values
+	}
+	return v
+}
+
+// encodeFunc appends the binary encoding of v, a value in the Avro JSON
+// encoding, to b. In a field default, isDefault is set: a union then
+// takes its first branch, unwrapped.
+type encodeFunc func(b []byte, v interface{}, isDefault bool) ([]byte, error)
+
+// compileEncoder returns the encoder of JSON values of the Avro type t,
+// defined in namespace ns.
+func (c *compiler) compileEncoder(t interface{}, ns string) (encodeFunc, error) {
+	switch t := t.(type) {
+	case []interface{}:
+		return c.compileUnionEncoder(t, ns)
+	case string:
+		if primitives[t] {
+			return primitiveEncoder(t, nil)
+		}
+		n, err := c.lookup(t, ns)
+		if err != nil {
+			return nil, err
+		}
+		return c.compileNamedEncoder(n)
+	case map[string]interface{}:
+		typ, ok := t["type"].(string)
+		if !ok {
+			if nested, isType := t["type"].(map[string]interface{}); isType {
+				return c.compileEncoder(nested, ns)
+			}
+			return nil, errors.New("invalid avro type")
+		}
+		switch typ {
+		case "record", "error", "enum", "fixed":
+			n, err := c.lookup(definitionName(t, ns), "")
+			if err != nil {
+				return nil, err
+			}
+			return c.compileNamedEncoder(n)
+		case "array":
+			encode, err := c.compileEncoder(t["items"], ns)
+			if err != nil {
+				return nil, err
+			}
+			return func(b []byte, v interface{}, isDefault bool) ([]byte, error) {
+				items, ok := v.([]interface{})
+				if !ok {
+					return nil, mismatch("array", v)
+				}
+				if len(items) > 0 {
+					b = appendLong(b, int64(len(items)))
+				}
+				for i, item := range items {
+					var err error
+					if b, err = encode(b, item, isDefault); err != nil {
+						return nil, fmt.Errorf("[%d]: %w", i, err)
+					}
+				}
+				return appendLong(b, 0), nil
+			}, nil
+		case "map":
+			encode, err := c.compileEncoder(t["values"], ns)
+			if err != nil {
+				return nil, err
+			}
+			return func(b []byte, v interface{}, isDefault bool) ([]byte, error) {
+				o, ok := v.(*object)
+				if !ok {
+					return nil, mismatch("map", v)
+				}
+				if len(o.keys) > 0 {
+					b = appendLong(b, int64(len(o.keys)))
+				}
+				for _, k := range o.keys {
+					b = appendString(b, k)
+					var err error
+					if b, err = encode(b, o.values[k], isDefault); err != nil {
+						return nil, fmt.Errorf("%s: %w", k, err)
+					}
+				}
+				return appendLong(b, 0), nil
+			}, nil
+		}
+		if primitives[typ] {
+			return primitiveEncoder(typ, t)
+		}
+		return c.compileEncoder(typ, ns)
+	}
+	return nil, errors.New("invalid avro type")
+}
+
+// compileNamedEncoder returns the encoder of a named type. The encoder is
+// shared and built once, so recursive types terminate.
+func (c *compiler) compileNamedEncoder(n named) (encodeFunc, error) {
+	if encode, ok := c.encoders[n.full]; ok {
+		return func(b []byte, v interface{}, isDefault bool) ([]byte, error) { return (*encode)(b, v, isDefault) }, nil
+	}
+	encode := new(encodeFunc)
+	c.encoders[n.full] = encode
+	var err error
+	switch n.def["type"] {
+	case "record", "error":
+		*encode, err = c.compileRecordEncoder(n.def, n.namespace)
+	case "enum":
+		*encode, err = enumEncoder(n.def)
+	case "fixed":
+		*encode, err = primitiveEncoder("fixed", n.def)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(b []byte, v interface{}, isDefault bool) ([]byte, error) { return (*encode)(b, v, isDefault) }, nil
+}
+
+// compileRecordEncoder returns the encoder of a record. A field is also
+// found under its aliases, which hold the original names of fields
+// renamed by a conversion.
+func (c *compiler) compileRecordEncoder(t map[string]interface{}, ns string) (encodeFunc, error) {
+	fields, ok := t["fields"].([]interface{})
+	if !ok {
+		return nil, errors.New("invalid avro record fields")
+	}
+	type fieldEncoder struct {
+		name       string
+		keys       []string
+		encode     encodeFunc
+		def        interface{}
+		hasDefault bool
+	}
+	encoders := make([]fieldEncoder, len(fields))
+	known := make(map[string]bool)
+	for i, f := range fields {
+		field, ok := f.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("invalid avro record field")
+		}
+		e := &encoders[i]
+		e.name, _ = field["name"].(string)
+		e.keys = []string{e.name}
+		if aliases, ok := field["aliases"].([]interface{}); ok {
+			for _, alias := range aliases {
+				if alias, ok := alias.(string); ok {
+					e.keys = append(e.keys, alias)
+				}
+			}
+		}
+		for _, key := range e.keys {
+			known[key] = true
+		}
+		var err error
+		if e.encode, err = c.compileEncoder(fieldType(field), ns); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.name, err)
+		}
+		if def, ok := field["default"]; ok {
+			e.def, e.hasDefault = ordered(def), true
+		}
+	}
+	return func(b []byte, v interface{}, isDefault bool) ([]byte, error) {
+		o, ok := v.(*object)
+		if !ok {
+			return nil, mismatch("record", v)
+		}
+		for _, k := range o.keys {
+			if !known[k] {
+				return nil, fmt.Errorf("unknown field %s", k)
+			}
+		}
+		for _, e := range encoders {
+			var value interface{}
+			found := false
+			for _, key := range e.keys {
+				if value, found = o.values[key]; found {
+					break
+				}
+			}
+			valueIsDefault := isDefault
+			if !found {
+				if !e.hasDefault {
+					return nil, fmt.Errorf("missing field %s", e.name)
+				}
+				value, valueIsDefault = e.def, true
+			}
+			var err error
+			if b, err = e.encode(b, value, valueIsDefault); err != nil {
+				return nil, fmt.Errorf("%s: %w", e.name, err)
+			}
+		}
+		return b, nil
+	}, nil
+}
+
+func (c *compiler) compileUnionEncoder(branches []interface{}, ns string) (encodeFunc, error) {
+	encoders := make([]encodeFunc, len(branches))
+	names := make(map[string]int)
+	null := -1
+	for i, branch := range branches {
+		if branch == "null" {
+			null = i
+		}
+		var err error
+		if encoders[i], err = c.compileEncoder(branch, ns); err != nil {
+			return nil, err
+		}
+		for _, name := range c.branchNames(branch, ns) {
+			if _, dup := names[name]; dup {
+				// An ambiguous short name only matches by full name.
+				names[name] = -1
+				continue
+			}
+			names[name] = i
+		}
+	}
+	return func(b []byte, v interface{}, isDefault bool) ([]byte, error) {
+		i := null
+		switch {
+		case isDefault:
+			i = 0
+		case v == nil:
+			if null < 0 {
+				return nil, errors.New("null is not a branch of the union")
+			}
+		default:
+			o, ok := v.(*object)
+			if !ok || len(o.keys) != 1 {
+				return nil, fmt.Errorf("union value must be null or an object with a single key, got %s", jsonType(v))
+			}
+			key := o.keys[0]
+			var known bool
+			if i, known = names[key]; !known || i < 0 {
+				return nil, fmt.Errorf("unknown union branch %s", key)
+			}
+			v = o.values[key]
+		}
+		return encoders[i](appendLong(b, int64(i)), v, isDefault)
+	}, nil
+}
+
+// branchNames returns the names a JSON union value may use for branch: the
+// full name of a named type, which its simple name also matches when it
+// is not ambiguous, and the type name otherwise, alone or followed by the
+// logical type, as in "long.timestamp-millis".
+func (c *compiler) branchNames(branch interface{}, ns string) []string {
+	switch b := branch.(type) {
+	case string:
+		if primitives[b] {
+			return []string{b}
+		}
+		if n, err := c.lookup(b, ns); err == nil {
+			return []string{n.full, unionBranchName(n.full)}
+		}
+	case map[string]interface{}:
+		typ, _ := b["type"].(string)
+		switch typ {
+		case "record", "error", "enum", "fixed":
+			full := definitionName(b, ns)
+			return []string{full, unionBranchName(full)}
+		case "":
+			return c.branchNames(b["type"], ns)
+		}
+		if logicalType, ok := b["logicalType"].(string); ok && primitives[typ] {
+			return []string{typ, typ + "." + logicalType}
+		}
+		if !primitives[typ] && typ != "array" && typ != "map" {
+			return c.branchNames(typ, ns)
+		}
+		return []string{typ}
+	}
+	return nil
+}
+
+func enumEncoder(t map[string]interface{}) (encodeFunc, error) {
+	symbols, ok := t["symbols"].([]interface{})
+	if !ok {
+		return nil, errors.New("invalid enum symbols")
+	}
+	return func(b []byte, v interface{}, _ bool) ([]byte, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, mismatch("enum symbol", v)
+		}
+		for i, symbol := range symbols {
+			if symbol == s {
+				return appendLong(b, int64(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("unknown enum symbol %q", s)
+	}, nil
+}
+
+// primitiveEncoder returns the encoder of a primitive or fixed type.
+func primitiveEncoder(typ string, props map[string]interface{}) (encodeFunc, error) {
+	switch typ {
+	case "null":
+		return func(b []byte, v interface{}, _ bool) ([]byte, error) {
+			if v != nil {
+				return nil, mismatch("null", v)
+			}
+			return b, nil
+		}, nil
+	case "boolean":
+		return func(b []byte, v interface{}, _ bool) ([]byte, error) {
+			t, ok := v.(bool)
+			if !ok {
+				return nil, mismatch("boolean", v)
+			}
+			if t {
+				return append(b, 1), nil
+			}
+			return append(b, 0), nil
+		}, nil
+	case "int", "long":
+		bits := 64
+		if typ == "int" {
+			bits = 32
+		}
+		return func(b []byte, v interface{}, _ bool) ([]byte, error) {
+			n, err := jsonInt(v, bits)
+			if err != nil {
+				return nil, err
+			}
+			return appendLong(b, n), nil
+		}, nil
+	case "float":
+		return func(b []byte, v interface{}, _ bool) ([]byte, error) {
+			f, err := jsonFloat(v, 32)
+			if err != nil {
+				return nil, err
+			}
+			var buf [4]byte
+			binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(f)))
+			return append(b, buf[:]...), nil
+		}, nil
+	case "double":
+		return func(b []byte, v interface{}, _ bool) ([]byte, error) {
+			f, err := jsonFloat(v, 64)
+			if err != nil {
+				return nil, err
+			}
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+			return append(b, buf[:]...), nil
+		}, nil
+	case "bytes":
+		return func(b []byte, v interface{}, _ bool) ([]byte, error) {
+			data, err := jsonBytes(v)
+			if err != nil {
+				return nil, err
+			}
+			return append(appendLong(b, int64(len(data))), data...), nil
+		}, nil
+	case "string":
+		return func(b []byte, v interface{}, _ bool) ([]byte, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, mismatch("string", v)
+			}
+			return appendString(b, s), nil
+		}, nil
+	case "fixed":
+		size, ok := props["size"].(float64)
+		if !ok || size < 0 || size != float64(int(size)) {
+			return nil, fmt.Errorf("invalid fixed size %v", props["size"])
+		}
+		n := int(size)
+		return func(b []byte, v interface{}, _ bool) ([]byte, error) {
+			data, err := jsonBytes(v)
+			if err != nil {
+				return nil, err
+			}
+			if len(data) != n {
+				return nil, fmt.Errorf("fixed value has %d bytes, want %d", len(data), n)
+			}
+			return append(b, data...), nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown avro type %s", typ)
+}
+
+// jsonInt returns the integer v, a json.Number or, in defaults, a
+// float64, checking that it fits bits bits.
+func jsonInt(v interface{}, bits int) (int64, error) {
+	var n int64
+	switch v := v.(type) {
+	case json.Number:
+		var err error
+		if n, err = strconv.ParseInt(string(v), 10, bits); err != nil {
+			return 0, fmt.Errorf("invalid int%d %s", bits, v)
+		}
+		return n, nil
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("invalid int%d %v", bits, v)
+		}
+		n = int64(v)
+		if bits == 32 && (n < math.MinInt32 || n > math.MaxInt32) {
+			return 0, fmt.Errorf("invalid int%d %v", bits, v)
+		}
+		return n, nil
+	}
+	return 0, mismatch("number", v)
+}
+
+// jsonFloat returns the number v, accepting the strings NaN, Infinity and
+// -Infinity that JSON cannot represent as numbers.
+func jsonFloat(v interface{}, bits int) (float64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), bits)
+		if err != nil {
+			return 0, fmt.Errorf("invalid float%d %s", bits, v)
+		}
+		return f, nil
+	case float64:
+		return v, nil
+	case string:
+		switch v {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+	}
+	return 0, mismatch("number", v)
+}
+
+// jsonBytes returns the bytes of v, a string whose code points are the
+// byte values.
+func jsonBytes(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, mismatch("bytes string", v)
+	}
+	b := make([]byte, 0, utf8.RuneCountInString(s))
+	for _, r := range s {
+		if r > 0xff {
+			return nil, fmt.Errorf("bytes string has code point %U above U+00FF", r)
+		}
+		b = append(b, byte(r))
+	}
+	return b, nil
+}
+
+func mismatch(want string, v interface{}) error {
+	return fmt.Errorf("expected %s, got %s", want, jsonType(v))
+}
+
+// jsonType describes the type of a parsed JSON value in errors.
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case *object:
+		return "object with keys " + strings.Join(v.keys, ", ")
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func appendLong(b []byte, n int64) []byte {
+	u := uint64(n<<1) ^ uint64(n>>63)
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendLong(b, int64(len(s))), s...)
+}
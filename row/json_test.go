@@ -0,0 +1,178 @@
+package row
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// kafkaConnect returns conversion options with the Kafka Connect profile,
+// which maps maps and unions of several types.
+func kafkaConnect(t *testing.T, naming schema.NamingStrategy) schema.ConvertOptions {
+	t.Helper()
+	profile, err := schema.ProfileByName(schema.ProfileKafkaConnect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema.ConvertOptions{Profile: profile, Naming: naming}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	address := record("Address", field("city", "string"), field("zip", []interface{}{"null", "string"}))
+	avroSchema := map[string]interface{}{
+		"type":      "record",
+		"name":      "User",
+		"namespace": "com.example",
+		"fields": []interface{}{
+			field("id", "long"),
+			field("score", "float"),
+			field("avatar", "bytes"),
+			field("email", []interface{}{"null", "string"}),
+			field("status", map[string]interface{}{"type": "enum", "name": "Status", "symbols": []interface{}{"ACTIVE", "BANNED"}}),
+			field("address", address),
+			field("previous", []interface{}{"null", "com.example.Address"}),
+			field("tags", map[string]interface{}{"type": "array", "items": "string", "name": "tag"}),
+			field("hash", map[string]interface{}{"type": "fixed", "name": "Hash", "size": float64(2)}),
+			field("price", decimal("bytes", 10, 2)),
+			field("payload", []interface{}{"null", "string", "long"}),
+			map[string]interface{}{"name": "source", "type": []interface{}{"null", "string"}, "default": nil},
+			map[string]interface{}{"name": "attempts", "type": "int", "default": float64(1)},
+		},
+	}
+	binary := encoder(nil).
+		long(42).float(1.1).bytes([]byte{0, 0xff}).
+		long(1).str("a@example.com").
+		long(1).
+		str("Paris").long(1).str("75001").
+		long(1).str("Lyon").long(0).
+		long(2).str("x").str("y").long(0).
+		fixed(0xca, 0xfe).
+		bytes([]byte{0xff, 0x85}).
+		long(2).long(9).
+		long(0).
+		long(1)
+	text := `{
+		"id": 42, "score": 1.1, "avatar": "\u0000ÿ",
+		"email": {"string": "a@example.com"},
+		"status": "BANNED",
+		"address": {"city": "Paris", "zip": {"string": "75001"}},
+		"previous": {"com.example.Address": {"city": "Lyon", "zip": null}},
+		"tags": ["x", "y"],
+		"hash": "Êþ",
+		"price": "ÿ\u0085",
+		"payload": {"long": 9}
+	}`
+
+	d := convert(t, avroSchema, kafkaConnect(t, nil))
+	want, err := d.Decode(binary)
+	if err != nil {
+		t.Fatalf("Error decoding binary record: %v", err)
+	}
+	got, err := d.DecodeJSON([]byte(text))
+	if err != nil {
+		t.Fatalf("Error decoding JSON record: %v", err)
+	}
+	if got["price"] != -1.23 {
+		t.Fatalf("Expected price -1.23, but got %v", got["price"])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, but got %v", want, got)
+	}
+
+	t.Run("short branch name", func(t *testing.T) {
+		text := strings.Replace(text, "com.example.Address", "Address", 1)
+		if _, err := d.DecodeJSON([]byte(text)); err != nil {
+			t.Fatalf("Error decoding JSON record: %v", err)
+		}
+	})
+
+	invalid := map[string]string{
+		"unwrapped union":  strings.Replace(text, `{"string": "a@example.com"}`, `"a@example.com"`, 1),
+		"unknown branch":   strings.Replace(text, `{"long": 9}`, `{"int": 9}`, 1),
+		"unknown symbol":   strings.Replace(text, `"BANNED"`, `"DELETED"`, 1),
+		"missing field":    strings.Replace(text, `"id": 42,`, ``, 1),
+		"unknown field":    strings.Replace(text, `"id": 42,`, `"id": 42, "extra": 1,`, 1),
+		"fractional long":  strings.Replace(text, `"id": 42`, `"id": 4.2`, 1),
+		"wide bytes":       strings.Replace(text, `ÿ"`, `Ā"`, 1),
+		"fixed size":       strings.Replace(text, `"Êþ"`, `"Ê"`, 1),
+		"trailing data":    text + `{}`,
+		"truncated":        text[:len(text)-2],
+		"duplicate key":    strings.Replace(text, `"id": 42,`, `"id": 42, "id": 43,`, 1),
+		"int out of range": strings.Replace(text, `"payload"`, `"attempts": 3000000000, "payload"`, 1),
+	}
+	for name, text := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := d.DecodeJSON([]byte(text)); err == nil {
+				t.Fatalf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestDecodeJSONDefaults(t *testing.T) {
+	avroSchema := record("R",
+		field("id", "long"),
+		map[string]interface{}{"name": "note", "type": []interface{}{"string", "null"}, "default": "none"},
+		map[string]interface{}{"name": "ratio", "type": "double", "default": 0.5},
+		map[string]interface{}{"name": "counts", "type": map[string]interface{}{"type": "map", "values": "long"}, "default": map[string]interface{}{"b": float64(2), "a": float64(1)}},
+		map[string]interface{}{"name": "origin", "type": record("Origin", field("host", "string"), map[string]interface{}{"name": "port", "type": "int", "default": float64(80)}), "default": map[string]interface{}{"host": "localhost"}},
+	)
+	d := convert(t, avroSchema, kafkaConnect(t, nil))
+	got, err := d.DecodeJSON([]byte(`{"id": 1}`))
+	if err != nil {
+		t.Fatalf("Error decoding JSON record: %v", err)
+	}
+	want := Row{
+		"id":    int64(1),
+		"note":  "none",
+		"ratio": 0.5,
+		"counts": []bigquery.Value{
+			map[string]bigquery.Value{"key": "a", "value": int64(1)},
+			map[string]bigquery.Value{"key": "b", "value": int64(2)},
+		},
+		"origin": map[string]bigquery.Value{"host": "localhost", "port": int64(80)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, but got %v", want, got)
+	}
+}
+
+func TestJSONReader(t *testing.T) {
+	avroSchema := record("Event",
+		field("eventId", "string"),
+		field("attributes", map[string]interface{}{"type": "map", "values": "long"}),
+	)
+	d := convert(t, avroSchema, kafkaConnect(t, schema.NamingSnakeCase))
+	input := `{"eventId": "e1", "attributes": {"z": 1, "a": 2}}
+{"eventId": "e2", "attributes": {}}
+{"eventId": 3, "attributes": {}}
+`
+	r := NewJSONReader(strings.NewReader(input), d)
+	first, err := r.Read()
+	if err != nil {
+		t.Fatalf("Error reading record: %v", err)
+	}
+	want := Row{
+		"event_id": "e1",
+		"attributes": []bigquery.Value{
+			map[string]bigquery.Value{"key": "z", "value": int64(1)},
+			map[string]bigquery.Value{"key": "a", "value": int64(2)},
+		},
+	}
+	if !reflect.DeepEqual(first, want) {
+		t.Fatalf("Expected %v, but got %v", want, first)
+	}
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("Error reading record: %v", err)
+	}
+	if _, err := r.Read(); err == nil || !strings.HasPrefix(err.Error(), "record 3:") {
+		t.Fatalf("Expected an error for record 3, but got %v", err)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("Expected io.EOF, but got %v", err)
+	}
+}
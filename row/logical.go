@@ -0,0 +1,211 @@
+package row
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+)
+
+// valueConverter converts a raw Avro value to the value of a column.
+type valueConverter func(v bigquery.Value) (bigquery.Value, error)
+
+// Bounds of the BigQuery NUMERIC and BIGNUMERIC types.
+var (
+	maxNumeric, _    = new(big.Rat).SetString("99999999999999999999999999999.999999999")
+	maxBigNumeric, _ = new(big.Rat).SetString("578960446186580977117854925043439539266.34992332820282019728792003956564819967")
+	minBigNumeric, _ = new(big.Rat).SetString("-578960446186580977117854925043439539266.34992332820282019728792003956564819968")
+)
+
+const (
+	numericScale    = 9
+	bigNumericScale = 38
+)
+
+// Bounds of the BigQuery DATE, DATETIME and TIMESTAMP types.
+var (
+	minTime = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxTime = time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// logicalConverter returns the conversion of raw values of the Avro type
+// typ with the logical type logicalType to values of col, or nil when raw
+// values fit the column as they are. A logical type whose column has
+// another type than the one it maps to, such as a date in an INTEGER
+// column, keeps its raw value:
+//
+//	decimal                      NUMERIC, BIGNUMERIC: *big.Rat
+//	                             STRING: decimal string; FLOAT: float64
+//	date                         DATE: civil.Date
+//	time-millis, time-micros     TIME: civil.Time
+//	timestamp-millis, -micros    TIMESTAMP: time.Time in UTC
+//	local-timestamp-millis, -micros
+//	                             DATETIME: civil.DateTime
+//
+// Strings in JSON columns must be valid JSON.
+func logicalConverter(typ, logicalType string, props map[string]interface{}, col *bigquery.FieldSchema) (valueConverter, error) {
+	switch {
+	case logicalType == "decimal" && (typ == "bytes" || typ == "fixed"):
+		return decimalConverter(props, col)
+	case logicalType == "date" && typ == "int" && col.Type == bigquery.DateFieldType:
+		return func(v bigquery.Value) (bigquery.Value, error) {
+			t := time.Unix(v.(int64)*86400, 0).UTC()
+			if err := checkTime(t); err != nil {
+				return nil, err
+			}
+			return civil.DateOf(t), nil
+		}, nil
+	case logicalType == "time-millis" && typ == "int" && col.Type == bigquery.TimeFieldType:
+		return timeOfDay(time.Millisecond), nil
+	case logicalType == "time-micros" && typ == "long" && col.Type == bigquery.TimeFieldType:
+		return timeOfDay(time.Microsecond), nil
+	case typ == "long" && col.Type == bigquery.TimestampFieldType:
+		switch logicalType {
+		case "timestamp-millis":
+			return instant(time.UnixMilli, false), nil
+		case "timestamp-micros":
+			return instant(time.UnixMicro, false), nil
+		}
+	case typ == "long" && col.Type == bigquery.DateTimeFieldType:
+		switch logicalType {
+		case "local-timestamp-millis":
+			return instant(time.UnixMilli, true), nil
+		case "local-timestamp-micros":
+			return instant(time.UnixMicro, true), nil
+		}
+	case typ == "string" && col.Type == bigquery.JSONFieldType:
+		return func(v bigquery.Value) (bigquery.Value, error) {
+			if !json.Valid([]byte(v.(string))) {
+				return nil, errors.New("invalid JSON in JSON column")
+			}
+			return v, nil
+		}, nil
+	}
+	return nil, nil
+}
+
+// timeOfDay converts a time of day in units since midnight.
+func timeOfDay(unit time.Duration) valueConverter {
+	return func(v bigquery.Value) (bigquery.Value, error) {
+		d := time.Duration(v.(int64))
+		if d < 0 || d >= 24*time.Hour/unit {
+			return nil, fmt.Errorf("time of day %d out of range", d)
+		}
+		return civil.TimeOf(time.Unix(0, 0).UTC().Add(d * unit)), nil
+	}
+}
+
+// instant converts a point in time since the epoch, as a time.Time, or as
+// a civil.DateTime when local is set.
+func instant(fromEpoch func(int64) time.Time, local bool) valueConverter {
+	return func(v bigquery.Value) (bigquery.Value, error) {
+		t := fromEpoch(v.(int64)).UTC()
+		if err := checkTime(t); err != nil {
+			return nil, err
+		}
+		if local {
+			return civil.DateTimeOf(t), nil
+		}
+		return t, nil
+	}
+}
+
+func checkTime(t time.Time) error {
+	if t.Before(minTime) || !t.Before(maxTime) {
+		return fmt.Errorf("%s is out of the BigQuery range", t.Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// decimalConverter converts the big-endian two's-complement unscaled value
+// of a decimal to the type of col, checking that it fits NUMERIC and
+// BIGNUMERIC columns, including their precision and scale parameters.
+func decimalConverter(props map[string]interface{}, col *bigquery.FieldSchema) (valueConverter, error) {
+	precision, _ := props["precision"].(float64)
+	scaleProp, _ := props["scale"].(float64)
+	if precision <= 0 || scaleProp < 0 || scaleProp > precision || scaleProp != math.Trunc(scaleProp) {
+		return nil, fmt.Errorf("invalid decimal precision %v and scale %v", props["precision"], props["scale"])
+	}
+	scale := int64(scaleProp)
+	denominator := pow10(scale)
+
+	var check func(unscaled *big.Int, r *big.Rat) error
+	switch col.Type {
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		check = decimalCheck(col, scale)
+	case bigquery.StringFieldType, bigquery.FloatFieldType:
+	default:
+		// BYTES, as with the legacy load profile, keeps the raw bytes.
+		return nil, nil
+	}
+
+	return func(v bigquery.Value) (bigquery.Value, error) {
+		unscaled := twosComplement(v.([]byte))
+		r := new(big.Rat).SetFrac(unscaled, denominator)
+		switch col.Type {
+		case bigquery.StringFieldType:
+			return r.FloatString(int(scale)), nil
+		case bigquery.FloatFieldType:
+			f, _ := r.Float64()
+			return f, nil
+		}
+		if err := check(unscaled, r); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}, nil
+}
+
+// decimalCheck returns the overflow check of decimals of the given scale
+// in the NUMERIC or BIGNUMERIC column col.
+func decimalCheck(col *bigquery.FieldSchema, scale int64) func(unscaled *big.Int, r *big.Rat) error {
+	maxScale, min, max := int64(numericScale), new(big.Rat).Neg(maxNumeric), maxNumeric
+	if col.Type == bigquery.BigNumericFieldType {
+		maxScale, min, max = bigNumericScale, minBigNumeric, maxBigNumeric
+	}
+	if col.Precision > 0 {
+		// NUMERIC(P, S) holds P-S integer digits and S fractional digits.
+		maxScale = col.Scale
+		limit := new(big.Rat).SetFrac(new(big.Int).Sub(pow10(col.Precision), big.NewInt(1)), pow10(col.Scale))
+		min, max = new(big.Rat).Neg(limit), limit
+	}
+	var excess *big.Int
+	if scale > maxScale {
+		excess = pow10(scale - maxScale)
+	}
+	return func(unscaled *big.Int, r *big.Rat) error {
+		if excess != nil && new(big.Int).Rem(unscaled, excess).Sign() != 0 {
+			return fmt.Errorf("decimal %s has more than %d fractional digits", r.FloatString(int(scale)), maxScale)
+		}
+		if r.Cmp(min) < 0 || r.Cmp(max) > 0 {
+			return fmt.Errorf("decimal %s overflows %s", r.FloatString(int(scale)), columnType(col))
+		}
+		return nil
+	}
+}
+
+func columnType(col *bigquery.FieldSchema) string {
+	if col.Precision > 0 {
+		return fmt.Sprintf("%s(%d, %d)", col.Type, col.Precision, col.Scale)
+	}
+	return string(col.Type)
+}
+
+// twosComplement returns the integer of the big-endian two's-complement
+// bytes b.
+func twosComplement(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return n
+}
+
+func pow10(n int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil)
+}
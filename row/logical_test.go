@@ -0,0 +1,162 @@
+package row
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+func logical(typ, logicalType string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "logicalType": logicalType}
+}
+
+func decimal(typ string, precision, scale float64) map[string]interface{} {
+	t := map[string]interface{}{"type": typ, "logicalType": "decimal", "precision": precision, "scale": scale}
+	if typ == "fixed" {
+		t["name"] = "Amount"
+		t["size"] = float64(8)
+	}
+	return t
+}
+
+func TestLogicalTypes(t *testing.T) {
+	avroSchema := record("Event",
+		field("day", logical("int", "date")),
+		field("at_millis", logical("long", "timestamp-millis")),
+		field("at_micros", logical("long", "timestamp-micros")),
+		field("local", logical("long", "local-timestamp-millis")),
+		field("time_millis", logical("int", "time-millis")),
+		field("time_micros", logical("long", "time-micros")),
+		field("price", decimal("bytes", 10, 2)),
+		field("balance", decimal("fixed", 18, 4)),
+		field("payload", map[string]interface{}{"type": "string", "sqlType": "JSON"}),
+		field("id", logical("string", "uuid")),
+	)
+	data := encoder(nil).
+		long(19000).
+		long(1700000000123).
+		long(1700000000123456).
+		long(1700000000123).
+		long(3723004).
+		long(3723000005).
+		bytes([]byte{0xff, 0x85}).              // -123
+		fixed(0, 0, 0, 0, 0, 0x01, 0xe2, 0x40). // 123456
+		str(`{"a": 1}`).
+		str("7c9e6679-7425-40de-944b-e07fc1f90ae7")
+
+	d := convert(t, avroSchema, schema.ConvertOptions{})
+	row, err := d.Decode(data)
+	if err != nil {
+		t.Fatalf("Error decoding record: %v", err)
+	}
+	want := Row{
+		"day":         civil.Date{Year: 2022, Month: time.January, Day: 8},
+		"at_millis":   time.Date(2023, time.November, 14, 22, 13, 20, 123000000, time.UTC),
+		"at_micros":   time.Date(2023, time.November, 14, 22, 13, 20, 123456000, time.UTC),
+		"local":       civil.DateTime{Date: civil.Date{Year: 2023, Month: time.November, Day: 14}, Time: civil.Time{Hour: 22, Minute: 13, Second: 20, Nanosecond: 123000000}},
+		"time_millis": civil.Time{Hour: 1, Minute: 2, Second: 3, Nanosecond: 4000000},
+		"time_micros": civil.Time{Hour: 1, Minute: 2, Second: 3, Nanosecond: 5000},
+		"price":       big.NewRat(-123, 100),
+		"balance":     big.NewRat(123456, 10000),
+		"payload":     `{"a": 1}`,
+		"id":          "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+	}
+	for name, w := range want {
+		got := row[name]
+		if r, ok := w.(*big.Rat); ok {
+			if g, isRat := got.(*big.Rat); !isRat || g.Cmp(r) != 0 {
+				t.Fatalf("Expected %s to be %v, but got %v", name, r, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Fatalf("Expected %s to be %v (%T), but got %v (%T)", name, w, w, got, got)
+		}
+	}
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		d := convert(t, record("R", field("payload", map[string]interface{}{"type": "string", "sqlType": "JSON"})), schema.ConvertOptions{})
+		if _, err := d.Decode(encoder(nil).str("{")); err == nil {
+			t.Fatalf("Expected an error for invalid JSON")
+		}
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		d := convert(t, record("R", field("at", logical("long", "timestamp-millis"))), schema.ConvertOptions{})
+		if _, err := d.Decode(encoder(nil).long(300000000000000)); err == nil {
+			t.Fatalf("Expected an error for a timestamp after year 9999")
+		}
+	})
+}
+
+func TestDecimalColumns(t *testing.T) {
+	amount := record("R", field("amount", decimal("bytes", 12, 3)))
+	data := encoder(nil).bytes([]byte{0x30, 0x39}) // 12.345
+
+	cases := map[string]struct {
+		column bigquery.FieldSchema
+		want   bigquery.Value
+		fails  bool
+	}{
+		"numeric":          {column: bigquery.FieldSchema{Type: bigquery.NumericFieldType}, want: big.NewRat(12345, 1000)},
+		"string":           {column: bigquery.FieldSchema{Type: bigquery.StringFieldType}, want: "12.345"},
+		"float":            {column: bigquery.FieldSchema{Type: bigquery.FloatFieldType}, want: 12.345},
+		"bytes":            {column: bigquery.FieldSchema{Type: bigquery.BytesFieldType}, want: []byte{0x30, 0x39}},
+		"precision fits":   {column: bigquery.FieldSchema{Type: bigquery.NumericFieldType, Precision: 5, Scale: 3}, want: big.NewRat(12345, 1000)},
+		"integer overflow": {column: bigquery.FieldSchema{Type: bigquery.NumericFieldType, Precision: 4, Scale: 3}, fails: true},
+		"scale overflow":   {column: bigquery.FieldSchema{Type: bigquery.NumericFieldType, Precision: 4, Scale: 2}, fails: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			c.column.Name = "amount"
+			d, err := NewDecoder(amount, bigquery.Schema{&c.column})
+			if err != nil {
+				t.Fatalf("Error creating decoder: %v", err)
+			}
+			row, err := d.Decode(data)
+			if c.fails {
+				if err == nil {
+					t.Fatalf("Expected an overflow error, but got %v", row["amount"])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error decoding record: %v", err)
+			}
+			got := row["amount"]
+			if r, ok := c.want.(*big.Rat); ok {
+				if g, isRat := got.(*big.Rat); !isRat || g.Cmp(r) != 0 {
+					t.Fatalf("Expected %v, but got %v", r, got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("Expected %v (%T), but got %v (%T)", c.want, c.want, got, got)
+			}
+		})
+	}
+
+	t.Run("numeric overflow", func(t *testing.T) {
+		big40 := record("R", field("amount", decimal("bytes", 40, 0)))
+		d, err := NewDecoder(big40, bigquery.Schema{{Name: "amount", Type: bigquery.NumericFieldType}})
+		if err != nil {
+			t.Fatalf("Error creating decoder: %v", err)
+		}
+		unscaled := new(big.Int).Exp(big.NewInt(10), big.NewInt(30), nil).Bytes()
+		if _, err := d.Decode(encoder(nil).bytes(unscaled)); err == nil {
+			t.Fatalf("Expected 10^30 to overflow NUMERIC")
+		}
+		d, err = NewDecoder(big40, bigquery.Schema{{Name: "amount", Type: bigquery.BigNumericFieldType}})
+		if err != nil {
+			t.Fatalf("Error creating decoder: %v", err)
+		}
+		if _, err := d.Decode(encoder(nil).bytes(unscaled)); err != nil {
+			t.Fatalf("Expected 10^30 to fit BIGNUMERIC, got %v", err)
+		}
+	})
+}
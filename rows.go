@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/ocf"
+	"github.com/go-syar/avro-schema-bq/row"
+	"github.com/go-syar/avro-schema-bq/schema"
+	"github.com/go-syar/avro-schema-bq/table"
+)
+
+// runRows implements the "rows" subcommand, which decodes the records of
+// an Avro data file, or of a file of records in the Avro JSON encoding
+// with -schema, into rows of the converted BigQuery schema and writes them
+// to stdout as JSON lines. It returns the process exit code.
+func runRows(args []string) int {
+	fs := flag.NewFlagSet("rows", flag.ContinueOnError)
+	profile := fs.String("profile", "", "mapping profile: "+strings.Join(schema.ProfileNames(), ", "))
+	naming := fs.String("naming", "as-is", "column naming strategy: as-is, snake_case or lowerCamel")
+	enumMode := fs.String("enum", string(schema.EnumAsString), "enum mapping: string, symbols, ordinal or check")
+	schemaPath := fs.String("schema", "", "Avro schema (.avsc) of input records in the Avro JSON encoding")
+	skipCorrupt := fs.Bool("skip-corrupt", false, "skip corrupt blocks, resuming at the next sync marker")
+	limit := fs.Int("limit", 0, "stop after this many rows (0: all)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: rows [flags] data.avro | rows -schema schema.avsc [flags] records.json")
+		fs.PrintDefaults()
+		return 2
+	}
+
+	opts := ocf.ReaderOptions{SkipCorrupt: *skipCorrupt}
+	if *profile != "" {
+		p, err := schema.ProfileByName(*profile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 2
+		}
+		opts.Convert.Profile = p
+	}
+	strategy, err := schema.NamingStrategyByName(*naming)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	opts.Convert.Naming = strategy
+	mode, err := schema.EnumModeByName(*enumMode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 2
+	}
+	if mode != schema.EnumAsString {
+		opts.Convert.Enums = &schema.EnumOptions{Mode: mode}
+	}
+
+	var read func() (row.Row, error)
+	var s bigquery.Schema
+	var corruptions func() []*ocf.CorruptBlockError
+	if *schemaPath != "" {
+		avroSchema, err := table.ReadAvroSchemaFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading Avro schema:", err)
+			return 1
+		}
+		conversion, err := schema.ConvertAvroToBigQueryWithOptions(avroSchema, opts.Convert)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		d, err := row.NewConversionDecoder(conversion)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		defer f.Close()
+		read, s = row.NewJSONReader(bufio.NewReader(f), d).Read, conversion.Schema
+	} else {
+		r, err := ocf.OpenFile(fs.Arg(0), opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		defer r.Close()
+		read, s, corruptions = r.Read, r.Schema(), r.Corruptions
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+	for n := 0; *limit <= 0 || n < *limit; n++ {
+		rw, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Flush()
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		if err := enc.Encode(jsonRecord(rw, s)); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing row:", err)
+			return 1
+		}
+	}
+	if corruptions != nil {
+		for _, c := range corruptions() {
+			fmt.Fprintln(os.Stderr, "Skipped", c)
+		}
+	}
+	return 0
+}
+
+// jsonRecord returns the values of a record of s for JSON output, with
+// NUMERIC and BIGNUMERIC values as decimal strings.
+func jsonRecord(values map[string]bigquery.Value, s bigquery.Schema) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for name, v := range values {
+		var col *bigquery.FieldSchema
+		for _, f := range s {
+			if f.Name == name {
+				col = f
+				break
+			}
+		}
+		out[name] = jsonValue(v, col)
+	}
+	return out
+}
+
+func jsonValue(v bigquery.Value, col *bigquery.FieldSchema) interface{} {
+	switch v := v.(type) {
+	case []bigquery.Value:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = jsonValue(e, col)
+		}
+		return values
+	case map[string]bigquery.Value:
+		if col == nil {
+			return v
+		}
+		return jsonRecord(v, col.Schema)
+	case *big.Rat:
+		if col != nil && col.Type == bigquery.BigNumericFieldType {
+			return bigquery.BigNumericString(v)
+		}
+		return bigquery.NumericString(v)
+	}
+	return v
+}
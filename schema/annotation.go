@@ -0,0 +1,174 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// AnnotationPrefix starts the names of the custom Avro properties that
+// control the BigQuery column of a field. They may be set on a field or on
+// its type, including the non-null branch of a nullable union; properties
+// of the field take precedence:
+//
+//	bq.type         BigQuery type, e.g. GEOGRAPHY, JSON or BIGNUMERIC
+//	bq.mode         NULLABLE, REQUIRED or REPEATED
+//	bq.description  column description, replacing the field doc
+//	bq.maxLength    maximum length of a STRING or BYTES column
+//	bq.collation    collation of a STRING column, e.g. "und:ci"
+//	bq.policyTags   policy tag resource name or ID, or a list of one
+//
+// bq.type RANGE and bq.roundingMode are rejected, as the BigQuery client
+// this package is built with cannot express them and ignoring them would
+// create a column with the wrong type or rounding. Other unknown
+// properties starting with the prefix are ignored with a warning, or
+// rejected with ConvertOptions.StrictAnnotations.
+const AnnotationPrefix = "bq."
+
+// Recognized annotation properties.
+const (
+	annotationType        = AnnotationPrefix + "type"
+	annotationMode        = AnnotationPrefix + "mode"
+	annotationDescription = AnnotationPrefix + "description"
+	annotationMaxLength   = AnnotationPrefix + "maxLength"
+	annotationCollation   = AnnotationPrefix + "collation"
+	annotationPolicyTags  = AnnotationPrefix + "policyTags"
+	// Recognized but not supported by the BigQuery client.
+	annotationRoundingMode = AnnotationPrefix + "roundingMode"
+)
+
+// fieldAnnotations returns the annotation properties of an Avro field,
+// merged over those of its type.
+func fieldAnnotations(field map[string]interface{}) map[string]interface{} {
+	props := make(map[string]interface{})
+	collectAnnotations(typeAnnotated(field["type"]), props)
+	collectAnnotations(field, props)
+	return props
+}
+
+// typeAnnotated returns the Avro type t, or the only non-null branch of
+// the union t, if it is a map that may carry annotations.
+func typeAnnotated(t interface{}) map[string]interface{} {
+	switch t := t.(type) {
+	case []interface{}:
+		var branch interface{}
+		for _, b := range t {
+			if b == "null" {
+				continue
+			}
+			if branch != nil {
+				return nil
+			}
+			branch = b
+		}
+		return typeAnnotated(branch)
+	case map[string]interface{}:
+		return t
+	}
+	return nil
+}
+
+func collectAnnotations(m map[string]interface{}, props map[string]interface{}) {
+	for k, v := range m {
+		if strings.HasPrefix(k, AnnotationPrefix) {
+			props[k] = v
+		}
+	}
+}
+
+// applyAnnotations applies the annotation properties of every field,
+// indexed by column path, to the columns of s. It returns a warning for
+// each unknown property, or fails on the first one when strict.
+func applyAnnotations(s bigquery.Schema, annotations map[string]map[string]interface{}, strict bool) ([]string, error) {
+	paths := make([]string, 0, len(annotations))
+	for path := range annotations {
+		paths = append(paths, path)
+	}
+	// Parents first, so that annotations of fields dropped along with
+	// their record are skipped.
+	sort.Strings(paths)
+	var warnings []string
+	for _, path := range paths {
+		f := lookupField(s, path)
+		if f == nil {
+			continue
+		}
+		unknown, err := applyAnnotation(f, annotations[path])
+		if err == nil && strict && len(unknown) > 0 {
+			err = fmt.Errorf("unknown annotation %s", unknown[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, k := range unknown {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown annotation %s ignored", path, k))
+		}
+	}
+	return warnings, nil
+}
+
+// applyAnnotation applies props to f, and returns the unknown properties
+// among them, sorted.
+func applyAnnotation(f *bigquery.FieldSchema, props map[string]interface{}) ([]string, error) {
+	var unknown []string
+	var override FieldOverride
+	var policyTags []string
+	collation, hasCollation := "", false
+	for k, v := range props {
+		var ok bool
+		switch k {
+		case annotationType:
+			override.Type, ok = v.(string)
+			if ok && strings.EqualFold(override.Type, "RANGE") {
+				return nil, fmt.Errorf("%s RANGE is not supported by the BigQuery client", k)
+			}
+		case annotationMode:
+			override.Mode, ok = v.(string)
+		case annotationDescription:
+			override.Description, ok = v.(string)
+		case annotationMaxLength:
+			var n float64
+			n, ok = v.(float64)
+			ok = ok && n > 0 && n == float64(int64(n))
+			override.MaxLength = int64(n)
+		case annotationCollation:
+			collation, ok = v.(string)
+			hasCollation = true
+		case annotationPolicyTags:
+			if tag, isString := v.(string); isString {
+				policyTags, ok = []string{tag}, true
+			} else if list, isList := v.([]interface{}); isList {
+				policyTags = stringList(list)
+				ok = len(policyTags) == len(list)
+			}
+		case annotationRoundingMode:
+			return nil, fmt.Errorf("%s is not supported by the BigQuery client", k)
+		default:
+			unknown = append(unknown, k)
+			continue
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value %v", k, v)
+		}
+	}
+	sort.Strings(unknown)
+
+	if err := override.validate(); err != nil {
+		return nil, err
+	}
+	if err := override.applyTo(f); err != nil {
+		return nil, err
+	}
+	if hasCollation {
+		if f.Type != bigquery.StringFieldType {
+			return nil, fmt.Errorf("%s requires a STRING column, not %s", annotationCollation, f.Type)
+		}
+		f.Collation = collation
+	}
+	if len(policyTags) > 0 {
+		f.PolicyTags = &bigquery.PolicyTagList{Names: policyTags}
+	}
+	return unknown, nil
+}
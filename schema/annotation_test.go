@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestConvertAvroToBigQueryWithAnnotations(t *testing.T) {
+	record := func(fields ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"type": "record", "name": "Place", "fields": fields}
+	}
+
+	t.Run("annotations", func(t *testing.T) {
+		avroSchema := record(
+			map[string]interface{}{"name": "location", "type": "string", "bq.type": "GEOGRAPHY", "bq.description": "WKT point."},
+			map[string]interface{}{"name": "attributes", "type": []interface{}{"null", map[string]interface{}{"type": "string", "bq.type": "JSON"}}},
+			map[string]interface{}{"name": "amount", "type": map[string]interface{}{"type": "string", "bq.type": "BIGNUMERIC"}, "bq.mode": "NULLABLE"},
+			map[string]interface{}{"name": "code", "type": "string", "bq.maxLength": float64(8), "bq.collation": "und:ci"},
+			map[string]interface{}{"name": "owner", "type": "string", "bq.policyTags": []interface{}{"projects/p/locations/eu/taxonomies/1/policyTags/2"}},
+			map[string]interface{}{"name": "payload", "type": map[string]interface{}{"type": "string", "sqlType": "JSON"}},
+		)
+		s, err := ConvertAvroToBigQuery(avroSchema)
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		want := []struct {
+			typ      bigquery.FieldType
+			required bool
+		}{
+			{bigquery.GeographyFieldType, true},
+			{bigquery.JSONFieldType, false},
+			{bigquery.BigNumericFieldType, false},
+			{bigquery.StringFieldType, true},
+			{bigquery.StringFieldType, true},
+			{bigquery.JSONFieldType, true},
+		}
+		for i, w := range want {
+			if s[i].Type != w.typ || s[i].Required != w.required {
+				t.Fatalf("Expected %s to be %s (required %t), but got %s (required %t)", s[i].Name, w.typ, w.required, s[i].Type, s[i].Required)
+			}
+		}
+		if s[0].Description != "WKT point." {
+			t.Fatalf("Expected the bq.description, but got %q", s[0].Description)
+		}
+		if s[3].MaxLength != 8 || s[3].Collation != "und:ci" {
+			t.Fatalf("Expected maxLength 8 and collation und:ci, but got %d and %q", s[3].MaxLength, s[3].Collation)
+		}
+		if s[4].PolicyTags == nil || s[4].PolicyTags.Names[0] != "projects/p/locations/eu/taxonomies/1/policyTags/2" {
+			t.Fatalf("Expected the policy tag, but got %+v", s[4].PolicyTags)
+		}
+	})
+
+	t.Run("field wins over type", func(t *testing.T) {
+		avroSchema := record(map[string]interface{}{
+			"name":    "n",
+			"type":    map[string]interface{}{"type": "string", "bq.type": "JSON"},
+			"bq.type": "STRING",
+		})
+		s, err := ConvertAvroToBigQuery(avroSchema)
+		if err != nil || s[0].Type != bigquery.StringFieldType {
+			t.Fatalf("Expected a STRING column, but got %+v, %v", s, err)
+		}
+	})
+
+	t.Run("override wins over annotation", func(t *testing.T) {
+		avroSchema := record(map[string]interface{}{"name": "n", "type": "string", "bq.type": "JSON"})
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Overrides: Overrides{"n": {Type: "STRING"}}})
+		if err != nil || conversion.Schema[0].Type != bigquery.StringFieldType {
+			t.Fatalf("Expected a STRING column, but got %+v, %v", conversion, err)
+		}
+	})
+
+	t.Run("unknown annotations", func(t *testing.T) {
+		avroSchema := record(map[string]interface{}{"name": "n", "type": "string", "bq.size": float64(3), "bq.scale": float64(2)})
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{})
+		if err != nil {
+			t.Fatalf("Expected unknown annotations to be ignored, but got %v", err)
+		}
+		expected := []string{"n: unknown annotation bq.scale ignored", "n: unknown annotation bq.size ignored"}
+		if !reflect.DeepEqual(conversion.Warnings, expected) {
+			t.Fatalf("Expected warnings %q, but got %q", expected, conversion.Warnings)
+		}
+		if _, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{StrictAnnotations: true}); err == nil || err.Error() != "n: unknown annotation bq.scale" {
+			t.Fatalf("Expected an unknown annotation error in strict mode, but got %v", err)
+		}
+	})
+
+	invalid := map[string]map[string]interface{}{
+		"unknown type":            {"name": "n", "type": "string", "bq.type": "VARCHAR"},
+		"range":                   {"name": "n", "type": "string", "bq.type": "RANGE"},
+		"rounding mode":           {"name": "n", "type": "bytes", "logicalType": "decimal", "precision": float64(10), "bq.roundingMode": "ROUND_HALF_EVEN"},
+		"fractional maxLength":    {"name": "n", "type": "string", "bq.maxLength": 1.5},
+		"collation on integer":    {"name": "n", "type": "long", "bq.collation": "und:ci"},
+		"invalid policy tag list": {"name": "n", "type": "string", "bq.policyTags": []interface{}{1}},
+	}
+	for name, field := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ConvertAvroToBigQuery(record(field)); err == nil {
+				t.Fatalf("Expected an error for %v", field)
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package schema
 
 import (
 	"fmt"
-	"reflect"
 	"strings"
 
 	"cloud.google.com/go/bigquery"
@@ -16,26 +15,83 @@ import (
 // bigquery.FieldSchema with metadata like name, type, and description.
 // The resulting BigQuery schema fields are returned as a slice.
 // If any invalid field or type is encountered, an error is returned.
+// The converted schema is checked with ValidateBigQuerySchema, so schemas
+// BigQuery would reject are reported before any API call. Types are mapped
+// with DefaultTypeMapper. A reference to a named type converts like its
+// definition; recursive references are rejected.
+func ConvertAvroToBigQuery(avroSchema map[string]interface{}) ([]*bigquery.FieldSchema, error) {
+	conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return conversion.Schema, nil
+}
 
-func checkPrecisionScale(fieldType map[string]interface{}) (int64, int64) {
-	if fieldType["logicalType"].(string) == "decimal" {
-		precisionValue, ok := fieldType["precision"].(float64)
-		if !ok {
-			return 0, 0
-		}
-		fmt.Println("precisionValue:", precisionValue)
-		scale, ok := fieldType["scale"].(float64)
-		if !ok {
-			return int64(precisionValue), 0
-		}
-		fmt.Println("scale:", scale)
-		return int64(precisionValue), int64(scale)
+// converter converts Avro fields to BigQuery columns, mapping every type
+// with mapper. profile, when non-nil, selects the structural mapping of
+// arrays, unions and defaults.
+type converter struct {
+	mapper  TypeMapper
+	profile *Profile
+	// named holds the named types defined so far by full name, so that
+	// every reference to a type converts like its definition.
+	named map[string]map[string]interface{}
+	// open holds the full names of the records being converted, to reject
+	// recursive references.
+	open map[string]bool
+	// ns is the namespace that names resolve against.
+	ns string
+}
+
+func newConverter(mapper TypeMapper, profile *Profile) *converter {
+	if mapper == nil && profile != nil {
+		mapper = profile.TypeMapper
 	}
-	return 0, 0
+	if mapper == nil {
+		mapper = DefaultTypeMapper
+	}
+	return &converter{mapper: mapper, profile: profile, named: make(map[string]map[string]interface{}), open: make(map[string]bool)}
 }
 
-func ConvertAvroToBigQuery(avroSchema map[string]interface{}) ([]*bigquery.FieldSchema, error) {
-	var fields []*bigquery.FieldSchema
+// convertSchema converts the fields of the record avroSchema, the root of
+// the schema.
+func (c *converter) convertSchema(avroSchema map[string]interface{}) (bigquery.Schema, error) {
+	col, err := c.convertType(avroSchema)
+	if err != nil {
+		return nil, err
+	}
+	return col.schema, nil
+}
+
+// column is a converted Avro type.
+type column struct {
+	BigQueryType
+	repeated bool
+	// nullable is set for a union of null and another type.
+	nullable bool
+	schema   bigquery.Schema
+}
+
+// field returns the column as a field.
+func (c column) field(name, description, defaultValue string, required bool) *bigquery.FieldSchema {
+	return &bigquery.FieldSchema{
+		Name:                   name,
+		Type:                   c.Type,
+		Schema:                 c.schema,
+		Description:            description,
+		Required:               required,
+		Repeated:               c.repeated,
+		Precision:              c.Precision,
+		Scale:                  c.Scale,
+		MaxLength:              c.MaxLength,
+		DefaultValueExpression: defaultValue,
+	}
+}
+
+// convertFields converts the fields of avroSchema without validating the
+// result. It is called recursively for nested records.
+func (c *converter) convertFields(avroSchema map[string]interface{}) (bigquery.Schema, error) {
+	var fields bigquery.Schema
 
 	// Extract the "fields" from the Avro schema.
 	avroFields, ok := avroSchema["fields"].([]interface{})
@@ -54,9 +110,11 @@ func ConvertAvroToBigQuery(avroSchema map[string]interface{}) ([]*bigquery.Field
 		// Extract the optional "doc" field from the Avro field as its description.
 		description, _ := avroFieldMap["doc"].(string)
 
-		fmt.Println("default type:", reflect.TypeOf(avroFieldMap["default"]))
 		defaultValue, _ := avroFieldMap["default"].(string)
-		fmt.Println("defaultValue:", defaultValue)
+		if c.profile != nil {
+			// The tools profiles reproduce do not set default values.
+			defaultValue = ""
+		}
 
 		// Extract the required "name" field from the Avro field as the BigQuery field name.
 		fieldName, ok := avroFieldMap["name"].(string)
@@ -64,340 +122,238 @@ func ConvertAvroToBigQuery(avroSchema map[string]interface{}) ([]*bigquery.Field
 			return nil, fmt.Errorf("invalid Avro schema field name")
 		}
 
-		fmt.Println("fieldName: ", fieldName)
-
-		fmt.Println("fieldTypeDataType: ", reflect.TypeOf(avroFieldMap["type"]))
-
 		// Determine the type of the Avro field and convert it to the corresponding BigQuery type.
-		switch avroFieldMap["type"].(type) {
+		switch fieldType := avroFieldMap["type"].(type) {
 		case []interface{}:
-			// The Avro field has multiple types (e.g., union).
-			fieldType, ok := avroFieldMap["type"].([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("invalid Avro schema field type")
-			}
-			// Iterate through the types and convert each one to BigQuery field(s).
-			for _, avroField := range fieldType {
-				switch avroField.(type) {
-				case map[string]interface{}:
-					// The type is a nested record. Convert recursively.
-					b := avroField
-					repeated := false
-					precision := int64(0)
-					scale := int64(0)
-					if b.(map[string]interface{})["type"].(string) == "bytes" {
-						precision, scale = checkPrecisionScale(b.(map[string]interface{}))
-					}
-
-					if b.(map[string]interface{})["type"].(string) == "array" {
-						repeated = true
-					}
-
-					bqFieldType, bqFieldSchema, err := convertAvroTypeToBigQuery(b.(map[string]interface{}))
-					if err != nil {
-						return nil, err
-					}
-					// Create the BigQuery field with the converted schema.
-					field := &bigquery.FieldSchema{
-						Name:                   fieldName,
-						Type:                   bqFieldType,
-						Schema:                 bqFieldSchema,
-						Description:            description,
-						Required:               false,
-						Repeated:               repeated,
-						Precision:              precision,
-						Scale:                  scale,
-						DefaultValueExpression: defaultValue,
-					}
-					fields = append(fields, field)
-
-				case string:
-					// The type is a primitive type.
-					if avroField == "null" {
-						fmt.Println("type null for []interface")
-					} else {
-						c := avroField
-						bqFieldType, err := convertAvroStringTypeToBigQuery(c.(string))
-						if err != nil {
-							return nil, fmt.Errorf("invalid Avro schema field type")
-						}
-						fmt.Println("bqFieldType: ", bqFieldType)
-						// Create the BigQuery field with the primitive type.
-						field := &bigquery.FieldSchema{
-							Name:                   fieldName,
-							Type:                   bqFieldType,
-							Description:            description,
-							Required:               false,
-							DefaultValueExpression: defaultValue,
-						}
-						fields = append(fields, field)
-					}
+			// The Avro field has multiple types (e.g., union). Every
+			// branch but null becomes a nullable column, unless a
+			// profile maps such unions to a record or rejects them.
+			if c.profile != nil {
+				col, err := c.convertType(fieldType)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", fieldName, err)
 				}
+				fields = append(fields, col.field(fieldName, description, defaultValue, !col.nullable))
+				continue
 			}
-		case string:
-			// The Avro field has a single primitive type.
-			fieldType, ok := avroFieldMap["type"].(string)
-			if !ok {
-				return nil, fmt.Errorf("invalid Avro schema field type")
-			}
-			var bqFieldSchema bigquery.Schema
-			if fieldType == "record" {
-				// The Avro type is a record, recursively convert the record's fields.
-				fields, ok := avroFieldMap["fields"].([]interface{})
-				if !ok {
-					return nil, fmt.Errorf("invalid avro record fields")
+			for _, branch := range fieldType {
+				if branch == "null" {
+					continue
 				}
-
-				// Recursively convert record fields using the ConvertAvroToBigQuery function.
-				recordFields, err := ConvertAvroToBigQuery(map[string]interface{}{"fields": fields})
+				col, err := c.convertType(branch)
 				if err != nil {
-					bqFieldSchema = nil
+					return nil, fmt.Errorf("%s: %w", fieldName, err)
 				}
-
-				// The record in Avro is mapped to a BigQuery RECORD type, with the schema of the record fields.
-				bqFieldSchema = recordFields
-			}
-			// Convert the primitive type to BigQuery type.
-			bqFieldType, err := convertAvroStringTypeToBigQuery(fieldType)
-			if err != nil {
-				return nil, fmt.Errorf("invalid Avro schema field type")
-			}
-			// Create the BigQuery field with the primitive type.
-			field := &bigquery.FieldSchema{
-				Name:                   fieldName,
-				Type:                   bqFieldType,
-				Schema:                 bqFieldSchema,
-				Description:            description,
-				Required:               true,
-				DefaultValueExpression: defaultValue,
-			}
-			fields = append(fields, field)
-
-		case map[string]interface{}:
-			// The Avro field is a nested record.
-			fieldType, ok := avroFieldMap["type"].(map[string]interface{})
-			if !ok {
-				return nil, fmt.Errorf("invalid Avro schema field type")
-			}
-
-			fmt.Println("avro test type: ", fieldType["type"].(string))
-
-			repeated := false
-			precision := int64(0)
-			scale := int64(0)
-			if fieldType["type"].(string) == "bytes" {
-				precision, scale = checkPrecisionScale(fieldType)
+				fields = append(fields, col.field(fieldName, description, defaultValue, false))
 			}
-			if fieldType["type"].(string) == "array" {
-				repeated = true
+		case string, map[string]interface{}:
+			var t interface{} = fieldType
+			if fieldType == "record" {
+				// A record declared directly on the field.
+				t = avroFieldMap
 			}
-			// Convert the nested type to BigQuery field(s).
-			bqFieldType, bqFieldSchema, err := convertAvroTypeToBigQuery(fieldType)
+			col, err := c.convertType(t)
 			if err != nil {
-				return nil, err
-			}
-			// Create the BigQuery field with the nested schema.
-			field := &bigquery.FieldSchema{
-				Name:                   fieldName,
-				Type:                   bqFieldType,
-				Schema:                 bqFieldSchema,
-				Description:            description,
-				Required:               true,
-				Repeated:               repeated,
-				Precision:              precision,
-				Scale:                  scale,
-				DefaultValueExpression: defaultValue,
+				return nil, fmt.Errorf("%s: %w", fieldName, err)
 			}
-			fields = append(fields, field)
-
+			fields = append(fields, col.field(fieldName, description, defaultValue, true))
 		default:
 			// The Avro field type is not recognized as a valid type.
 			// In this case, we treat it as a string type.
-			field := &bigquery.FieldSchema{
+			fields = append(fields, &bigquery.FieldSchema{
 				Name:                   fieldName,
 				Type:                   bigquery.StringFieldType,
 				Description:            description,
 				DefaultValueExpression: defaultValue,
-			}
-			fields = append(fields, field)
+			})
 		}
 	}
 	return fields, nil
 }
 
-// convertAvroStringTypeToBigQuery converts a given Avro data type (bqFieldType) to the corresponding BigQuery
-// data type (bigquery.FieldType). It maps Avro data types to their equivalent BigQuery data types based on the
-// switch cases. If a valid mapping is found, it returns the corresponding BigQuery data type and nil error.
-// If the provided Avro data type is not recognized or mapped to a BigQuery data type, it defaults to the
-// bigquery.StringFieldType and returns nil error.
-
-func convertAvroStringTypeToBigQuery(bqFieldType string) (bigquery.FieldType, error) {
-	switch bqFieldType {
-	case "null":
-		return bigquery.StringFieldType, nil
-	case "boolean":
-		return bigquery.BooleanFieldType, nil
-	case "int":
-		return bigquery.IntegerFieldType, nil
-	case "long":
-		return bigquery.IntegerFieldType, nil
-	case "float":
-		return bigquery.FloatFieldType, nil
-	case "double":
-		return bigquery.FloatFieldType, nil
-	case "bytes":
-		return bigquery.BytesFieldType, nil
-	case "string":
-		return bigquery.StringFieldType, nil
-	case "enum":
-		return bigquery.StringFieldType, nil
-	case "fixed":
-		// The Avro type is fixed, map to BigQuery BYTES type.
-		return bigquery.BytesFieldType, nil
-	case "record":
-		// The Avro type is fixed, map to BigQuery BYTES type.
-		return bigquery.RecordFieldType, nil
-	default:
-		return bigquery.StringFieldType, nil
+// convertType converts an Avro type given by name, as a type object or as
+// a union with a single non-null branch.
+func (c *converter) convertType(t interface{}) (column, error) {
+	switch t := t.(type) {
+	case string:
+		if def, full := c.lookup(t); def != nil {
+			if c.open[full] {
+				return column{}, fmt.Errorf("recursive reference to %s is not supported", full)
+			}
+			// Convert the reference in the namespace of its definition.
+			prev := c.ns
+			c.ns = namespaceOf(full)
+			defer func() { c.ns = prev }()
+			return c.convertType(def)
+		}
+		return c.convertNode(AvroType{Type: t})
+	case map[string]interface{}:
+		typeName, ok := t["type"].(string)
+		if !ok {
+			if nested, isType := t["type"].(map[string]interface{}); isType {
+				return c.convertType(nested)
+			}
+			return column{}, fmt.Errorf("invalid avro type")
+		}
+		logicalType, _ := t["logicalType"].(string)
+		switch typeName {
+		case "record", "error", "enum", "fixed":
+			full := definedName(t, c.ns)
+			c.named[full] = t
+			if typeName == "record" || typeName == "error" {
+				prev := c.ns
+				c.ns = namespaceOf(full)
+				c.open[full] = true
+				defer func() { c.ns = prev; delete(c.open, full) }()
+			}
+		case "array", "map":
+		default:
+			if !primitiveTypes[typeName] {
+				// A reference wrapped in an object, e.g. {"type": "Address"}.
+				return c.convertType(typeName)
+			}
+		}
+		return c.convertNode(AvroType{Type: typeName, LogicalType: logicalType, Schema: t})
+	case []interface{}:
+		var branches []interface{}
+		for _, b := range t {
+			if b != "null" {
+				branches = append(branches, b)
+			}
+		}
+		var col column
+		var err error
+		switch {
+		case len(branches) == 0:
+			col, err = c.convertNode(AvroType{Type: "null"})
+		case len(branches) == 1:
+			col, err = c.convertType(branches[0])
+		case c.profile != nil && c.profile.UnionRecords:
+			col, err = c.convertUnion(branches)
+		case c.profile != nil:
+			name := c.profile.Name
+			if name == "" {
+				name = "mapping"
+			}
+			return column{}, fmt.Errorf("the %s profile rejects unions with several non-null types", name)
+		default:
+			return column{}, fmt.Errorf("unions with several non-null types are only supported on fields")
+		}
+		col.nullable = len(branches) < len(t)
+		return col, err
 	}
-
+	return column{}, fmt.Errorf("invalid avro type")
 }
 
-// convertAvroTypeToBigQuery converts an Avro type represented as a map (avroType) to the corresponding BigQuery
-// data type (bigquery.FieldType) and schema (bigquery.Schema). It also handles nested types, such as arrays and records.
-// The function uses a switch statement to map Avro types to their equivalent BigQuery types.
-// If the Avro type is a simple primitive type, it returns the corresponding BigQuery type with a nil schema and error.
-// If the Avro type is an array, it recursively converts the array's element type and returns a BigQuery RECORD type
-// with the schema of the element type.
-// If the Avro type is a record, it recursively converts the record's fields and returns a BigQuery RECORD type
-// with the schema of the record fields.
-// If the provided Avro type is not recognized or unsupported, it returns an error with a BigQuery RECORD type.
+// lookup returns the definition and full name of the named type a
+// reference names, or nil when no such type was defined.
+func (c *converter) lookup(name string) (map[string]interface{}, string) {
+	if primitiveTypes[name] {
+		return nil, ""
+	}
+	for _, full := range []string{fullName(name, c.ns), name} {
+		if def, ok := c.named[full]; ok {
+			return def, full
+		}
+	}
+	return nil, ""
+}
 
-func convertAvroTypeToBigQuery(avroType map[string]interface{}) (bigquery.FieldType, bigquery.Schema, error) {
-	typeName, ok := avroType["type"].(string)
-	fmt.Println("typeName:", typeName)
-	if !ok {
-		return bigquery.RecordFieldType, nil, fmt.Errorf("invalid avro type")
+// convertNode maps t and, when it is mapped to RECORD, builds the nested
+// schema of records, arrays and maps.
+func (c *converter) convertNode(t AvroType) (column, error) {
+	mapped, err := c.mapper.MapType(t)
+	if err != nil {
+		return column{}, err
+	}
+	col := column{BigQueryType: mapped}
+	if mapped.Type != bigquery.RecordFieldType {
+		return col, nil
+	}
+	if t.Schema == nil {
+		return column{}, fmt.Errorf("avro type %s cannot be mapped to RECORD", t.Type)
 	}
 
-	switch typeName {
-	case "null":
-		// The Avro type is null, map to BigQuery STRING type (use STRING as a placeholder).
-		return bigquery.StringFieldType, nil, nil
-	case "fixed":
-		// The Avro type is fixed, map to BigQuery BYTES type.
-		return bigquery.BytesFieldType, nil, nil
-	case "bytes":
-		if avroType["logicalType"].(string) == "decimal" {
-			precisionValue, scale := checkPrecisionScale(avroType)
-			if 0 <= (precisionValue-scale) && (precisionValue-scale) <= 29 && scale <= 9 && precisionValue <= 38 {
-				// The Avro type is bytes, logicalType is decimal and precision > 38 map to BigQuery BigNumeric type.
-				return bigquery.NumericFieldType, nil, nil
-			} else if 0 <= (precisionValue-scale) && (precisionValue-scale) <= 38 && scale <= 38 && precisionValue <= 76 {
-				return bigquery.BigNumericFieldType, nil, nil
-			} else {
-				// The Avro type is bytes, logicalType is decimal map to BigQuery Numeric type.
-				return bigquery.NumericFieldType, nil, fmt.Errorf("precision and scale are out of bounds")
-			}
+	switch t.Type {
+	case "record", "error":
+		// The record in Avro is mapped to a BigQuery RECORD type, with the schema of the record fields.
+		if _, ok := t.Schema["fields"].([]interface{}); !ok {
+			return column{}, fmt.Errorf("invalid avro record fields")
 		}
-		// The Avro type is bytes, map to BigQuery BYTES type.
-		return bigquery.BytesFieldType, nil, nil
-	case "int":
-		switch avroType["logicalType"].(string) {
-		case "date":
-			// The Avro type is int, logicalType is date map to BigQuery Date type.
-			return bigquery.DateFieldType, nil, nil
-		case "time-millis":
-			// The Avro type is int, logicalType is time-millis map to BigQuery Time type.
-			return bigquery.TimeFieldType, nil, nil
+		col.schema, err = c.convertFields(t.Schema)
+		return col, err
+	case "array":
+		// The array in Avro is mapped to a REPEATED column. An array of
+		// records repeats the record; any other element becomes a single
+		// nested column named after the array.
+		element, err := c.convertType(t.Schema["items"])
+		if err != nil {
+			return column{}, err
 		}
-		// The Avro type is int, map to BigQuery INTEGER type.
-		return bigquery.IntegerFieldType, nil, nil
-	case "long":
-		switch avroType["logicalType"].(string) {
-		case "time-micros":
-			// The Avro type is int, logicalType is date map to BigQuery Date type.
-			return bigquery.TimeFieldType, nil, nil
-		case "timestamp-millis":
-			// The Avro type is int, logicalType is time-millis map to BigQuery Time type.
-			return bigquery.TimestampFieldType, nil, nil
-		case "timestamp-micros":
-			// The Avro type is int, logicalType is date map to BigQuery Date type.
-			return bigquery.TimestampFieldType, nil, nil
-		case "local-timestamp-millis":
-			// The Avro type is int, logicalType is time-millis map to BigQuery Time type.
-			return bigquery.DateTimeFieldType, nil, nil
-		case "local-timestamp-micros":
-			// The Avro type is int, logicalType is time-millis map to BigQuery Time type.
-			return bigquery.DateTimeFieldType, nil, nil
+		if c.profile != nil && c.profile.NativeArrays {
+			if element.repeated {
+				return column{}, fmt.Errorf("arrays of arrays are not supported")
+			}
+			element.repeated, element.nullable = true, false
+			return element, nil
 		}
-		// The Avro type is long, map to BigQuery INTEGER type.
-		return bigquery.IntegerFieldType, nil, nil
-	case "boolean":
-		// The Avro type is boolean, map to BigQuery BOOLEAN type.
-		return bigquery.BooleanFieldType, nil, nil
-	case "float":
-		// The Avro type is float, map to BigQuery FLOAT type.
-		return bigquery.FloatFieldType, nil, nil
-	case "double":
-		// The Avro type is double, map to BigQuery FLOAT type.
-		return bigquery.FloatFieldType, nil, nil
-	case "string":
-		// The Avro type is string, map to BigQuery STRING type.
-		if strings.ToLower(avroType["sqlType"].(string)) == "json" {
-			return bigquery.JSONFieldType, nil, nil
+		col.repeated = true
+		if element.Type == bigquery.RecordFieldType && !element.repeated {
+			col.schema = element.schema
+			return col, nil
 		}
-		return bigquery.StringFieldType, nil, nil
-	case "enum":
-		// The Avro type is an enum, map to BigQuery STRING type (use STRING as a placeholder for enum).
-		return bigquery.StringFieldType, nil, nil
-	case "array":
-		// The Avro type is an array, recursively convert the array's element type.
-		switch avroType["items"].(type) {
-		case string:
-			items, ok := avroType["items"].(string)
-			if !ok {
-				return bigquery.StringFieldType, nil, fmt.Errorf("invalid avro array items")
-			}
-			itemsbqtype, err := convertAvroStringTypeToBigQuery(items)
-			if err != nil {
-				return bigquery.RecordFieldType, nil, err
-			}
-			elementSchema := bigquery.Schema{{Name: avroType["name"].(string), Type: itemsbqtype}}
-			// The array in Avro is mapped to a BigQuery RECORD type, with the schema of the element type.
-			return bigquery.RecordFieldType, elementSchema, nil
-		case map[string]interface{}:
-			items, ok := avroType["items"].(map[string]interface{})
-			if !ok {
-				return bigquery.StringFieldType, nil, fmt.Errorf("invalid avro array items")
-			}
-			elementType, elementSchema, err := convertAvroTypeToBigQuery(items)
-			if err != nil {
-				return bigquery.RecordFieldType, nil, err
-			}
-			fmt.Println("elementType: ", elementType)
-
-			// The array in Avro is mapped to a BigQuery RECORD type, with the schema of the element type.
-			return bigquery.RecordFieldType, elementSchema, nil
+		name, _ := t.Schema["name"].(string)
+		if name == "" {
+			name = "element"
 		}
-	case "record":
-		// The Avro type is a record, recursively convert the record's fields.
-		fields, ok := avroType["fields"].([]interface{})
-		if !ok {
-			return bigquery.RecordFieldType, nil, fmt.Errorf("invalid avro record fields")
+		col.schema = bigquery.Schema{element.field(name, "", "", false)}
+		return col, nil
+	case "map":
+		// The map in Avro is mapped to a REPEATED RECORD of its entries.
+		value, err := c.convertType(t.Schema["values"])
+		if err != nil {
+			return column{}, err
 		}
+		col.repeated = true
+		col.schema = bigquery.Schema{
+			{Name: "key", Type: bigquery.StringFieldType, Required: true},
+			value.field("value", "", "", !value.nullable && !value.repeated),
+		}
+		return col, nil
+	}
+	return column{}, fmt.Errorf("avro type %s cannot be mapped to RECORD", t.Type)
+}
 
-		// Recursively convert record fields using the ConvertAvroToBigQuery function.
-		recordFields, err := ConvertAvroToBigQuery(map[string]interface{}{"fields": fields})
+// convertUnion converts a union with several non-null branches to a RECORD
+// with one nullable field per branch, named after the branch type.
+func (c *converter) convertUnion(branches []interface{}) (column, error) {
+	col := column{BigQueryType: BigQueryType{Type: bigquery.RecordFieldType}}
+	for _, branch := range branches {
+		name := unionBranchName(branch)
+		branchCol, err := c.convertType(branch)
 		if err != nil {
-			return bigquery.RecordFieldType, nil, err
+			return column{}, fmt.Errorf("%s: %w", name, err)
 		}
+		col.schema = append(col.schema, branchCol.field(name, "", "", false))
+	}
+	return col, nil
+}
 
-		// The record in Avro is mapped to a BigQuery RECORD type, with the schema of the record fields.
-		return bigquery.RecordFieldType, recordFields, nil
-	default:
-		// The Avro type is not recognized or unsupported, return an error with a BigQuery RECORD type.
-		return bigquery.RecordFieldType, nil, fmt.Errorf("unsupported avro type: %s", typeName)
+// unionBranchName returns the simple name of a named type, and the type
+// name of any other type.
+func unionBranchName(branch interface{}) string {
+	name, _ := branch.(string)
+	if m, ok := branch.(map[string]interface{}); ok {
+		name, _ = m["type"].(string)
+		switch name {
+		case "record", "error", "enum", "fixed":
+			if typeName, ok := m["name"].(string); ok {
+				name = typeName
+			}
+		}
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
 	}
-	return bigquery.StringFieldType, nil, fmt.Errorf("unsupported avro type: %s", typeName)
+	return name
 }
@@ -0,0 +1,299 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"cloud.google.com/go/bigquery"
+	"gopkg.in/yaml.v3"
+)
+
+// ClassificationRule flags fields holding a category of personal data. A
+// field matches when any of the name patterns, keywords or properties
+// matches.
+type ClassificationRule struct {
+	// Category names the kind of data, e.g. "email". It must be a valid
+	// label key: lowercase letters, digits, underscores and dashes.
+	Category string `json:"category" yaml:"category"`
+	// NamePatterns are regular expressions matched against the Avro field
+	// name and the column name, both in lowercase snake_case, so
+	// "contactEMail" is matched as "contact_e_mail".
+	NamePatterns []string `json:"namePatterns,omitempty" yaml:"namePatterns,omitempty"`
+	// Keywords are words or phrases searched for, as whole words and
+	// ignoring case and punctuation, in the doc of the field and in its
+	// names.
+	Keywords []string `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+	// Properties maps custom Avro properties of the field or its type to
+	// regular expressions their values must match, e.g. {"pii": "^true$"}.
+	Properties map[string]string `json:"properties,omitempty" yaml:"properties,omitempty"`
+	// PolicyTag is attached to matching columns when
+	// ClassifyOptions.PolicyTags is set. Like override policy tags, it is
+	// a resource name or an ID within ConvertOptions.PolicyTagTaxonomy.
+	PolicyTag string `json:"policyTag,omitempty" yaml:"policyTag,omitempty"`
+}
+
+// DefaultClassificationRules flag email addresses, phone numbers and
+// national identifiers such as social security, tax and passport numbers,
+// as well as any field with a "pii" property naming the category.
+var DefaultClassificationRules = []ClassificationRule{
+	{
+		Category:     "email",
+		NamePatterns: []string{`(^|_)e_?mail(_|$)`},
+		Keywords:     []string{"email", "e-mail"},
+		Properties:   map[string]string{"pii": `(?i)^e-?mail$`},
+	},
+	{
+		Category:     "phone",
+		NamePatterns: []string{`(^|_)(phone|telephone|mobile|msisdn|fax)(_|$)`},
+		Keywords:     []string{"phone number", "telephone", "mobile number"},
+		Properties:   map[string]string{"pii": `(?i)^phone$`},
+	},
+	{
+		Category: "national_id",
+		NamePatterns: []string{
+			`(^|_)(ssn|sin|nino|tin|nif|cpf|aadhaar)(_|$)`,
+			`(^|_)(national|social_security|tax|passport|id_card)_(id|number|no|num|insurance)(_|$)`,
+			`(^|_)passport(_|$)`,
+		},
+		Keywords:   []string{"social security number", "national insurance number", "national id", "passport number", "tax id", "taxpayer identification number"},
+		Properties: map[string]string{"pii": `(?i)^national[_ -]?id$`},
+	},
+}
+
+// ClassifyOptions configures the classification of fields during
+// conversion.
+type ClassifyOptions struct {
+	// Rules are the classification rules. Defaults to
+	// DefaultClassificationRules.
+	Rules []ClassificationRule
+	// PolicyTags attaches the policy tag of the first matching rule that
+	// has one to every flagged column without a policy tag.
+	PolicyTags bool
+}
+
+// Classification records a field flagged by a classification rule.
+type Classification struct {
+	AvroPath   string `json:"avroPath"`
+	ColumnPath string `json:"columnPath"`
+	Category   string `json:"category"`
+	// Reason tells what matched: "name", "keyword" or "property" followed
+	// by the matching pattern, keyword or property.
+	Reason string `json:"reason"`
+	// PolicyTag is the resolved policy tag of the column, if any.
+	PolicyTag string `json:"policyTag,omitempty"`
+}
+
+var categoryPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,58}$`)
+
+// LoadClassificationRules reads a list of rules from the file at path.
+// Files ending in ".json" are parsed as JSON, anything else as YAML.
+func LoadClassificationRules(path string) ([]ClassificationRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	rules, err := ParseClassificationRules(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseClassificationRules parses a list of rules in the given format
+// ("json" or "yaml") and validates them.
+func ParseClassificationRules(data []byte, format string) ([]ClassificationRule, error) {
+	var rules []ClassificationRule
+	switch strings.ToLower(format) {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rules); err != nil {
+			return nil, fmt.Errorf("invalid classification rules: %w", err)
+		}
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&rules); err != nil {
+			return nil, fmt.Errorf("invalid classification rules: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported classification rules format %q", format)
+	}
+	if _, err := compileRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// compiledRule is a ClassificationRule with its expressions compiled and
+// its keywords normalized.
+type compiledRule struct {
+	ClassificationRule
+	names      []*regexp.Regexp
+	keywords   []string
+	properties map[string]*regexp.Regexp
+}
+
+func compileRules(rules []ClassificationRule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, len(rules))
+	for i, rule := range rules {
+		if !categoryPattern.MatchString(rule.Category) {
+			return nil, fmt.Errorf("rule %d: invalid category %q", i, rule.Category)
+		}
+		c := compiledRule{ClassificationRule: rule, properties: make(map[string]*regexp.Regexp, len(rule.Properties))}
+		for _, pattern := range rule.NamePatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.Category, err)
+			}
+			c.names = append(c.names, re)
+		}
+		for _, keyword := range rule.Keywords {
+			if normalized := normalizeText(keyword); normalized != "" {
+				c.keywords = append(c.keywords, normalized)
+			}
+		}
+		for property, pattern := range rule.Properties {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: property %s: %w", rule.Category, property, err)
+			}
+			c.properties[property] = re
+		}
+		compiled[i] = c
+	}
+	return compiled, nil
+}
+
+// match returns the reason the Avro field matches the rule, or "".
+func (c compiledRule) match(avroName, columnName string, field map[string]interface{}) string {
+	names := []string{snakeName(avroName), snakeName(columnName)}
+	for _, re := range c.names {
+		for _, name := range names {
+			if re.MatchString(name) {
+				return "name " + re.String()
+			}
+		}
+	}
+	doc, _ := field["doc"].(string)
+	text := " " + normalizeText(doc) + " " + normalizeText(names[0]) + " " + normalizeText(names[1]) + " "
+	for _, keyword := range c.keywords {
+		if strings.Contains(text, " "+keyword+" ") {
+			return "keyword " + keyword
+		}
+	}
+	props := fieldProperties(field)
+	keys := make([]string, 0, len(c.properties))
+	for property := range c.properties {
+		keys = append(keys, property)
+	}
+	sort.Strings(keys)
+	for _, property := range keys {
+		if v, ok := props[property]; ok && c.properties[property].MatchString(fmt.Sprint(v)) {
+			return "property " + property
+		}
+	}
+	return ""
+}
+
+// fieldProperties returns the properties of an Avro field merged over
+// those of its type, like fieldAnnotations.
+func fieldProperties(field map[string]interface{}) map[string]interface{} {
+	props := make(map[string]interface{})
+	for k, v := range typeAnnotated(field["type"]) {
+		props[k] = v
+	}
+	for k, v := range field {
+		props[k] = v
+	}
+	return props
+}
+
+// snakeName returns name in lowercase snake_case.
+func snakeName(name string) string {
+	_, words := splitWords(name)
+	return strings.ToLower(strings.Join(words, "_"))
+}
+
+// normalizeText lowercases s and reduces it to words separated by single
+// spaces.
+func normalizeText(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// classify runs the rules against every field, given by Avro path in
+// fields, and returns one classification per flagged column and category,
+// in field order. RECORD columns are not classified, except the REPEATED
+// RECORD holding the single element column of an array of primitives,
+// whose element column is classified instead. With
+// opts.PolicyTags, the policy tag of the first matching rule that has one
+// is attached to columns without a policy tag.
+func classify(s bigquery.Schema, mappings []FieldMapping, fields map[string]map[string]interface{}, recordArrays map[string]bool, opts ClassifyOptions) ([]Classification, error) {
+	rules := opts.Rules
+	if rules == nil {
+		rules = DefaultClassificationRules
+	}
+	compiled, err := compileRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	var found []Classification
+	for _, m := range mappings {
+		field := fields[m.AvroPath]
+		f := lookupField(s, m.ColumnPath)
+		columnPath := m.ColumnPath
+		if f != nil && isRecord(f) && f.Repeated && !recordArrays[m.ColumnPath] && len(f.Schema) == 1 && !isRecord(f.Schema[0]) {
+			// The element column of an array of primitives.
+			f = f.Schema[0]
+			columnPath = joinPath(columnPath, f.Name)
+		}
+		if field == nil || f == nil || isRecord(f) {
+			continue
+		}
+		avroName, _ := field["name"].(string)
+		seen := make(map[string]bool)
+		for _, rule := range compiled {
+			if seen[rule.Category] {
+				continue
+			}
+			reason := rule.match(avroName, f.Name, field)
+			if reason == "" {
+				continue
+			}
+			seen[rule.Category] = true
+			if opts.PolicyTags && rule.PolicyTag != "" && (f.PolicyTags == nil || len(f.PolicyTags.Names) == 0) {
+				f.PolicyTags = &bigquery.PolicyTagList{Names: []string{rule.PolicyTag}}
+			}
+			found = append(found, Classification{AvroPath: m.AvroPath, ColumnPath: columnPath, Category: rule.Category, Reason: reason})
+		}
+	}
+	return found, nil
+}
+
+// ClassificationLabels returns the table labels summarizing the
+// classifications of a table: "contains_pii" and one "pii_<category>"
+// label per category, all set to "true". It returns nil when nothing was
+// flagged.
+func ClassificationLabels(classifications []Classification) map[string]string {
+	if len(classifications) == 0 {
+		return nil
+	}
+	labels := map[string]string{"contains_pii": "true"}
+	for _, c := range classifications {
+		labels["pii_"+c.Category] = "true"
+	}
+	return labels
+}
@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Customer",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "id", "type": "long"},
+			map[string]interface{}{"name": "contactEmail", "type": "string"},
+			map[string]interface{}{"name": "reach", "type": "string", "doc": "Mobile number, E.164 formatted"},
+			map[string]interface{}{"name": "ref", "type": []interface{}{"null", map[string]interface{}{"type": "string", "pii": "national_id"}}},
+			map[string]interface{}{"name": "address", "type": map[string]interface{}{
+				"type": "record",
+				"name": "Address",
+				"fields": []interface{}{
+					map[string]interface{}{"name": "SSN", "type": "string"},
+					map[string]interface{}{"name": "city", "type": "string", "doc": "City of the email sender"},
+				},
+			}},
+			map[string]interface{}{"name": "mailbox", "type": "string"},
+		},
+	}
+
+	t.Run("default rules", func(t *testing.T) {
+		c, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{
+			Naming:   NamingSnakeCase,
+			Flatten:  &FlattenOptions{},
+			Classify: &ClassifyOptions{},
+		})
+		if err != nil {
+			t.Fatalf("Error converting schema: %v", err)
+		}
+		want := []Classification{
+			{AvroPath: "contactEmail", ColumnPath: "contact_email", Category: "email"},
+			{AvroPath: "reach", ColumnPath: "reach", Category: "phone"},
+			{AvroPath: "ref", ColumnPath: "ref", Category: "national_id"},
+			{AvroPath: "address.SSN", ColumnPath: "address__ssn", Category: "national_id"},
+			{AvroPath: "address.city", ColumnPath: "address__city", Category: "email"},
+		}
+		if len(c.Classifications) != len(want) {
+			t.Fatalf("Expected %d classifications, but got %+v", len(want), c.Classifications)
+		}
+		for i, w := range want {
+			got := c.Classifications[i]
+			if got.AvroPath != w.AvroPath || got.ColumnPath != w.ColumnPath || got.Category != w.Category || got.Reason == "" {
+				t.Fatalf("Expected %+v, but got %+v", w, got)
+			}
+		}
+		if c.Classifications[2].Reason != "property pii" {
+			t.Fatalf("Expected ref to be flagged by its pii property, but got %q", c.Classifications[2].Reason)
+		}
+
+		labels := ClassificationLabels(c.Classifications)
+		if len(labels) != 4 || labels["contains_pii"] != "true" || labels["pii_national_id"] != "true" {
+			t.Fatalf("Unexpected labels: %v", labels)
+		}
+	})
+
+	t.Run("policy tags", func(t *testing.T) {
+		rules := []ClassificationRule{
+			{Category: "email", NamePatterns: []string{`(^|_)email$`}, PolicyTag: "10"},
+			{Category: "ssn", Keywords: []string{"ssn"}, PolicyTag: "11"},
+		}
+		c, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{
+			Classify:          &ClassifyOptions{Rules: rules, PolicyTags: true},
+			PolicyTagTaxonomy: testTaxonomy,
+			Overrides:         Overrides{"address.SSN": {PolicyTag: "12"}},
+		})
+		if err != nil {
+			t.Fatalf("Error converting schema: %v", err)
+		}
+		if len(c.Classifications) != 2 {
+			t.Fatalf("Expected 2 classifications, but got %+v", c.Classifications)
+		}
+		if got := c.Classifications[0].PolicyTag; got != testTaxonomy+"/policyTags/10" {
+			t.Fatalf("Expected the rule's policy tag on contactEmail, but got %q", got)
+		}
+		if got := c.Classifications[1].PolicyTag; got != testTaxonomy+"/policyTags/12" {
+			t.Fatalf("Expected the overridden policy tag to be kept, but got %q", got)
+		}
+		if f := lookupField(c.Schema, "contactEmail"); policyTagString(f) != testTaxonomy+"/policyTags/10" {
+			t.Fatalf("Expected the policy tag in the schema, but got %+v", f.PolicyTags)
+		}
+	})
+
+	t.Run("arrays of primitives", func(t *testing.T) {
+		arrays := map[string]interface{}{
+			"type": "record",
+			"name": "Contacts",
+			"fields": []interface{}{
+				map[string]interface{}{"name": "email_addresses", "type": map[string]interface{}{"type": "array", "items": "string"}},
+				map[string]interface{}{"name": "phone_numbers", "type": []interface{}{"null", map[string]interface{}{"type": "array", "items": "string"}}},
+				map[string]interface{}{"name": "people", "type": map[string]interface{}{"type": "array", "items": map[string]interface{}{
+					"type": "record", "name": "Person", "fields": []interface{}{map[string]interface{}{"name": "email", "type": "string"}},
+				}}},
+			},
+		}
+		rules := []ClassificationRule{
+			{Category: "email", NamePatterns: []string{`(^|_)email`}, PolicyTag: "10"},
+			{Category: "phone", NamePatterns: []string{`(^|_)phone`}},
+		}
+		c, err := ConvertAvroToBigQueryWithOptions(arrays, ConvertOptions{
+			Classify:          &ClassifyOptions{Rules: rules, PolicyTags: true},
+			PolicyTagTaxonomy: testTaxonomy,
+		})
+		if err != nil {
+			t.Fatalf("Error converting schema: %v", err)
+		}
+		want := []Classification{
+			{AvroPath: "email_addresses", ColumnPath: "email_addresses.element", Category: "email"},
+			{AvroPath: "phone_numbers", ColumnPath: "phone_numbers.element", Category: "phone"},
+			{AvroPath: "people.email", ColumnPath: "people.email", Category: "email"},
+		}
+		if len(c.Classifications) != len(want) {
+			t.Fatalf("Expected %d classifications, but got %+v", len(want), c.Classifications)
+		}
+		for i, w := range want {
+			if got := c.Classifications[i]; got.AvroPath != w.AvroPath || got.ColumnPath != w.ColumnPath || got.Category != w.Category {
+				t.Fatalf("Expected %+v, but got %+v", w, got)
+			}
+		}
+		if f := lookupField(c.Schema, "email_addresses.element"); policyTagString(f) != testTaxonomy+"/policyTags/10" {
+			t.Fatalf("Expected the policy tag on the element column, but got %+v", f)
+		}
+	})
+}
+
+func TestParseClassificationRules(t *testing.T) {
+	rules, err := ParseClassificationRules([]byte(`
+- category: iban
+  namePatterns: ["iban"]
+  keywords: [bank account]
+  properties: {pii: "^iban$"}
+  policyTag: "42"
+`), "yaml")
+	if err != nil {
+		t.Fatalf("Error parsing rules: %v", err)
+	}
+	if len(rules) != 1 || rules[0].Category != "iban" || rules[0].Properties["pii"] != "^iban$" {
+		t.Fatalf("Unexpected rules: %+v", rules)
+	}
+
+	invalid := map[string]string{
+		"invalid category": `[{"category": "E-Mail"}]`,
+		"invalid pattern":  `[{"category": "email", "namePatterns": ["("]}]`,
+		"unknown property": `[{"category": "email", "regex": "mail"}]`,
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseClassificationRules([]byte(data), "json"); err == nil {
+				t.Fatalf("Expected an error")
+			}
+		})
+	}
+}
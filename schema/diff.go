@@ -0,0 +1,173 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// DiffKind classifies a difference between two BigQuery schemas.
+type DiffKind string
+
+const (
+	// DiffMissingColumn is a column of the expected schema that is absent
+	// from the actual schema.
+	DiffMissingColumn DiffKind = "missing_column"
+	// DiffExtraColumn is a column of the actual schema that is absent from
+	// the expected schema.
+	DiffExtraColumn DiffKind = "extra_column"
+	// DiffTypeMismatch is a column whose type (including parameters such as
+	// precision, scale or maximum length) differs.
+	DiffTypeMismatch DiffKind = "type_mismatch"
+	// DiffModeMismatch is a column whose mode (NULLABLE, REQUIRED or
+	// REPEATED) differs.
+	DiffModeMismatch DiffKind = "mode_mismatch"
+	// DiffDescriptionMismatch is a column whose description differs.
+	DiffDescriptionMismatch DiffKind = "description_mismatch"
+	// DiffPolicyTagMismatch is a column whose policy tags differ from the
+	// expected ones. It is only reported for columns that are expected to
+	// have policy tags, so tags applied outside of the Avro schema are not
+	// differences.
+	DiffPolicyTagMismatch DiffKind = "policy_tag_mismatch"
+)
+
+// SchemaDifference describes a single difference between an expected and
+// an actual BigQuery schema. Path is the dot separated column path.
+type SchemaDifference struct {
+	Path     string   `json:"path"`
+	Kind     DiffKind `json:"kind"`
+	Expected string   `json:"expected,omitempty"`
+	Actual   string   `json:"actual,omitempty"`
+}
+
+// String returns a one-line, human readable description of the difference.
+func (d SchemaDifference) String() string {
+	switch d.Kind {
+	case DiffMissingColumn:
+		return fmt.Sprintf("%s: missing column (expected %s)", d.Path, d.Expected)
+	case DiffExtraColumn:
+		return fmt.Sprintf("%s: extra column (%s)", d.Path, d.Actual)
+	default:
+		return fmt.Sprintf("%s: %s (expected %q, actual %q)", d.Path, strings.ReplaceAll(string(d.Kind), "_", " "), d.Expected, d.Actual)
+	}
+}
+
+// DiffSchemas compares the expected schema, typically converted from Avro,
+// with the actual schema of a table and returns every difference at every
+// nesting level. Column names are compared case-insensitively, as BigQuery
+// does. Differences are reported in the column order of expected, followed
+// by the extra columns in the order of actual.
+func DiffSchemas(expected, actual bigquery.Schema) []SchemaDifference {
+	return diffFields("", expected, actual)
+}
+
+func diffFields(prefix string, expected, actual bigquery.Schema) []SchemaDifference {
+	var diffs []SchemaDifference
+
+	actualByName := make(map[string]*bigquery.FieldSchema, len(actual))
+	for _, f := range actual {
+		actualByName[strings.ToLower(f.Name)] = f
+	}
+	matched := make(map[string]bool, len(expected))
+
+	for _, want := range expected {
+		path := joinPath(prefix, want.Name)
+		key := strings.ToLower(want.Name)
+		matched[key] = true
+		got, ok := actualByName[key]
+		if !ok {
+			diffs = append(diffs, SchemaDifference{Path: path, Kind: DiffMissingColumn, Expected: describeField(want)})
+			continue
+		}
+
+		wantType, gotType := FieldTypeString(want), FieldTypeString(got)
+		if wantType != gotType {
+			diffs = append(diffs, SchemaDifference{Path: path, Kind: DiffTypeMismatch, Expected: wantType, Actual: gotType})
+		}
+		if wantMode, gotMode := FieldMode(want), FieldMode(got); wantMode != gotMode {
+			diffs = append(diffs, SchemaDifference{Path: path, Kind: DiffModeMismatch, Expected: wantMode, Actual: gotMode})
+		}
+		if want.Description != got.Description {
+			diffs = append(diffs, SchemaDifference{Path: path, Kind: DiffDescriptionMismatch, Expected: want.Description, Actual: got.Description})
+		}
+		if wantTags := policyTagString(want); wantTags != "" {
+			if gotTags := policyTagString(got); wantTags != gotTags {
+				diffs = append(diffs, SchemaDifference{Path: path, Kind: DiffPolicyTagMismatch, Expected: wantTags, Actual: gotTags})
+			}
+		}
+		if isRecord(want) && isRecord(got) {
+			diffs = append(diffs, diffFields(path, want.Schema, got.Schema)...)
+		}
+	}
+
+	for _, got := range actual {
+		if !matched[strings.ToLower(got.Name)] {
+			diffs = append(diffs, SchemaDifference{Path: joinPath(prefix, got.Name), Kind: DiffExtraColumn, Actual: describeField(got)})
+		}
+	}
+	return diffs
+}
+
+// FieldMode returns the BigQuery mode of a field: REPEATED, REQUIRED or
+// NULLABLE.
+func FieldMode(f *bigquery.FieldSchema) string {
+	switch {
+	case f.Repeated:
+		return "REPEATED"
+	case f.Required:
+		return "REQUIRED"
+	default:
+		return "NULLABLE"
+	}
+}
+
+// FieldTypeString returns the type of a field in BigQuery DDL notation,
+// including its parameters, e.g. STRING(10) or NUMERIC(4, 2). Legacy and
+// standard SQL type names are normalized to the names used by the
+// BigQuery API.
+func FieldTypeString(f *bigquery.FieldSchema) string {
+	t := normalizeFieldType(f.Type)
+	switch {
+	case f.MaxLength > 0:
+		return fmt.Sprintf("%s(%d)", t, f.MaxLength)
+	case f.Precision > 0 && f.Scale > 0:
+		return fmt.Sprintf("%s(%d, %d)", t, f.Precision, f.Scale)
+	case f.Precision > 0:
+		return fmt.Sprintf("%s(%d)", t, f.Precision)
+	}
+	return string(t)
+}
+
+func normalizeFieldType(t bigquery.FieldType) bigquery.FieldType {
+	switch strings.ToUpper(string(t)) {
+	case "INT64":
+		return bigquery.IntegerFieldType
+	case "FLOAT64":
+		return bigquery.FloatFieldType
+	case "BOOL":
+		return bigquery.BooleanFieldType
+	case "STRUCT":
+		return bigquery.RecordFieldType
+	case "DECIMAL":
+		return bigquery.NumericFieldType
+	case "BIGDECIMAL":
+		return bigquery.BigNumericFieldType
+	}
+	return bigquery.FieldType(strings.ToUpper(string(t)))
+}
+
+func isRecord(f *bigquery.FieldSchema) bool {
+	return normalizeFieldType(f.Type) == bigquery.RecordFieldType
+}
+
+func describeField(f *bigquery.FieldSchema) string {
+	return FieldTypeString(f) + " " + FieldMode(f)
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
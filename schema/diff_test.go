@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	expected := bigquery.Schema{
+		{Name: "id", Type: bigquery.IntegerFieldType, Required: true},
+		{Name: "amount", Type: bigquery.NumericFieldType, Precision: 10, Scale: 2},
+		{Name: "email", Type: bigquery.StringFieldType, Description: "Primary email"},
+		{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "city", Type: bigquery.StringFieldType},
+			{Name: "zip", Type: bigquery.StringFieldType},
+		}},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+	}
+	actual := bigquery.Schema{
+		{Name: "ID", Type: "INT64", Required: true},
+		{Name: "amount", Type: bigquery.NumericFieldType},
+		{Name: "email", Type: bigquery.StringFieldType, Description: "Email"},
+		{Name: "address", Type: "STRUCT", Schema: bigquery.Schema{
+			{Name: "city", Type: bigquery.StringFieldType, Required: true},
+			{Name: "country", Type: bigquery.StringFieldType},
+		}},
+		{Name: "added_by_hand", Type: bigquery.BooleanFieldType},
+	}
+
+	want := []SchemaDifference{
+		{Path: "amount", Kind: DiffTypeMismatch, Expected: "NUMERIC(10, 2)", Actual: "NUMERIC"},
+		{Path: "email", Kind: DiffDescriptionMismatch, Expected: "Primary email", Actual: "Email"},
+		{Path: "address.city", Kind: DiffModeMismatch, Expected: "NULLABLE", Actual: "REQUIRED"},
+		{Path: "address.zip", Kind: DiffMissingColumn, Expected: "STRING NULLABLE"},
+		{Path: "address.country", Kind: DiffExtraColumn, Actual: "STRING NULLABLE"},
+		{Path: "tags", Kind: DiffMissingColumn, Expected: "STRING REPEATED"},
+		{Path: "added_by_hand", Kind: DiffExtraColumn, Actual: "BOOLEAN NULLABLE"},
+	}
+
+	got := DiffSchemas(expected, actual)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d differences, but got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Difference %d: Expected %+v, but got %+v", i, want[i], got[i])
+		}
+	}
+
+	if diffs := DiffSchemas(expected, expected); len(diffs) != 0 {
+		t.Fatalf("Expected no differences for identical schemas, but got %v", diffs)
+	}
+}
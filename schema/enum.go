@@ -0,0 +1,269 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// EnumMode selects how Avro enums are converted.
+type EnumMode string
+
+const (
+	// EnumAsString maps enums to plain STRING columns, dropping the
+	// symbols. This is the behavior of ConvertAvroToBigQuery.
+	EnumAsString EnumMode = "string"
+	// EnumWithSymbols maps enums to STRING columns and appends the symbol
+	// list to the column description.
+	EnumWithSymbols EnumMode = "symbols"
+	// EnumAsOrdinal maps enums to INTEGER columns holding the position of
+	// the symbol, and appends the ordinal of every symbol to the column
+	// description.
+	EnumAsOrdinal EnumMode = "ordinal"
+	// EnumWithCheck maps enums to STRING columns and generates a validation
+	// query counting values that are not symbols (see
+	// Conversion.EnumValidationSQL).
+	EnumWithCheck EnumMode = "check"
+)
+
+// EnumModeByName returns the enum mode with the given name: "string",
+// "symbols", "ordinal" or "check".
+func EnumModeByName(name string) (EnumMode, error) {
+	switch mode := EnumMode(name); mode {
+	case "":
+		return EnumAsString, nil
+	case EnumAsString, EnumWithSymbols, EnumAsOrdinal, EnumWithCheck:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown enum mode %q", name)
+}
+
+// EnumOptions configures the conversion of Avro enums.
+type EnumOptions struct {
+	// Mode selects the column type and the metadata kept. Defaults to
+	// EnumAsString.
+	Mode EnumMode
+	// Defaults turns the default symbol of an enum field into the column's
+	// default value expression, as a string literal or an ordinal, and
+	// mentions the enum's own default, used by Avro readers for unknown
+	// symbols, in the description.
+	Defaults bool
+	// SymbolDocs adds the documentation of each symbol, read from the
+	// enum's "symbolDocs" object, to the symbol list in the description.
+	SymbolDocs bool
+}
+
+// EnumCheck describes the validation of an enum column converted with
+// EnumWithCheck.
+type EnumCheck struct {
+	// Table is the normalized table holding the column; it is empty unless
+	// the schema was normalized.
+	Table string `json:"table,omitempty"`
+	// ColumnPath is the dot separated path of the column in the converted
+	// schema.
+	ColumnPath string   `json:"columnPath"`
+	Symbols    []string `json:"symbols"`
+	// Condition is a boolean SQL expression that is true for the rows
+	// holding a value that is not one of Symbols.
+	Condition string `json:"condition"`
+}
+
+// SQL returns a query counting the rows of table that hold an invalid
+// value in the column. table is quoted with backticks, so it may be a
+// qualified name such as project.dataset.table.
+func (c EnumCheck) SQL(table string) string {
+	return fmt.Sprintf("SELECT COUNT(*) AS invalid_rows FROM `%s` WHERE %s", table, c.Condition)
+}
+
+// EnumValidationSQL returns a single query reporting, for every enum
+// column converted with EnumWithCheck, the number of rows holding a value
+// that is not a symbol, or "" when there are no such columns. table is the
+// qualified name of the table; for a normalized schema it names the root
+// table and child tables are looked up next to it.
+func (c *Conversion) EnumValidationSQL(table string) string {
+	queries := make([]string, len(c.EnumChecks))
+	for i, check := range c.EnumChecks {
+		ref := table
+		if check.Table != "" {
+			ref = check.Table
+			if i := strings.LastIndex(table, "."); i >= 0 {
+				ref = table[:i+1] + check.Table
+			}
+		}
+		queries[i] = fmt.Sprintf("SELECT %s AS table_name, %s AS column_path, COUNTIF(%s) AS invalid_rows FROM `%s`",
+			sqlString(ref), sqlString(check.ColumnPath), check.Condition, ref)
+	}
+	return strings.Join(queries, "\nUNION ALL\n")
+}
+
+// enumField is an enum-typed field found while renaming.
+type enumField struct {
+	symbols []string
+	docs    map[string]string
+	// enumDefault is the enum's own default symbol.
+	enumDefault string
+	// fieldDefault is the default symbol of the field, if any.
+	fieldDefault    string
+	hasFieldDefault bool
+}
+
+func newEnumField(enum, field map[string]interface{}) *enumField {
+	e := &enumField{symbols: stringList(enum["symbols"]), docs: make(map[string]string)}
+	e.enumDefault, _ = enum["default"].(string)
+	if docs, ok := enum["symbolDocs"].(map[string]interface{}); ok {
+		for symbol, doc := range docs {
+			if doc, ok := doc.(string); ok {
+				e.docs[symbol] = doc
+			}
+		}
+	}
+	e.fieldDefault, e.hasFieldDefault = field["default"].(string)
+	return e
+}
+
+func (e *enumField) ordinal(symbol string) int {
+	for i, s := range e.symbols {
+		if s == symbol {
+			return i
+		}
+	}
+	return -1
+}
+
+// describe returns the sentences appended to the column description.
+func (e *enumField) describe(opts EnumOptions) string {
+	items := make([]string, len(e.symbols))
+	for i, symbol := range e.symbols {
+		items[i] = symbol
+		if opts.Mode == EnumAsOrdinal {
+			items[i] = strconv.Itoa(i) + "=" + symbol
+		}
+		if doc := e.docs[symbol]; opts.SymbolDocs && doc != "" {
+			items[i] += " (" + doc + ")"
+		}
+	}
+	var sentences []string
+	switch opts.Mode {
+	case EnumWithSymbols:
+		sentences = append(sentences, "Symbols: "+strings.Join(items, ", ")+".")
+	case EnumAsOrdinal:
+		sentences = append(sentences, "Ordinals: "+strings.Join(items, ", ")+".")
+	}
+	if opts.Defaults && e.enumDefault != "" {
+		sentences = append(sentences, "Unknown symbols resolve to "+e.enumDefault+".")
+	}
+	return strings.Join(sentences, " ")
+}
+
+// applyEnums rewrites the enum columns of s, identified by their column
+// path in enums, according to opts. An array of enums is recorded under the
+// path of its REPEATED column, and rewrites the column itself or, when the
+// array became a REPEATED RECORD, its single nested column. When opts.Mode
+// is EnumWithCheck, it returns the columns to check, without their
+// condition.
+func applyEnums(s bigquery.Schema, prefix string, enums map[string]*enumField, opts EnumOptions) ([]EnumCheck, error) {
+	var checks []EnumCheck
+	for _, f := range s {
+		path := joinPath(prefix, f.Name)
+		e, ok := enums[path]
+		if isRecord(f) {
+			if ok && f.Repeated && len(f.Schema) == 1 && !isRecord(f.Schema[0]) {
+				// The element column of an array of enums.
+				f, path = f.Schema[0], joinPath(path, f.Schema[0].Name)
+			} else {
+				nested, err := applyEnums(f.Schema, path, enums, opts)
+				if err != nil {
+					return nil, err
+				}
+				checks = append(checks, nested...)
+				continue
+			}
+		}
+		if !ok {
+			continue
+		}
+		check, err := applyEnum(f, path, e, opts)
+		if err != nil {
+			return nil, err
+		}
+		if check != nil {
+			checks = append(checks, *check)
+		}
+	}
+	return checks, nil
+}
+
+// applyEnum rewrites the enum column f at path according to opts, and
+// returns its check when opts.Mode is EnumWithCheck.
+func applyEnum(f *bigquery.FieldSchema, path string, e *enumField, opts EnumOptions) (*EnumCheck, error) {
+	if opts.Defaults && e.hasFieldDefault {
+		ordinal := e.ordinal(e.fieldDefault)
+		if ordinal < 0 {
+			return nil, fmt.Errorf("%s: default %q is not an enum symbol", path, e.fieldDefault)
+		}
+		f.DefaultValueExpression = sqlString(e.fieldDefault)
+		if opts.Mode == EnumAsOrdinal {
+			f.DefaultValueExpression = strconv.Itoa(ordinal)
+		}
+	}
+	if opts.Mode == EnumAsOrdinal {
+		f.Type = bigquery.IntegerFieldType
+	}
+	if description := e.describe(opts); description != "" {
+		if f.Description != "" {
+			f.Description += " "
+		}
+		f.Description += description
+	}
+	if opts.Mode == EnumWithCheck {
+		return &EnumCheck{ColumnPath: path, Symbols: e.symbols}, nil
+	}
+	return nil, nil
+}
+
+// enumCondition returns the condition of an EnumCheck for the column at
+// path in s, unnesting REPEATED columns on the way. It returns false if
+// the path does not lead to a column of s.
+func enumCondition(s bigquery.Schema, path []string, ref string, depth int, symbols []string) (string, bool) {
+	f := findField(s, path[0])
+	if f == nil {
+		return "", false
+	}
+	column := "`" + f.Name + "`"
+	if ref != "" {
+		column = ref + "." + column
+	}
+	if len(path) == 1 {
+		if isRecord(f) {
+			return "", false
+		}
+		quoted := make([]string, len(symbols))
+		for i, symbol := range symbols {
+			quoted[i] = sqlString(symbol)
+		}
+		list := strings.Join(quoted, ", ")
+		if f.Repeated {
+			return fmt.Sprintf("EXISTS(SELECT 1 FROM UNNEST(%s) AS v WHERE v NOT IN (%s))", column, list), true
+		}
+		return fmt.Sprintf("%s NOT IN (%s)", column, list), true
+	}
+	if !isRecord(f) {
+		return "", false
+	}
+	if !f.Repeated {
+		return enumCondition(f.Schema, path[1:], column, depth, symbols)
+	}
+	alias := "e" + strconv.Itoa(depth)
+	inner, ok := enumCondition(f.Schema, path[1:], alias, depth+1, symbols)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("EXISTS(SELECT 1 FROM UNNEST(%s) AS %s WHERE %s)", column, alias, inner), true
+}
+
+// sqlString quotes s as a GoogleSQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,200 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestConvertAvroToBigQueryWithEnums(t *testing.T) {
+	color := map[string]interface{}{
+		"type":       "enum",
+		"name":       "Color",
+		"namespace":  "com.example",
+		"symbols":    []interface{}{"RED", "GREEN", "BLUE"},
+		"default":    "RED",
+		"symbolDocs": map[string]interface{}{"GREEN": "Go"},
+	}
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Light",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "color", "type": color, "doc": "Current color.", "default": "GREEN"},
+			map[string]interface{}{"name": "previous", "type": []interface{}{"null", "com.example.Color"}, "default": nil},
+			map[string]interface{}{"name": "next", "type": "Color"},
+			map[string]interface{}{"name": "history", "type": map[string]interface{}{
+				"type": "array",
+				"name": "history",
+				"items": map[string]interface{}{"type": "record", "name": "Change", "fields": []interface{}{
+					map[string]interface{}{"name": "to", "type": "Color"},
+				}},
+			}},
+		},
+	}
+
+	convert := func(t *testing.T, opts ConvertOptions) *Conversion {
+		t.Helper()
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, opts)
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		return conversion
+	}
+
+	t.Run("string", func(t *testing.T) {
+		conversion := convert(t, ConvertOptions{Enums: &EnumOptions{Mode: EnumAsString}})
+		if f := conversion.Schema[0]; f.Type != bigquery.StringFieldType || f.Description != "Current color." {
+			t.Fatalf("Expected a plain STRING column, but got %+v", f)
+		}
+	})
+
+	t.Run("symbols", func(t *testing.T) {
+		conversion := convert(t, ConvertOptions{Enums: &EnumOptions{Mode: EnumWithSymbols, SymbolDocs: true, Defaults: true}})
+		want := "Current color. Symbols: RED, GREEN (Go), BLUE. Unknown symbols resolve to RED."
+		if f := conversion.Schema[0]; f.Description != want || f.DefaultValueExpression != "'GREEN'" {
+			t.Fatalf("Expected description %q and default 'GREEN', but got %q and %q", want, f.Description, f.DefaultValueExpression)
+		}
+		for _, f := range []*bigquery.FieldSchema{conversion.Schema[1], conversion.Schema[2], conversion.Schema[3].Schema[0]} {
+			if !strings.HasPrefix(f.Description, "Symbols: RED, GREEN (Go), BLUE.") {
+				t.Fatalf("Expected the symbols of the referenced enum in %s, but got %q", f.Name, f.Description)
+			}
+		}
+	})
+
+	t.Run("ordinal", func(t *testing.T) {
+		conversion := convert(t, ConvertOptions{Enums: &EnumOptions{Mode: EnumAsOrdinal, Defaults: true}})
+		f := conversion.Schema[0]
+		if f.Type != bigquery.IntegerFieldType || f.DefaultValueExpression != "1" {
+			t.Fatalf("Expected an INTEGER column defaulting to 1, but got %s defaulting to %q", f.Type, f.DefaultValueExpression)
+		}
+		if !strings.Contains(f.Description, "Ordinals: 0=RED, 1=GREEN, 2=BLUE.") {
+			t.Fatalf("Expected the ordinals in the description, but got %q", f.Description)
+		}
+		if conversion.Schema[1].Type != bigquery.IntegerFieldType || conversion.Schema[1].Required {
+			t.Fatalf("Expected a nullable INTEGER column for the optional enum, but got %+v", conversion.Schema[1])
+		}
+	})
+
+	t.Run("check", func(t *testing.T) {
+		conversion := convert(t, ConvertOptions{Enums: &EnumOptions{Mode: EnumWithCheck}})
+		want := []EnumCheck{
+			{ColumnPath: "color", Condition: "`color` NOT IN ('RED', 'GREEN', 'BLUE')"},
+			{ColumnPath: "previous", Condition: "`previous` NOT IN ('RED', 'GREEN', 'BLUE')"},
+			{ColumnPath: "next", Condition: "`next` NOT IN ('RED', 'GREEN', 'BLUE')"},
+			{ColumnPath: "history.to", Condition: "EXISTS(SELECT 1 FROM UNNEST(`history`) AS e0 WHERE e0.`to` NOT IN ('RED', 'GREEN', 'BLUE'))"},
+		}
+		if len(conversion.EnumChecks) != len(want) {
+			t.Fatalf("Expected %d checks, but got %+v", len(want), conversion.EnumChecks)
+		}
+		for i, w := range want {
+			if got := conversion.EnumChecks[i]; got.ColumnPath != w.ColumnPath || got.Condition != w.Condition || got.Table != "" {
+				t.Fatalf("Expected check %+v, but got %+v", w, got)
+			}
+		}
+		query := conversion.EnumValidationSQL("p.d.lights")
+		if strings.Count(query, "UNION ALL") != 3 || !strings.Contains(query, "COUNTIF(`color` NOT IN ('RED', 'GREEN', 'BLUE')) AS invalid_rows FROM `p.d.lights`") {
+			t.Fatalf("Unexpected validation query: %s", query)
+		}
+	})
+
+	t.Run("check normalized", func(t *testing.T) {
+		conversion := convert(t, ConvertOptions{
+			Enums:     &EnumOptions{Mode: EnumWithCheck},
+			Normalize: &NormalizeOptions{RootTable: "lights"},
+		})
+		last := conversion.EnumChecks[len(conversion.EnumChecks)-1]
+		if last.Table != "lights_history" || last.ColumnPath != "to" || last.Condition != "`to` NOT IN ('RED', 'GREEN', 'BLUE')" {
+			t.Fatalf("Expected a check on lights_history.to, but got %+v", last)
+		}
+		if !strings.Contains(conversion.EnumValidationSQL("p.d.lights"), "FROM `p.d.lights_history`") {
+			t.Fatalf("Expected the child table in the validation query")
+		}
+	})
+
+	t.Run("check flattened", func(t *testing.T) {
+		conversion := convert(t, ConvertOptions{
+			Enums:   &EnumOptions{Mode: EnumWithCheck},
+			Flatten: &FlattenOptions{Arrays: ArraysAsJSON},
+		})
+		if len(conversion.EnumChecks) != 3 {
+			t.Fatalf("Expected the enum inside the JSON array to be skipped, but got %+v", conversion.EnumChecks)
+		}
+	})
+
+	t.Run("invalid default", func(t *testing.T) {
+		invalid := map[string]interface{}{"type": "record", "name": "R", "fields": []interface{}{
+			map[string]interface{}{"name": "color", "type": color, "default": "PINK"},
+		}}
+		if _, err := ConvertAvroToBigQueryWithOptions(invalid, ConvertOptions{Enums: &EnumOptions{Defaults: true}}); err == nil {
+			t.Fatalf("Expected an error for a default that is not a symbol")
+		}
+	})
+}
+
+func TestConvertAvroToBigQueryWithEnumArrays(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Palette",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "colors", "type": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "enum", "name": "Color", "symbols": []interface{}{"RED", "GREEN"}},
+			}},
+			map[string]interface{}{"name": "accents", "type": []interface{}{"null", map[string]interface{}{"type": "array", "items": "Color"}}},
+		},
+	}
+
+	t.Run("ordinal", func(t *testing.T) {
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Enums: &EnumOptions{Mode: EnumAsOrdinal}})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		for _, f := range conversion.Schema {
+			if !f.Repeated || len(f.Schema) != 1 {
+				t.Fatalf("Expected %s to be a REPEATED RECORD of one column, but got %+v", f.Name, f)
+			}
+			if element := f.Schema[0]; element.Type != bigquery.IntegerFieldType || element.Description != "Ordinals: 0=RED, 1=GREEN." {
+				t.Fatalf("Expected the elements of %s to be ordinals, but got %+v", f.Name, element)
+			}
+		}
+	})
+
+	t.Run("check", func(t *testing.T) {
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Enums: &EnumOptions{Mode: EnumWithCheck}})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		if len(conversion.EnumChecks) != 2 {
+			t.Fatalf("Expected 2 enum checks, but got %+v", conversion.EnumChecks)
+		}
+		want := "EXISTS(SELECT 1 FROM UNNEST(`colors`) AS e0 WHERE e0.`element` NOT IN ('RED', 'GREEN'))"
+		if c := conversion.EnumChecks[0]; c.ColumnPath != "colors.element" || c.Condition != want {
+			t.Fatalf("Expected condition %q, but got %+v", want, c)
+		}
+	})
+
+	t.Run("native arrays", func(t *testing.T) {
+		profile, err := ProfileByName(ProfileBQLoadLogicalTypes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Profile: profile, Enums: &EnumOptions{Mode: EnumWithCheck}})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		want := "EXISTS(SELECT 1 FROM UNNEST(`colors`) AS v WHERE v NOT IN ('RED', 'GREEN'))"
+		if len(conversion.EnumChecks) != 2 || conversion.EnumChecks[0].ColumnPath != "colors" || conversion.EnumChecks[0].Condition != want {
+			t.Fatalf("Expected a check of the colors column with %q, but got %+v", want, conversion.EnumChecks)
+		}
+	})
+}
+
+func TestEnumModeByName(t *testing.T) {
+	if mode, err := EnumModeByName("ordinal"); err != nil || mode != EnumAsOrdinal {
+		t.Fatalf("Expected EnumAsOrdinal, but got %q, %v", mode, err)
+	}
+	if _, err := EnumModeByName("bitmask"); err == nil {
+		t.Fatalf("Expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,302 @@
+package schema
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// crc64Empty is the CRC-64-AVRO fingerprint of the empty byte sequence. It
+// also serves as the polynomial of the fingerprint table.
+const crc64Empty uint64 = 0xc15d213aa4d7a795
+
+var crc64Table = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (crc64Empty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return table
+}()
+
+// primitiveTypes are the Avro primitive type names. They are never
+// qualified with a namespace.
+var primitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// canonicalAttributes lists the attributes kept by the Parsing Canonical
+// Form, in the order they are written.
+var canonicalAttributes = []string{"name", "type", "fields", "symbols", "items", "values", "size"}
+
+// CanonicalForm returns the Avro Parsing Canonical Form of avroSchema as
+// defined by the Avro specification: names are replaced by their full
+// names, attributes irrelevant to parsing (doc, aliases, defaults, logical
+// types, ...) are stripped, the remaining attributes are written in a fixed
+// order and all whitespace is removed. avroSchema is a schema unmarshaled
+// from JSON, i.e. a string, a []interface{} union or a
+// map[string]interface{}.
+//
+// Fields declared as {"name": ..., "type": "record", "fields": [...]}, which
+// ConvertAvroToBigQuery accepts, are treated as an inline record named
+// after the field.
+func CanonicalForm(avroSchema interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, avroSchema, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, s interface{}, namespace string) error {
+	switch s := s.(type) {
+	case string:
+		if primitiveTypes[s] {
+			writeCanonicalString(buf, s)
+		} else {
+			writeCanonicalString(buf, fullName(s, namespace))
+		}
+		return nil
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, branch := range s {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, branch, namespace); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case map[string]interface{}:
+		return writeCanonicalObject(buf, s, namespace)
+	default:
+		return fmt.Errorf("invalid avro schema: unexpected %T", s)
+	}
+}
+
+func writeCanonicalObject(buf *bytes.Buffer, s map[string]interface{}, namespace string) error {
+	typeName, ok := s["type"].(string)
+	if !ok {
+		// A type wrapped in an object, e.g. {"type": {"type": "array", ...}}.
+		return writeCanonical(buf, s["type"], namespace)
+	}
+
+	named := typeName == "record" || typeName == "error" || typeName == "enum" || typeName == "fixed"
+	if !named && typeName != "array" && typeName != "map" {
+		// Primitive types with attributes, e.g. {"type": "long", "logicalType": ...},
+		// reduce to the bare type name.
+		return writeCanonical(buf, typeName, namespace)
+	}
+
+	name := ""
+	if named {
+		rawName, ok := s["name"].(string)
+		if !ok || rawName == "" {
+			return fmt.Errorf("invalid avro schema: %s without a name", typeName)
+		}
+		ns := namespace
+		if explicit, ok := s["namespace"].(string); ok {
+			ns = explicit
+		}
+		name = fullName(rawName, ns)
+		namespace = namespaceOf(name)
+	}
+	if typeName == "error" {
+		typeName = "record"
+	}
+
+	buf.WriteByte('{')
+	first := true
+	writeKey := func(key string) {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeCanonicalString(buf, key)
+		buf.WriteByte(':')
+	}
+
+	for _, attr := range canonicalAttributes {
+		switch attr {
+		case "name":
+			if name == "" {
+				continue
+			}
+			writeKey(attr)
+			writeCanonicalString(buf, name)
+		case "type":
+			writeKey(attr)
+			writeCanonicalString(buf, typeName)
+		case "fields":
+			if typeName != "record" {
+				continue
+			}
+			fields, ok := s["fields"].([]interface{})
+			if !ok {
+				return fmt.Errorf("invalid avro record fields")
+			}
+			writeKey(attr)
+			if err := writeCanonicalFields(buf, fields, namespace); err != nil {
+				return err
+			}
+		case "symbols":
+			if typeName != "enum" {
+				continue
+			}
+			symbols, ok := s["symbols"].([]interface{})
+			if !ok {
+				return fmt.Errorf("invalid avro enum symbols")
+			}
+			writeKey(attr)
+			buf.WriteByte('[')
+			for i, symbol := range symbols {
+				symbolName, ok := symbol.(string)
+				if !ok {
+					return fmt.Errorf("invalid avro enum symbol")
+				}
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				writeCanonicalString(buf, symbolName)
+			}
+			buf.WriteByte(']')
+		case "items", "values":
+			if (attr == "items" && typeName != "array") || (attr == "values" && typeName != "map") {
+				continue
+			}
+			child, ok := s[attr]
+			if !ok {
+				return fmt.Errorf("invalid avro %s: missing %s", typeName, attr)
+			}
+			writeKey(attr)
+			if err := writeCanonical(buf, child, namespace); err != nil {
+				return err
+			}
+		case "size":
+			if typeName != "fixed" {
+				continue
+			}
+			size, ok := s["size"].(float64)
+			if !ok || size < 0 || size != float64(int64(size)) {
+				return fmt.Errorf("invalid avro fixed size")
+			}
+			writeKey(attr)
+			buf.WriteString(strconv.FormatInt(int64(size), 10))
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeCanonicalFields(buf *bytes.Buffer, fields []interface{}, namespace string) error {
+	buf.WriteByte('[')
+	for i, f := range fields {
+		field, ok := f.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid Avro schema field")
+		}
+		fieldName, ok := field["name"].(string)
+		if !ok {
+			return fmt.Errorf("invalid Avro schema field name")
+		}
+		fieldType := field["type"]
+		if fieldType == "record" && field["fields"] != nil {
+			inline := map[string]interface{}{"type": "record", "name": fieldName, "fields": field["fields"]}
+			if ns, ok := field["namespace"].(string); ok {
+				inline["namespace"] = ns
+			}
+			fieldType = inline
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`{"name":`)
+		writeCanonicalString(buf, fieldName)
+		buf.WriteString(`,"type":`)
+		if err := writeCanonical(buf, fieldType, namespace); err != nil {
+			return fmt.Errorf("field %s: %w", fieldName, err)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// writeCanonicalString writes s as a JSON string literal without escaping
+// characters that do not need it.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	var tmp bytes.Buffer
+	enc := json.NewEncoder(&tmp)
+	enc.SetEscapeHTML(false)
+	// Encoding a string never fails.
+	_ = enc.Encode(s)
+	buf.Write(bytes.TrimSuffix(tmp.Bytes(), []byte("\n")))
+}
+
+// fullName qualifies name with namespace unless it already contains a dot.
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// namespaceOf returns the namespace part of a full name.
+func namespaceOf(fullName string) string {
+	if i := strings.LastIndex(fullName, "."); i >= 0 {
+		return fullName[:i]
+	}
+	return ""
+}
+
+// SchemaFullName returns the full name (namespace and name) of a named
+// Avro schema, or "" if the schema has no name.
+func SchemaFullName(avroSchema map[string]interface{}) string {
+	name, _ := avroSchema["name"].(string)
+	if name == "" {
+		return ""
+	}
+	namespace, _ := avroSchema["namespace"].(string)
+	return fullName(name, namespace)
+}
+
+// FingerprintCRC64 returns the CRC-64-AVRO fingerprint of data, normally
+// the Parsing Canonical Form of a schema. This is the 64-bit fingerprint
+// used by Avro single-object encoding.
+func FingerprintCRC64(data []byte) uint64 {
+	fp := crc64Empty
+	for _, b := range data {
+		fp = (fp >> 8) ^ crc64Table[byte(fp)^b]
+	}
+	return fp
+}
+
+// FingerprintMD5 returns the MD5 fingerprint of data.
+func FingerprintMD5(data []byte) [md5.Size]byte {
+	return md5.Sum(data)
+}
+
+// FingerprintSHA256 returns the SHA-256 fingerprint of data.
+func FingerprintSHA256(data []byte) [sha256.Size]byte {
+	return sha256.Sum256(data)
+}
+
+// Fingerprint returns the CRC-64-AVRO fingerprint of the Parsing Canonical
+// Form of avroSchema.
+func Fingerprint(avroSchema interface{}) (uint64, error) {
+	canonical, err := CanonicalForm(avroSchema)
+	if err != nil {
+		return 0, err
+	}
+	return FingerprintCRC64(canonical), nil
+}
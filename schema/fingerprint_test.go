@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestCanonicalForm(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    string
+		canonical string
+	}{
+		{"primitive", `"int"`, `"int"`},
+		{"primitive object", `{"type": "long", "logicalType": "timestamp-millis"}`, `"long"`},
+		{
+			"record with namespace",
+			`{"type": "record", "name": "Test", "namespace": "x.y", "doc": "d",
+			  "fields": [{"name": "f", "type": "long", "default": 1, "doc": "field"}]}`,
+			`{"name":"x.y.Test","type":"record","fields":[{"name":"f","type":"long"}]}`,
+		},
+		{
+			"nested names inherit namespace",
+			`{"type": "record", "name": "a.Outer", "fields": [
+			  {"name": "inner", "type": {"type": "fixed", "name": "Inner", "size": 16, "aliases": ["x"]}},
+			  {"name": "again", "type": "Inner"},
+			  {"name": "e", "type": ["null", {"type": "enum", "name": "E", "namespace": "b", "symbols": ["A", "B"]}]}]}`,
+			`{"name":"a.Outer","type":"record","fields":[{"name":"inner","type":{"name":"a.Inner","type":"fixed","size":16}},` +
+				`{"name":"again","type":"a.Inner"},{"name":"e","type":["null",{"name":"b.E","type":"enum","symbols":["A","B"]}]}]}`,
+		},
+		{
+			"array and map",
+			`{"type": "array", "items": {"type": "map", "values": "string", "default": {}}}`,
+			`{"type":"array","items":{"type":"map","values":"string"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var avroSchema interface{}
+			if err := json.Unmarshal([]byte(tt.schema), &avroSchema); err != nil {
+				t.Fatalf("Error parsing Avro schema: %v", err)
+			}
+			canonical, err := CanonicalForm(avroSchema)
+			if err != nil {
+				t.Fatalf("Error computing canonical form: %v", err)
+			}
+			if string(canonical) != tt.canonical {
+				t.Fatalf("Expected %s, but got %s", tt.canonical, canonical)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	// Reference values from the Avro specification test suite.
+	primitives := map[string]int64{
+		"null":    7195948357588979594,
+		"boolean": -6970731678124411036,
+		"int":     8247732601305521295,
+		"long":    -3434872931120570953,
+		"float":   5583340709985441680,
+		"double":  -8181574048448539266,
+		"bytes":   5746618253357095269,
+		"string":  -8142146995180207161,
+	}
+	for name, want := range primitives {
+		fp, err := Fingerprint(name)
+		if err != nil {
+			t.Fatalf("Error fingerprinting %s: %v", name, err)
+		}
+		if int64(fp) != want {
+			t.Fatalf("%s: Expected fingerprint %d, but got %d", name, want, int64(fp))
+		}
+	}
+
+	md5sum := FingerprintMD5([]byte(`"int"`))
+	if hex.EncodeToString(md5sum[:]) != "ef524ea1b91e73173d938ade36c1db32" {
+		t.Fatalf("Unexpected MD5 fingerprint %x", md5sum)
+	}
+	sha := FingerprintSHA256([]byte(`"int"`))
+	if hex.EncodeToString(sha[:]) != "3f2b87a9fe7cc9b13835598c3981cd45e3e355309e5090aa0933d7becb6fba45" {
+		t.Fatalf("Unexpected SHA-256 fingerprint %x", sha)
+	}
+
+	t.Run("documentation does not change the fingerprint", func(t *testing.T) {
+		a := map[string]interface{}{"type": "record", "name": "R", "fields": []interface{}{
+			map[string]interface{}{"name": "f", "type": "int"},
+		}}
+		b := map[string]interface{}{"type": "record", "name": "R", "doc": "docs", "fields": []interface{}{
+			map[string]interface{}{"name": "f", "type": "int", "doc": "field docs"},
+		}}
+		c := map[string]interface{}{"type": "record", "name": "R", "fields": []interface{}{
+			map[string]interface{}{"name": "f", "type": "long"},
+		}}
+		fpA, _ := Fingerprint(a)
+		fpB, _ := Fingerprint(b)
+		fpC, _ := Fingerprint(c)
+		if fpA != fpB {
+			t.Fatalf("Expected equal fingerprints, but got %x and %x", fpA, fpB)
+		}
+		if fpA == fpC {
+			t.Fatalf("Expected different fingerprints for different field types")
+		}
+	})
+}
+
+func TestSchemaFullName(t *testing.T) {
+	if got := SchemaFullName(map[string]interface{}{"name": "User", "namespace": "com.example"}); got != "com.example.User" {
+		t.Fatalf("Expected com.example.User, but got %s", got)
+	}
+	if got := SchemaFullName(map[string]interface{}{"name": "a.b.User", "namespace": "ignored"}); got != "a.b.User" {
+		t.Fatalf("Expected a.b.User, but got %s", got)
+	}
+}
@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// DefaultFlattenSeparator joins the names of nested fields when flattening.
+const DefaultFlattenSeparator = "__"
+
+// ArrayHandling selects what FlattenSchema does with REPEATED columns.
+type ArrayHandling string
+
+const (
+	// ArraysKeep leaves REPEATED columns, including REPEATED RECORDs,
+	// unchanged.
+	ArraysKeep ArrayHandling = "keep"
+	// ArraysAsJSON replaces REPEATED columns with a single nullable JSON
+	// column holding the whole array.
+	ArraysAsJSON ArrayHandling = "json"
+)
+
+// FlattenOptions configures FlattenSchema.
+type FlattenOptions struct {
+	// Separator joins parent and child names, e.g. address__city. Defaults
+	// to DefaultFlattenSeparator.
+	Separator string
+	// Arrays selects how REPEATED columns are handled. Defaults to
+	// ArraysKeep.
+	Arrays ArrayHandling
+}
+
+// FlattenedColumn maps a column of a flattened schema to the path of the
+// value in the nested schema it was derived from.
+type FlattenedColumn struct {
+	// Path is the dot separated path of the value in the nested schema.
+	Path string `json:"path"`
+	// Column is the name of the top-level column.
+	Column string `json:"column"`
+	// JSON is set when the value must be encoded as JSON, which is the
+	// case for arrays flattened with ArraysAsJSON.
+	JSON bool `json:"json,omitempty"`
+}
+
+// FlattenSchema turns every non-repeated RECORD column of s into top-level
+// columns named after the path of the nested field, joined with
+// opts.Separator. A field inside a NULLABLE record becomes NULLABLE, since
+// the whole record may be missing. REPEATED columns are kept or turned
+// into JSON columns according to opts.Arrays. The input schema is not
+// modified.
+//
+// The returned columns describe, in schema order, where each top-level
+// column takes its value from; FlattenRow uses them to flatten data.
+func FlattenSchema(s bigquery.Schema, opts FlattenOptions) (bigquery.Schema, []FlattenedColumn) {
+	if opts.Separator == "" {
+		opts.Separator = DefaultFlattenSeparator
+	}
+	if opts.Arrays == "" {
+		opts.Arrays = ArraysKeep
+	}
+	var out bigquery.Schema
+	var columns []FlattenedColumn
+	flattenFields(s, "", "", true, opts, &out, &columns)
+	return out, columns
+}
+
+func flattenFields(fields bigquery.Schema, pathPrefix, namePrefix string, parentRequired bool, opts FlattenOptions,
+	out *bigquery.Schema, columns *[]FlattenedColumn) {
+	for _, f := range fields {
+		path := joinPath(pathPrefix, f.Name)
+		name := f.Name
+		if namePrefix != "" {
+			name = namePrefix + opts.Separator + f.Name
+		}
+
+		if isRecord(f) && !f.Repeated {
+			flattenFields(f.Schema, path, name, parentRequired && f.Required, opts, out, columns)
+			continue
+		}
+
+		column := *f
+		column.Name = name
+		column.Required = f.Required && parentRequired
+		asJSON := f.Repeated && opts.Arrays == ArraysAsJSON
+		if asJSON {
+			column = bigquery.FieldSchema{Name: name, Type: bigquery.JSONFieldType, Description: f.Description}
+		}
+		*out = append(*out, &column)
+		*columns = append(*columns, FlattenedColumn{Path: path, Column: name, JSON: asJSON})
+	}
+}
+
+// FlattenRow converts a row of the nested schema into a row of the schema
+// returned by FlattenSchema, using the columns it returned. Missing values
+// and values below a missing record become nil.
+func FlattenRow(row map[string]bigquery.Value, columns []FlattenedColumn) (map[string]bigquery.Value, error) {
+	out := make(map[string]bigquery.Value, len(columns))
+	for _, c := range columns {
+		v := lookupPath(row, strings.Split(c.Path, "."))
+		if c.JSON && v != nil {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", c.Path, err)
+			}
+			v = string(data)
+		}
+		out[c.Column] = v
+	}
+	return out, nil
+}
+
+func lookupPath(v interface{}, path []string) interface{} {
+	for _, name := range path {
+		switch m := v.(type) {
+		case map[string]bigquery.Value:
+			v = m[name]
+		case map[string]interface{}:
+			v = m[name]
+		default:
+			return nil
+		}
+	}
+	return v
+}
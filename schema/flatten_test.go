@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestFlattenSchema(t *testing.T) {
+	nested := bigquery.Schema{
+		{Name: "id", Type: bigquery.IntegerFieldType, Required: true},
+		{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "city", Type: bigquery.StringFieldType, Required: true},
+			{Name: "geo", Type: bigquery.RecordFieldType, Required: true, Schema: bigquery.Schema{
+				{Name: "lat", Type: bigquery.FloatFieldType, Required: true},
+			}},
+		}},
+		{Name: "meta", Type: bigquery.RecordFieldType, Required: true, Schema: bigquery.Schema{
+			{Name: "source", Type: bigquery.StringFieldType, Required: true},
+		}},
+		{Name: "items", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+			{Name: "sku", Type: bigquery.StringFieldType},
+		}},
+	}
+
+	t.Run("keep arrays", func(t *testing.T) {
+		flat, columns := FlattenSchema(nested, FlattenOptions{})
+		want := []struct {
+			name     string
+			required bool
+		}{
+			{"id", true},
+			{"address__city", false},
+			{"address__geo__lat", false},
+			{"meta__source", true},
+			{"items", false},
+		}
+		if len(flat) != len(want) {
+			t.Fatalf("Expected %d columns, but got %d", len(want), len(flat))
+		}
+		for i, w := range want {
+			if flat[i].Name != w.name || flat[i].Required != w.required {
+				t.Fatalf("Column %d: Expected (%s, required=%v), but got (%s, required=%v)", i, w.name, w.required, flat[i].Name, flat[i].Required)
+			}
+		}
+		if flat[4].Type != bigquery.RecordFieldType || !flat[4].Repeated {
+			t.Fatalf("Expected the repeated record to be kept, but got %+v", flat[4])
+		}
+		if columns[2].Path != "address.geo.lat" || columns[2].Column != "address__geo__lat" {
+			t.Fatalf("Unexpected column mapping %+v", columns[2])
+		}
+		if !nested[1].Schema[0].Required {
+			t.Fatalf("Input schema was modified")
+		}
+	})
+
+	t.Run("arrays as JSON with custom separator", func(t *testing.T) {
+		flat, columns := FlattenSchema(nested, FlattenOptions{Separator: "_", Arrays: ArraysAsJSON})
+		if flat[1].Name != "address_city" {
+			t.Fatalf("Expected address_city, but got %s", flat[1].Name)
+		}
+		if flat[4].Type != bigquery.JSONFieldType || flat[4].Repeated || flat[4].Schema != nil || !columns[4].JSON {
+			t.Fatalf("Expected a JSON column for the array, but got %+v", flat[4])
+		}
+
+		row := map[string]bigquery.Value{
+			"id":      int64(1),
+			"address": nil,
+			"meta":    map[string]bigquery.Value{"source": "web"},
+			"items":   []bigquery.Value{map[string]bigquery.Value{"sku": "A1"}},
+		}
+		flatRow, err := FlattenRow(row, columns)
+		if err != nil {
+			t.Fatalf("Error flattening row: %v", err)
+		}
+		if flatRow["address_city"] != nil || flatRow["meta_source"] != "web" || flatRow["items"] != `[{"sku":"A1"}]` {
+			t.Fatalf("Unexpected flattened row %v", flatRow)
+		}
+	})
+}
+
+func TestConvertAvroToBigQueryWithFlatten(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "User",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "homeAddress", "type": []interface{}{"null", map[string]interface{}{
+				"type": "record",
+				"name": "Address",
+				"fields": []interface{}{
+					map[string]interface{}{"name": "city", "type": "string"},
+				},
+			}}},
+		},
+	}
+	conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Naming: NamingSnakeCase, Flatten: &FlattenOptions{}})
+	if err != nil {
+		t.Fatalf("Error converting Avro schema: %v", err)
+	}
+	if len(conversion.Schema) != 1 || conversion.Schema[0].Name != "home_address__city" || conversion.Schema[0].Required {
+		t.Fatalf("Unexpected flattened schema %+v", conversion.Schema)
+	}
+	if got := conversion.Mapping()["homeAddress.city"]; got != "home_address__city" {
+		t.Fatalf("Expected homeAddress.city to map to home_address__city, but got %s", got)
+	}
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy derives the BigQuery column name of an Avro field from the
+// field name. It is applied to the fields of every record at every nesting
+// level, before name sanitization.
+type NamingStrategy interface {
+	ColumnName(avroName string) string
+}
+
+// NamingFunc adapts an ordinary function to a NamingStrategy.
+type NamingFunc func(avroName string) string
+
+// ColumnName returns f(avroName).
+func (f NamingFunc) ColumnName(avroName string) string {
+	return f(avroName)
+}
+
+// Built-in naming strategies.
+var (
+	// NamingAsIs keeps Avro field names unchanged.
+	NamingAsIs NamingStrategy = NamingFunc(func(name string) string { return name })
+	// NamingSnakeCase converts names to snake_case, e.g. orderID becomes
+	// order_id.
+	NamingSnakeCase NamingStrategy = NamingFunc(ToSnakeCase)
+	// NamingLowerCamelCase converts names to lowerCamelCase, e.g.
+	// order_id becomes orderId.
+	NamingLowerCamelCase NamingStrategy = NamingFunc(ToLowerCamelCase)
+)
+
+// NamingStrategyByName returns the built-in naming strategy with the given
+// name: "as-is", "snake_case" or "lowerCamel".
+func NamingStrategyByName(name string) (NamingStrategy, error) {
+	switch name {
+	case "", "as-is":
+		return NamingAsIs, nil
+	case "snake_case":
+		return NamingSnakeCase, nil
+	case "lowerCamel":
+		return NamingLowerCamelCase, nil
+	}
+	return nil, fmt.Errorf("unknown naming strategy %q", name)
+}
+
+// ToSnakeCase converts a camelCase, PascalCase, kebab-case or otherwise
+// delimited name to snake_case. Leading underscores are kept.
+func ToSnakeCase(name string) string {
+	prefix, words := splitWords(name)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+	return prefix + strings.Join(words, "_")
+}
+
+// ToLowerCamelCase converts a snake_case, PascalCase, kebab-case or
+// otherwise delimited name to lowerCamelCase. Leading underscores are
+// kept.
+func ToLowerCamelCase(name string) string {
+	prefix, words := splitWords(name)
+	var b strings.Builder
+	b.WriteString(prefix)
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if i > 0 {
+			r := []rune(w)
+			r[0] = unicode.ToUpper(r[0])
+			w = string(r)
+		}
+		b.WriteString(w)
+	}
+	return b.String()
+}
+
+// splitWords splits name into words at delimiters ('_', '-', '.', spaces)
+// and at case changes. An acronym followed by a capitalized word is split
+// before the last capital, so "HTTPServer" yields "HTTP" and "Server".
+// Digits stay attached to the preceding word. Leading underscores are
+// returned separately as prefix.
+func splitWords(name string) (prefix string, words []string) {
+	trimmed := strings.TrimLeft(name, "_")
+	prefix = name[:len(name)-len(trimmed)]
+
+	runes := []rune(trimmed)
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, string(current))
+			current = nil
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == '.' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && len(current) > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return prefix, words
+}
@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNamingStrategies(t *testing.T) {
+	tests := []struct {
+		in, snake, lowerCamel string
+	}{
+		{"userId", "user_id", "userId"},
+		{"UserName", "user_name", "userName"},
+		{"orderID", "order_id", "orderId"},
+		{"HTTPServerURL", "http_server_url", "httpServerUrl"},
+		{"address2Line", "address2_line", "address2Line"},
+		{"already_snake", "already_snake", "alreadySnake"},
+		{"kebab-case name", "kebab_case_name", "kebabCaseName"},
+		{"_private_field", "_private_field", "_privateField"},
+	}
+	for _, tt := range tests {
+		if got := ToSnakeCase(tt.in); got != tt.snake {
+			t.Fatalf("ToSnakeCase(%q): Expected %q, but got %q", tt.in, tt.snake, got)
+		}
+		if got := ToLowerCamelCase(tt.in); got != tt.lowerCamel {
+			t.Fatalf("ToLowerCamelCase(%q): Expected %q, but got %q", tt.in, tt.lowerCamel, got)
+		}
+	}
+
+	if _, err := NamingStrategyByName("SCREAMING"); err == nil {
+		t.Fatalf("Expected an error for an unknown naming strategy")
+	}
+}
+
+func TestConvertAvroToBigQueryWithNaming(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Order",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "orderId", "type": "long", "aliases": []interface{}{"id"}},
+			map[string]interface{}{"name": "shippingAddress", "type": map[string]interface{}{
+				"type": "record",
+				"name": "Address",
+				"fields": []interface{}{
+					map[string]interface{}{"name": "zipCode", "type": "string"},
+				},
+			}},
+		},
+	}
+
+	t.Run("snake_case at every level", func(t *testing.T) {
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Naming: NamingSnakeCase})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		if conversion.Schema[0].Name != "order_id" || conversion.Schema[1].Schema[0].Name != "zip_code" {
+			t.Fatalf("Unexpected column names: %s, %s", conversion.Schema[0].Name, conversion.Schema[1].Schema[0].Name)
+		}
+
+		mapping := conversion.Fields
+		if mapping[2].AvroPath != "shippingAddress.zipCode" || mapping[2].ColumnPath != "shipping_address.zip_code" {
+			t.Fatalf("Unexpected mapping %+v", mapping[2])
+		}
+		if len(mapping[0].Aliases) != 1 || mapping[0].Aliases[0] != "id" {
+			t.Fatalf("Expected the Avro aliases in the mapping, but got %+v", mapping[0])
+		}
+
+		// The renamed Avro schema keeps the original names as aliases.
+		renamed := conversion.AvroSchema["fields"].([]interface{})[0].(map[string]interface{})
+		aliases := renamed["aliases"].([]interface{})
+		if renamed["name"] != "order_id" || len(aliases) != 2 || aliases[1] != "orderId" {
+			t.Fatalf("Unexpected renamed field %v", renamed)
+		}
+
+		var buf bytes.Buffer
+		if err := conversion.WriteMapping(&buf); err != nil {
+			t.Fatalf("Error writing mapping: %v", err)
+		}
+		if !strings.Contains(buf.String(), `"columnPath": "shipping_address.zip_code"`) {
+			t.Fatalf("Unexpected mapping file:\n%s", buf.String())
+		}
+	})
+
+	t.Run("custom function", func(t *testing.T) {
+		upper := NamingFunc(strings.ToUpper)
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Naming: upper})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		if conversion.Schema[1].Name != "SHIPPINGADDRESS" || conversion.Schema[1].Schema[0].Name != "ZIPCODE" {
+			t.Fatalf("Unexpected column names: %+v", conversion.Schema)
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// Defaults of NormalizeOptions.
+const (
+	DefaultKeyColumn      = "row_id"
+	DefaultOrdinalSuffix  = "_ordinal"
+	DefaultTableSeparator = "_"
+)
+
+// NormalizeOptions configures the normalization stage of
+// ConvertAvroToBigQueryWithOptions, which moves every array of records into
+// a child table.
+type NormalizeOptions struct {
+	// RootTable is the name of the table holding the top-level record.
+	RootTable string
+	// PrimaryKey lists the top-level columns identifying a root row. When
+	// empty, a required STRING column named KeyColumn is added to the root
+	// table and must be filled by the loader.
+	PrimaryKey []string
+	// KeyColumn is the name of the generated root key column. Defaults to
+	// DefaultKeyColumn.
+	KeyColumn string
+	// OrdinalSuffix is appended to the array field name to name the
+	// column holding the position of an element in its array. Defaults to
+	// DefaultOrdinalSuffix, e.g. items_ordinal.
+	OrdinalSuffix string
+	// TableSeparator joins the parent table name and the array field name
+	// to name a child table. Defaults to DefaultTableSeparator, e.g.
+	// orders_items.
+	TableSeparator string
+}
+
+func (o NormalizeOptions) withDefaults() NormalizeOptions {
+	if o.KeyColumn == "" {
+		o.KeyColumn = DefaultKeyColumn
+	}
+	if o.OrdinalSuffix == "" {
+		o.OrdinalSuffix = DefaultOrdinalSuffix
+	}
+	if o.TableSeparator == "" {
+		o.TableSeparator = DefaultTableSeparator
+	}
+	return o
+}
+
+// TableSchema is one table of a normalized schema.
+type TableSchema struct {
+	Name   string
+	Schema bigquery.Schema
+	// PrimaryKey lists the columns identifying a row: the root key for the
+	// root table, and the parent's key plus the ordinal column for a child
+	// table.
+	PrimaryKey []string
+	// Parent is the name of the parent table, empty for the root table.
+	Parent string
+	// ForeignKey lists the columns referencing the parent's primary key.
+	// They have the same names as the parent's key columns.
+	ForeignKey []string
+	// SourcePath is the column path of the array in the nested schema the
+	// child table was split from.
+	SourcePath string
+}
+
+// NormalizeSchema splits every REPEATED RECORD column of s for which
+// isRecordArray returns true into a child table, recursively. Each child
+// table starts with the key columns of its parent followed by an INTEGER
+// ordinal column, and the fields of the array element. A record left
+// without fields once its arrays have moved out is dropped. isRecordArray
+// receives dot separated column paths; when nil, every REPEATED RECORD is
+// split.
+//
+// The root table comes first in the result and every parent precedes its
+// children, which is a valid creation order.
+func NormalizeSchema(s bigquery.Schema, isRecordArray func(path string) bool, opts NormalizeOptions) ([]TableSchema, error) {
+	opts = opts.withDefaults()
+	if opts.RootTable == "" {
+		return nil, fmt.Errorf("normalize: root table name is required")
+	}
+	if isRecordArray == nil {
+		isRecordArray = func(string) bool { return true }
+	}
+
+	var keys bigquery.Schema
+	rootFields := s
+	if len(opts.PrimaryKey) == 0 {
+		key := &bigquery.FieldSchema{Name: opts.KeyColumn, Type: bigquery.StringFieldType, Required: true,
+			Description: "Key of the " + opts.RootTable + " row."}
+		keys = bigquery.Schema{key}
+		rootFields = append(bigquery.Schema{key}, s...)
+	} else {
+		for _, name := range opts.PrimaryKey {
+			f := findField(s, name)
+			if f == nil {
+				return nil, fmt.Errorf("normalize: primary key column %s not found", name)
+			}
+			if f.Repeated || isRecord(f) {
+				return nil, fmt.Errorf("normalize: primary key column %s must not be REPEATED or RECORD", name)
+			}
+			keys = append(keys, f)
+		}
+	}
+
+	n := &normalizer{opts: opts, isRecordArray: isRecordArray}
+	n.tables = append(n.tables, TableSchema{})
+	rootSchema, err := n.extract(opts.RootTable, "", "", rootFields, keys)
+	if err != nil {
+		return nil, err
+	}
+	n.tables[0] = TableSchema{Name: opts.RootTable, Schema: rootSchema, PrimaryKey: fieldNames(keys)}
+	return n.tables, nil
+}
+
+type normalizer struct {
+	opts          NormalizeOptions
+	isRecordArray func(path string) bool
+	tables        []TableSchema
+}
+
+// extract returns the fields of table, moving arrays of records into child
+// tables. path is the column path of fields in the nested schema and
+// relPath their path relative to the table.
+func (n *normalizer) extract(table, path, relPath string, fields bigquery.Schema, keys bigquery.Schema) (bigquery.Schema, error) {
+	var out bigquery.Schema
+	for _, f := range fields {
+		fieldPath, fieldRelPath := joinPath(path, f.Name), joinPath(relPath, f.Name)
+
+		if !isRecord(f) {
+			out = append(out, f)
+			continue
+		}
+		if !f.Repeated || !n.isRecordArray(fieldPath) {
+			record := *f
+			nested, err := n.extract(table, fieldPath, fieldRelPath, f.Schema, keys)
+			if err != nil {
+				return nil, err
+			}
+			if len(nested) == 0 && len(f.Schema) > 0 {
+				// Every field moved to child tables; BigQuery rejects empty
+				// records.
+				continue
+			}
+			record.Schema = nested
+			out = append(out, &record)
+			continue
+		}
+
+		childName := table + n.opts.TableSeparator + strings.ReplaceAll(fieldRelPath, ".", n.opts.TableSeparator)
+		ordinal := &bigquery.FieldSchema{
+			Name:        strings.ReplaceAll(fieldRelPath, ".", "_") + n.opts.OrdinalSuffix,
+			Type:        bigquery.IntegerFieldType,
+			Required:    true,
+			Description: "Position of the element in " + fieldPath + ".",
+		}
+		childKeys := append(append(bigquery.Schema{}, keys...), ordinal)
+		for _, key := range childKeys {
+			if findField(f.Schema, key.Name) != nil {
+				return nil, fmt.Errorf("normalize: %s: field %s collides with the key column of table %s", fieldPath, key.Name, childName)
+			}
+		}
+
+		idx := len(n.tables)
+		n.tables = append(n.tables, TableSchema{})
+		childFields, err := n.extract(childName, fieldPath, "", f.Schema, childKeys)
+		if err != nil {
+			return nil, err
+		}
+		n.tables[idx] = TableSchema{
+			Name:       childName,
+			Schema:     append(keyColumns(childKeys), childFields...),
+			PrimaryKey: fieldNames(childKeys),
+			Parent:     table,
+			ForeignKey: fieldNames(keys),
+			SourcePath: fieldPath,
+		}
+	}
+	return out, nil
+}
+
+// keyColumns returns required, non-repeated copies of the key fields.
+func keyColumns(keys bigquery.Schema) bigquery.Schema {
+	out := make(bigquery.Schema, len(keys))
+	for i, k := range keys {
+		column := *k
+		column.Required = true
+		column.Repeated = false
+		column.DefaultValueExpression = ""
+		out[i] = &column
+	}
+	return out
+}
+
+func findField(s bigquery.Schema, name string) *bigquery.FieldSchema {
+	for _, f := range s {
+		if strings.EqualFold(f.Name, name) {
+			return f
+		}
+	}
+	return nil
+}
+
+func fieldNames(s bigquery.Schema) []string {
+	names := make([]string, len(s))
+	for i, f := range s {
+		names[i] = f.Name
+	}
+	return names
+}
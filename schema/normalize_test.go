@@ -0,0 +1,107 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertAvroToBigQueryWithNormalize(t *testing.T) {
+	record := func(name string, fields ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"type": "record", "name": name, "fields": fields}
+	}
+	field := func(name string, typ interface{}) map[string]interface{} {
+		return map[string]interface{}{"name": name, "type": typ}
+	}
+	array := func(items interface{}) map[string]interface{} {
+		return map[string]interface{}{"type": "array", "items": items, "name": "tag"}
+	}
+
+	avroSchema := record("Order",
+		field("order_id", "string"),
+		field("tags", array("string")),
+		field("items", array(record("Item",
+			field("sku", "string"),
+			field("discounts", array(record("Discount", field("code", "string")))),
+		))),
+		field("shipping", record("Shipping",
+			field("packages", array(record("Package", field("weight", "double")))),
+		)),
+	)
+
+	t.Run("primary key from column", func(t *testing.T) {
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{
+			Normalize: &NormalizeOptions{RootTable: "orders", PrimaryKey: []string{"order_id"}},
+		})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+
+		want := []struct {
+			name       string
+			columns    []string
+			primaryKey []string
+			parent     string
+			foreignKey []string
+		}{
+			{"orders", []string{"order_id", "tags"}, []string{"order_id"}, "", nil},
+			{"orders_items", []string{"order_id", "items_ordinal", "sku"}, []string{"order_id", "items_ordinal"}, "orders", []string{"order_id"}},
+			{"orders_items_discounts", []string{"order_id", "items_ordinal", "discounts_ordinal", "code"},
+				[]string{"order_id", "items_ordinal", "discounts_ordinal"}, "orders_items", []string{"order_id", "items_ordinal"}},
+			{"orders_shipping_packages", []string{"order_id", "shipping_packages_ordinal", "weight"},
+				[]string{"order_id", "shipping_packages_ordinal"}, "orders", []string{"order_id"}},
+		}
+		if len(conversion.Tables) != len(want) {
+			t.Fatalf("Expected %d tables, but got %d", len(want), len(conversion.Tables))
+		}
+		for i, w := range want {
+			got := conversion.Tables[i]
+			if got.Name != w.name || !reflect.DeepEqual(fieldNames(got.Schema), w.columns) ||
+				!reflect.DeepEqual(got.PrimaryKey, w.primaryKey) || got.Parent != w.parent ||
+				!reflect.DeepEqual(got.ForeignKey, w.foreignKey) {
+				t.Fatalf("Table %d: Expected %+v, but got %s %v pk=%v parent=%s fk=%v",
+					i, w, got.Name, fieldNames(got.Schema), got.PrimaryKey, got.Parent, got.ForeignKey)
+			}
+		}
+		if !conversion.Tables[1].Schema[0].Required || !conversion.Tables[1].Schema[1].Required {
+			t.Fatalf("Expected required key columns in child table")
+		}
+		if tags := conversion.Schema[1]; !tags.Repeated || len(tags.Schema) != 1 {
+			t.Fatalf("Expected the array of primitives to stay in the root table, but got %+v", tags)
+		}
+	})
+
+	t.Run("generated key", func(t *testing.T) {
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{
+			Normalize: &NormalizeOptions{RootTable: "orders", KeyColumn: "order_key"},
+		})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		root, items := conversion.Tables[0], conversion.Tables[1]
+		if root.Schema[0].Name != "order_key" || !root.Schema[0].Required || !reflect.DeepEqual(root.PrimaryKey, []string{"order_key"}) {
+			t.Fatalf("Expected a generated order_key column, but got %v pk=%v", fieldNames(root.Schema), root.PrimaryKey)
+		}
+		if !reflect.DeepEqual(items.ForeignKey, []string{"order_key"}) || items.Schema[0].Name != "order_key" {
+			t.Fatalf("Expected orders_items to reference order_key, but got %v fk=%v", fieldNames(items.Schema), items.ForeignKey)
+		}
+	})
+
+	t.Run("combined with flatten", func(t *testing.T) {
+		_, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{
+			Flatten:   &FlattenOptions{},
+			Normalize: &NormalizeOptions{RootTable: "orders"},
+		})
+		if err == nil {
+			t.Fatalf("Expected an error when combining flattening and normalization")
+		}
+	})
+
+	t.Run("unknown primary key", func(t *testing.T) {
+		_, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{
+			Normalize: &NormalizeOptions{RootTable: "orders", PrimaryKey: []string{"missing"}},
+		})
+		if err == nil {
+			t.Fatalf("Expected an error for an unknown primary key column")
+		}
+	})
+}
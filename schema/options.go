@@ -0,0 +1,257 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// ConvertOptions configures ConvertAvroToBigQueryWithOptions. The zero
+// value converts exactly like ConvertAvroToBigQuery.
+type ConvertOptions struct {
+	// Naming derives column names from Avro field names. Defaults to
+	// NamingAsIs.
+	Naming NamingStrategy
+	// Sanitize, when non-nil, rewrites field names that BigQuery would
+	// reject and resolves case-insensitive collisions between sibling
+	// fields.
+	Sanitize *SanitizeOptions
+	// Flatten, when non-nil, turns nested records into top-level columns
+	// (see FlattenSchema).
+	Flatten *FlattenOptions
+	// Normalize, when non-nil, moves every array of records into a child
+	// table (see NormalizeSchema). It cannot be combined with Flatten.
+	Normalize *NormalizeOptions
+	// Enums, when non-nil, selects how enums are converted and which of
+	// their symbols, defaults and docs are kept (see EnumOptions).
+	Enums *EnumOptions
+	// Overrides forces the type, mode, description, maximum length or
+	// policy tag of matching fields after the default mapping, before
+	// flattening or normalization.
+	Overrides Overrides
+	// TypeMapper maps Avro types to BigQuery types. Defaults to the type
+	// mapper of Profile, or DefaultTypeMapper.
+	TypeMapper TypeMapper
+	// Profile, when non-nil, reproduces the mapping of another tool (see
+	// ProfileByName).
+	Profile *Profile
+	// PolicyTagTaxonomy is the resource name of the taxonomy, of the form
+	// projects/*/locations/*/taxonomies/*, that qualifies policy tags given
+	// by ID only in annotations or overrides (see ResolvePolicyTags).
+	PolicyTagTaxonomy string
+	// Classify, when non-nil, flags fields that likely hold personal data
+	// and optionally attaches policy tags to them (see ClassifyOptions).
+	// It runs after overrides, so overridden policy tags are kept.
+	Classify *ClassifyOptions
+	// StrictAnnotations rejects unknown annotation properties (see
+	// AnnotationPrefix) instead of ignoring them with a warning.
+	StrictAnnotations bool
+}
+
+// FieldMapping records the BigQuery column an Avro field was converted to.
+// Both paths are dot separated, e.g. "address.zipCode". Aliases lists the
+// Avro aliases of the field, under which data may also be written.
+type FieldMapping struct {
+	AvroPath   string   `json:"avroPath"`
+	ColumnPath string   `json:"columnPath"`
+	Aliases    []string `json:"aliases,omitempty"`
+}
+
+// Renamed reports whether the column path differs from the Avro field
+// path.
+func (m FieldMapping) Renamed() bool {
+	return m.AvroPath != m.ColumnPath
+}
+
+// Conversion is the result of ConvertAvroToBigQueryWithOptions.
+type Conversion struct {
+	// Schema is the converted and validated BigQuery schema.
+	Schema bigquery.Schema
+	// Fields maps every Avro field, in schema order, to its BigQuery
+	// column. With flattening, records that were expanded map to the
+	// common prefix of their columns.
+	Fields []FieldMapping
+	// Flattened describes the columns produced by flattening; it is nil
+	// unless ConvertOptions.Flatten is set.
+	Flattened []FlattenedColumn
+	// Tables holds the normalized tables, root first; it is nil unless
+	// ConvertOptions.Normalize is set, in which case Schema is the schema
+	// of the root table.
+	Tables []TableSchema
+	// EnumChecks lists the enum columns to validate; it is nil unless
+	// ConvertOptions.Enums selects EnumWithCheck.
+	EnumChecks []EnumCheck
+	// Classifications lists the fields flagged by classification, with
+	// column paths like Fields; it is nil unless ConvertOptions.Classify
+	// is set. ClassificationLabels summarizes it as table labels.
+	Classifications []Classification
+	// AvroSchema is the Avro schema with every field renamed to its column
+	// name. Renamed fields carry their original name in "aliases", so it
+	// can be used as a reader schema for data written with the original
+	// schema.
+	AvroSchema map[string]interface{}
+	// Warnings lists the unknown annotation properties that were ignored.
+	Warnings []string
+}
+
+// Mapping returns the field mapping as a map from Avro field path to
+// BigQuery column path.
+func (c *Conversion) Mapping() map[string]string {
+	m := make(map[string]string, len(c.Fields))
+	for _, f := range c.Fields {
+		m[f.AvroPath] = f.ColumnPath
+	}
+	return m
+}
+
+// WriteMapping writes the field mapping as an indented JSON document of the
+// form {"fields": [{"avroPath": ..., "columnPath": ..., "aliases": [...]}]}
+// for data loaders that need to translate Avro field names to columns.
+// When the schema was flattened, the document also lists the flattened
+// columns under "flattened".
+func (c *Conversion) WriteMapping(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(struct {
+		Fields    []FieldMapping    `json:"fields"`
+		Flattened []FlattenedColumn `json:"flattened,omitempty"`
+	}{c.Fields, c.Flattened})
+}
+
+// ConvertAvroToBigQueryWithOptions converts avroSchema like
+// ConvertAvroToBigQuery, applying the stages configured in opts, and
+// returns the BigQuery schema together with the mapping from Avro field
+// paths to BigQuery column paths. Annotations of fields and types (see
+// AnnotationPrefix) are applied after enum handling, overrides after
+// annotations, and policy tags are resolved last.
+func ConvertAvroToBigQueryWithOptions(avroSchema map[string]interface{}, opts ConvertOptions) (*Conversion, error) {
+	if opts.Flatten != nil && opts.Normalize != nil {
+		return nil, fmt.Errorf("flattening and normalization cannot be combined")
+	}
+	r := newFieldRenamer(opts)
+	renamed := r.renameRecord(avroSchema, "", "")
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	fields, err := newConverter(opts.TypeMapper, opts.Profile).convertSchema(renamed)
+	if err != nil {
+		return nil, err
+	}
+	conversion := &Conversion{Schema: fields, Fields: r.mappings, AvroSchema: renamed}
+
+	var checks []EnumCheck
+	if opts.Enums != nil {
+		if checks, err = applyEnums(fields, "", r.enums, *opts.Enums); err != nil {
+			return nil, err
+		}
+	}
+	if conversion.Warnings, err = applyAnnotations(fields, r.annotations, opts.StrictAnnotations); err != nil {
+		return nil, err
+	}
+	if len(opts.Overrides) > 0 {
+		if err := opts.Overrides.apply(fields, r.mappings); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Classify != nil {
+		if conversion.Classifications, err = classify(fields, r.mappings, r.fields, r.recordArrays, *opts.Classify); err != nil {
+			return nil, err
+		}
+	}
+	if err := ResolvePolicyTags(fields, opts.PolicyTagTaxonomy); err != nil {
+		return nil, err
+	}
+	for i, c := range conversion.Classifications {
+		if f := lookupField(fields, c.ColumnPath); f != nil {
+			conversion.Classifications[i].PolicyTag = policyTagString(f)
+		}
+	}
+
+	if opts.Flatten != nil {
+		conversion.Schema, conversion.Flattened = FlattenSchema(fields, *opts.Flatten)
+		separator := opts.Flatten.Separator
+		if separator == "" {
+			separator = DefaultFlattenSeparator
+		}
+		columns := make(map[string]string, len(conversion.Flattened))
+		for _, c := range conversion.Flattened {
+			columns[c.Path] = c.Column
+		}
+		for i := range conversion.Fields {
+			conversion.Fields[i].ColumnPath = flattenedPath(conversion.Fields[i].ColumnPath, columns, separator)
+		}
+		for i := range checks {
+			checks[i].ColumnPath = flattenedPath(checks[i].ColumnPath, columns, separator)
+		}
+		for i := range conversion.Classifications {
+			c := &conversion.Classifications[i]
+			c.ColumnPath = flattenedPath(c.ColumnPath, columns, separator)
+		}
+	}
+
+	if opts.Normalize != nil {
+		isRecordArray := func(path string) bool { return r.recordArrays[path] }
+		conversion.Tables, err = NormalizeSchema(fields, isRecordArray, *opts.Normalize)
+		if err != nil {
+			return nil, err
+		}
+		conversion.Schema = conversion.Tables[0].Schema
+		conversion.addEnumChecks(checks)
+		for _, t := range conversion.Tables {
+			if err := ValidateBigQuerySchema(t.Schema); err != nil {
+				return nil, fmt.Errorf("table %s: %w", t.Name, err)
+			}
+		}
+		return conversion, nil
+	}
+
+	conversion.addEnumChecks(checks)
+	if err := ValidateBigQuerySchema(conversion.Schema); err != nil {
+		return nil, err
+	}
+	return conversion, nil
+}
+
+// addEnumChecks completes checks, whose column paths have been translated
+// through flattening, with the table holding the column and the
+// condition, and adds them to c. Columns that no longer exist as such,
+// such as arrays flattened into JSON, are not checked.
+func (c *Conversion) addEnumChecks(checks []EnumCheck) {
+	for _, check := range checks {
+		s := c.Schema
+		source := ""
+		if len(c.Tables) > 0 {
+			check.Table = c.Tables[0].Name
+		}
+		for _, t := range c.Tables {
+			// The longest source path is the innermost table.
+			if t.SourcePath != "" && len(t.SourcePath) > len(source) && strings.HasPrefix(check.ColumnPath, t.SourcePath+".") {
+				source, check.Table, s = t.SourcePath, t.Name, t.Schema
+			}
+		}
+		if source != "" {
+			check.ColumnPath = strings.TrimPrefix(check.ColumnPath, source+".")
+		}
+		if condition, ok := enumCondition(s, strings.Split(check.ColumnPath, "."), "", 0, check.Symbols); ok {
+			check.Condition = condition
+			c.EnumChecks = append(c.EnumChecks, check)
+		}
+	}
+}
+
+// flattenedPath translates a nested column path into the flattened schema:
+// the longest prefix that became a column is replaced by the column name,
+// and any other path is joined with the separator.
+func flattenedPath(path string, columns map[string]string, separator string) string {
+	segments := strings.Split(path, ".")
+	for n := len(segments); n > 0; n-- {
+		if column, ok := columns[strings.Join(segments[:n], ".")]; ok {
+			return strings.Join(append([]string{column}, segments[n:]...), ".")
+		}
+	}
+	return strings.Join(segments, separator)
+}
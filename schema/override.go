@@ -0,0 +1,294 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"gopkg.in/yaml.v3"
+)
+
+// FieldOverride forces properties of the column an Avro field is converted
+// to. Empty properties leave the converted column unchanged.
+type FieldOverride struct {
+	// Type is a BigQuery type such as TIMESTAMP or JSON; the GoogleSQL
+	// names INT64, FLOAT64, BOOL and STRUCT are accepted too. Overriding a
+	// RECORD column with another type drops its fields.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// Mode is NULLABLE, REQUIRED or REPEATED.
+	Mode        string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// MaxLength limits the length of STRING and BYTES columns.
+	MaxLength int64 `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	// PolicyTag is the resource name of a policy tag, of the form
+	// projects/*/locations/*/taxonomies/*/policyTags/*, or its ID within
+	// ConvertOptions.PolicyTagTaxonomy.
+	PolicyTag string `json:"policyTag,omitempty" yaml:"policyTag,omitempty"`
+}
+
+// Overrides maps Avro field paths or path patterns to the overrides of the
+// matching fields. Paths are dot separated, e.g. "order.created_at". In a
+// pattern, each segment is matched with path.Match, so "*" matches exactly
+// one field name, and a "**" segment matches any number of segments:
+// "*.created_at" matches created_at in every top-level record and
+// "**.created_at" matches it at any depth.
+//
+// When several keys match a field, their properties are merged: an exact
+// path takes precedence over patterns, and a longer pattern over a shorter
+// one.
+type Overrides map[string]FieldOverride
+
+// overrideTypes maps the accepted type names to BigQuery field types.
+var overrideTypes = map[string]bigquery.FieldType{
+	"STRING":     bigquery.StringFieldType,
+	"BYTES":      bigquery.BytesFieldType,
+	"INTEGER":    bigquery.IntegerFieldType,
+	"INT64":      bigquery.IntegerFieldType,
+	"FLOAT":      bigquery.FloatFieldType,
+	"FLOAT64":    bigquery.FloatFieldType,
+	"BOOLEAN":    bigquery.BooleanFieldType,
+	"BOOL":       bigquery.BooleanFieldType,
+	"TIMESTAMP":  bigquery.TimestampFieldType,
+	"RECORD":     bigquery.RecordFieldType,
+	"STRUCT":     bigquery.RecordFieldType,
+	"DATE":       bigquery.DateFieldType,
+	"TIME":       bigquery.TimeFieldType,
+	"DATETIME":   bigquery.DateTimeFieldType,
+	"NUMERIC":    bigquery.NumericFieldType,
+	"BIGNUMERIC": bigquery.BigNumericFieldType,
+	"GEOGRAPHY":  bigquery.GeographyFieldType,
+	"INTERVAL":   bigquery.IntervalFieldType,
+	"JSON":       bigquery.JSONFieldType,
+}
+
+// LoadOverrides reads the override file at path. Files ending in ".json"
+// are parsed as JSON, anything else as YAML.
+func LoadOverrides(path string) (Overrides, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	o, err := ParseOverrides(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return o, nil
+}
+
+// ParseOverrides parses override data in the given format ("json" or
+// "yaml") and validates the result.
+func ParseOverrides(data []byte, format string) (Overrides, error) {
+	var o Overrides
+	switch strings.ToLower(format) {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&o); err != nil {
+			return nil, fmt.Errorf("invalid overrides: %w", err)
+		}
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&o); err != nil {
+			return nil, fmt.Errorf("invalid overrides: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported overrides format %q", format)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o Overrides) validate() error {
+	for key, override := range o {
+		for _, segment := range strings.Split(key, ".") {
+			if _, err := path.Match(segment, ""); err != nil || segment == "" {
+				return fmt.Errorf("%s: invalid field path or pattern", key)
+			}
+		}
+		if err := override.validate(); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (override FieldOverride) validate() error {
+	if override.Type != "" {
+		if _, ok := overrideTypes[strings.ToUpper(override.Type)]; !ok {
+			return fmt.Errorf("unknown type %q", override.Type)
+		}
+	}
+	switch strings.ToUpper(override.Mode) {
+	case "", "NULLABLE", "REQUIRED", "REPEATED":
+	default:
+		return fmt.Errorf("unknown mode %q", override.Mode)
+	}
+	if override.MaxLength < 0 {
+		return fmt.Errorf("maxLength must not be negative")
+	}
+	return nil
+}
+
+// apply applies the overrides to the columns of s, located through the
+// field mappings. An exact path that names no column is an error, unless
+// the column was dropped along with its record, while patterns may match
+// nothing.
+func (o Overrides) apply(s bigquery.Schema, mappings []FieldMapping) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	matched := make(map[string]bool, len(o))
+	for _, m := range mappings {
+		keys := o.matching(m.AvroPath)
+		if len(keys) == 0 {
+			continue
+		}
+		f := lookupField(s, m.ColumnPath)
+		if f == nil {
+			if droppedByRecord(s, m.ColumnPath) {
+				// The column was dropped by an override of its record.
+				for _, key := range keys {
+					matched[key] = true
+				}
+			}
+			continue
+		}
+		for _, key := range keys {
+			matched[key] = true
+			if err := o[key].applyTo(f); err != nil {
+				return fmt.Errorf("%s: %w", m.AvroPath, err)
+			}
+		}
+	}
+	for key := range o {
+		if !isPattern(key) && !matched[key] {
+			return fmt.Errorf("override for unknown field %s", key)
+		}
+	}
+	return nil
+}
+
+// matching returns the keys matching avroPath, least specific first.
+func (o Overrides) matching(avroPath string) []string {
+	segments := strings.Split(avroPath, ".")
+	var keys []string
+	for key := range o {
+		if matchSegments(strings.Split(key, "."), segments) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if isPattern(a) != isPattern(b) {
+			return isPattern(a)
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return keys
+}
+
+func (override FieldOverride) applyTo(f *bigquery.FieldSchema) error {
+	if override.Type != "" {
+		t := overrideTypes[strings.ToUpper(override.Type)]
+		if t == bigquery.RecordFieldType && !isRecord(f) {
+			return fmt.Errorf("cannot override %s with RECORD", f.Type)
+		}
+		if t != bigquery.RecordFieldType {
+			f.Schema = nil
+		}
+		if t != bigquery.NumericFieldType && t != bigquery.BigNumericFieldType {
+			f.Precision, f.Scale = 0, 0
+		}
+		if t != bigquery.StringFieldType && t != bigquery.BytesFieldType {
+			f.MaxLength = 0
+		}
+		f.Type = t
+	}
+	switch strings.ToUpper(override.Mode) {
+	case "NULLABLE":
+		f.Required, f.Repeated = false, false
+	case "REQUIRED":
+		f.Required, f.Repeated = true, false
+	case "REPEATED":
+		f.Required, f.Repeated = false, true
+	}
+	if override.Description != "" {
+		f.Description = override.Description
+	}
+	if override.MaxLength > 0 {
+		if f.Type != bigquery.StringFieldType && f.Type != bigquery.BytesFieldType {
+			return fmt.Errorf("maxLength requires a STRING or BYTES column, not %s", f.Type)
+		}
+		f.MaxLength = override.MaxLength
+	}
+	if override.PolicyTag != "" {
+		f.PolicyTags = &bigquery.PolicyTagList{Names: []string{override.PolicyTag}}
+	}
+	return nil
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches any number of path segments.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segments[0])
+	return ok && matchSegments(pattern[1:], segments[1:])
+}
+
+func isPattern(key string) bool {
+	return strings.ContainsAny(key, `*?[\`)
+}
+
+// lookupField returns the column at the dot separated path in s, or nil.
+// droppedByRecord reports whether an ancestor of the column columnPath is
+// no longer a RECORD, so that the column was dropped along with its
+// fields.
+func droppedByRecord(s bigquery.Schema, columnPath string) bool {
+	for i := strings.LastIndex(columnPath, "."); i >= 0; i = strings.LastIndex(columnPath, ".") {
+		columnPath = columnPath[:i]
+		if f := lookupField(s, columnPath); f != nil {
+			return !isRecord(f)
+		}
+	}
+	return false
+}
+
+func lookupField(s bigquery.Schema, columnPath string) *bigquery.FieldSchema {
+	var f *bigquery.FieldSchema
+	for _, name := range strings.Split(columnPath, ".") {
+		if f = findField(s, name); f == nil {
+			return nil
+		}
+		s = f.Schema
+	}
+	return f
+}
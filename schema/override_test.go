@@ -0,0 +1,146 @@
+package schema
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestParseOverrides(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		o, err := ParseOverrides([]byte(`
+"*.created_at":
+  type: TIMESTAMP
+payload:
+  type: json
+  mode: NULLABLE
+`), "yaml")
+		if err != nil {
+			t.Fatalf("Error parsing overrides: %v", err)
+		}
+		if len(o) != 2 || o["*.created_at"].Type != "TIMESTAMP" || o["payload"].Mode != "NULLABLE" {
+			t.Fatalf("Unexpected overrides: %+v", o)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		o, err := ParseOverrides([]byte(`{"name": {"maxLength": 64, "policyTag": "projects/p/locations/eu/taxonomies/1/policyTags/2"}}`), "json")
+		if err != nil {
+			t.Fatalf("Error parsing overrides: %v", err)
+		}
+		if o["name"].MaxLength != 64 {
+			t.Fatalf("Unexpected overrides: %+v", o)
+		}
+	})
+
+	invalid := map[string]string{
+		"unknown property": `{"name": {"size": 3}}`,
+		"unknown type":     `{"name": {"type": "VARCHAR"}}`,
+		"unknown mode":     `{"name": {"mode": "OPTIONAL"}}`,
+		"bad pattern":      `{"name.[": {"type": "STRING"}}`,
+		"empty segment":    `{"a..b": {"type": "STRING"}}`,
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseOverrides([]byte(data), "json"); err == nil {
+				t.Fatalf("Expected an error for %s", data)
+			}
+		})
+	}
+}
+
+func TestConvertAvroToBigQueryWithOverrides(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Event",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "created_at", "type": "long"},
+			map[string]interface{}{"name": "payload", "type": "string"},
+			map[string]interface{}{"name": "user", "type": map[string]interface{}{
+				"type": "record", "name": "User", "fields": []interface{}{
+					map[string]interface{}{"name": "created_at", "type": "long", "doc": "Signup time."},
+					map[string]interface{}{"name": "name", "type": "string"},
+				},
+			}},
+			map[string]interface{}{"name": "meta", "type": map[string]interface{}{
+				"type": "record", "name": "Meta", "fields": []interface{}{
+					map[string]interface{}{"name": "source", "type": "string"},
+				},
+			}},
+		},
+	}
+
+	t.Run("overrides", func(t *testing.T) {
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{
+			Naming: NamingLowerCamelCase,
+			Overrides: Overrides{
+				"**.created_at":   {Type: "TIMESTAMP", Description: "Epoch millis."},
+				"user.created_at": {Mode: "NULLABLE"},
+				"payload":         {Type: "JSON"},
+				"user.name":       {MaxLength: 64, PolicyTag: "projects/p/locations/eu/taxonomies/1/policyTags/2"},
+				"meta":            {Type: "JSON", Mode: "NULLABLE"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		createdAt, payload, user, meta := conversion.Schema[0], conversion.Schema[1], conversion.Schema[2], conversion.Schema[3]
+		if createdAt.Name != "createdAt" || createdAt.Type != bigquery.TimestampFieldType || !createdAt.Required {
+			t.Fatalf("Expected a required TIMESTAMP createdAt column, but got %+v", createdAt)
+		}
+		if nested := user.Schema[0]; nested.Type != bigquery.TimestampFieldType || nested.Required || nested.Description != "Epoch millis." {
+			t.Fatalf("Expected the exact path to be merged over the pattern, but got %+v", nested)
+		}
+		if payload.Type != bigquery.JSONFieldType {
+			t.Fatalf("Expected a JSON payload column, but got %s", payload.Type)
+		}
+		name := user.Schema[1]
+		if name.MaxLength != 64 || name.PolicyTags == nil || len(name.PolicyTags.Names) != 1 {
+			t.Fatalf("Expected maxLength and policy tag on user.name, but got %+v", name)
+		}
+		if meta.Type != bigquery.JSONFieldType || meta.Schema != nil || meta.Required {
+			t.Fatalf("Expected a nullable JSON meta column without fields, but got %+v", meta)
+		}
+	})
+
+	invalid := map[string]Overrides{
+		"unknown field":        {"missing": {Type: "STRING"}},
+		"record type":          {"payload": {Type: "RECORD"}},
+		"maxLength on integer": {"created_at": {MaxLength: 10}},
+	}
+	for name, overrides := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Overrides: overrides}); err == nil {
+				t.Fatalf("Expected an error for %+v", overrides)
+			}
+		})
+	}
+
+	t.Run("record and child overrides", func(t *testing.T) {
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Overrides: Overrides{
+			"meta":        {Type: "JSON"},
+			"meta.source": {MaxLength: 10},
+		}})
+		if err != nil {
+			t.Fatalf("Expected the override of a dropped column to be skipped, but got %v", err)
+		}
+		if meta := conversion.Schema[3]; meta.Type != bigquery.JSONFieldType || meta.Schema != nil {
+			t.Fatalf("Expected a JSON meta column without fields, but got %+v", meta)
+		}
+	})
+
+	t.Run("missing column of a kept record", func(t *testing.T) {
+		s := bigquery.Schema{{Name: "meta", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{{Name: "other", Type: bigquery.StringFieldType}}}}
+		mappings := []FieldMapping{{AvroPath: "meta", ColumnPath: "meta"}, {AvroPath: "meta.source", ColumnPath: "meta.source"}}
+		err := Overrides{"meta.source": {MaxLength: 10}}.apply(s, mappings)
+		if err == nil || err.Error() != "override for unknown field meta.source" {
+			t.Fatalf("Expected an unknown field error, but got %v", err)
+		}
+	})
+
+	t.Run("pattern without match", func(t *testing.T) {
+		if _, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Overrides: Overrides{"*.missing": {Type: "STRING"}}}); err != nil {
+			t.Fatalf("Expected a pattern matching nothing to be accepted, but got %v", err)
+		}
+	})
+}
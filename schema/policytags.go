@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+var (
+	policyTagPattern = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/taxonomies/[^/]+/policyTags/[^/]+$`)
+	taxonomyPattern  = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/taxonomies/[^/]+$`)
+)
+
+// ResolvePolicyTags qualifies the policy tags of the columns of s that are
+// given by ID only, e.g. "4567", with the taxonomy resource name
+// projects/*/locations/*/taxonomies/*, and checks that every column has at
+// most one well-formed policy tag and that no RECORD column has one, as
+// BigQuery requires.
+func ResolvePolicyTags(s bigquery.Schema, taxonomy string) error {
+	taxonomy = strings.TrimSuffix(taxonomy, "/")
+	if taxonomy != "" && !taxonomyPattern.MatchString(taxonomy) {
+		return fmt.Errorf("invalid policy tag taxonomy %q, expected projects/*/locations/*/taxonomies/*", taxonomy)
+	}
+	return resolvePolicyTags("", s, taxonomy)
+}
+
+func resolvePolicyTags(prefix string, s bigquery.Schema, taxonomy string) error {
+	for _, f := range s {
+		path := joinPath(prefix, f.Name)
+		if isRecord(f) {
+			if f.PolicyTags != nil && len(f.PolicyTags.Names) > 0 {
+				return fmt.Errorf("%s: policy tags cannot be set on RECORD columns", path)
+			}
+			if err := resolvePolicyTags(path, f.Schema, taxonomy); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.PolicyTags == nil || len(f.PolicyTags.Names) == 0 {
+			continue
+		}
+		if len(f.PolicyTags.Names) > 1 {
+			return fmt.Errorf("%s: a column can have only one policy tag, got %d", path, len(f.PolicyTags.Names))
+		}
+		name := f.PolicyTags.Names[0]
+		if !strings.HasPrefix(name, "projects/") {
+			if taxonomy == "" {
+				return fmt.Errorf("%s: policy tag %q is not a resource name and no taxonomy is configured", path, name)
+			}
+			name = taxonomy + "/policyTags/" + name
+		}
+		if !policyTagPattern.MatchString(name) {
+			return fmt.Errorf("%s: invalid policy tag %q", path, name)
+		}
+		// The list may be shared with the source of an override; replace it.
+		f.PolicyTags = &bigquery.PolicyTagList{Names: []string{name}}
+	}
+	return nil
+}
+
+// LostPolicyTags reports the columns of before, typically the schema of a
+// table before an update, whose policy tags are missing or different in
+// after. Columns removed from after are not reported.
+func LostPolicyTags(before, after bigquery.Schema) []SchemaDifference {
+	var lost []SchemaDifference
+	for _, d := range DiffSchemas(before, after) {
+		if d.Kind == DiffPolicyTagMismatch {
+			lost = append(lost, d)
+		}
+	}
+	return lost
+}
+
+// policyTagString returns the policy tags of f, comma separated.
+func policyTagString(f *bigquery.FieldSchema) string {
+	if f.PolicyTags == nil {
+		return ""
+	}
+	return strings.Join(f.PolicyTags.Names, ",")
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+const testTaxonomy = "projects/p/locations/eu/taxonomies/1"
+
+func TestPolicyTags(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "User",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "id", "type": "long"},
+			map[string]interface{}{"name": "email", "type": "string", "bq.policyTags": "2"},
+			map[string]interface{}{"name": "address", "type": map[string]interface{}{
+				"type": "record",
+				"name": "Address",
+				"fields": []interface{}{
+					map[string]interface{}{"name": "street", "type": "string"},
+				},
+			}},
+			map[string]interface{}{"name": "ssn", "type": "string", "bq.policyTags": "projects/q/locations/eu/taxonomies/9/policyTags/8"},
+		},
+	}
+
+	t.Run("taxonomy", func(t *testing.T) {
+		c, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{
+			PolicyTagTaxonomy: testTaxonomy,
+			Overrides:         Overrides{"address.street": {PolicyTag: "3"}},
+		})
+		if err != nil {
+			t.Fatalf("Error converting schema: %v", err)
+		}
+		tags := map[string]string{
+			"email":          testTaxonomy + "/policyTags/2",
+			"address.street": testTaxonomy + "/policyTags/3",
+			"ssn":            "projects/q/locations/eu/taxonomies/9/policyTags/8",
+		}
+		for path, want := range tags {
+			f := lookupField(c.Schema, path)
+			if got := policyTagString(f); got != want {
+				t.Fatalf("Expected policy tag %s on %s, but got %q", want, path, got)
+			}
+		}
+		if c.Schema[0].PolicyTags != nil {
+			t.Fatalf("Expected no policy tag on id, but got %+v", c.Schema[0].PolicyTags)
+		}
+	})
+
+	t.Run("no taxonomy", func(t *testing.T) {
+		if _, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{}); err == nil {
+			t.Fatalf("Expected an error for a policy tag ID without taxonomy")
+		}
+	})
+
+	invalid := map[string]ConvertOptions{
+		"invalid taxonomy": {PolicyTagTaxonomy: "projects/p/taxonomies/1"},
+		"record":           {PolicyTagTaxonomy: testTaxonomy, Overrides: Overrides{"address": {PolicyTag: "3"}}},
+		"invalid tag":      {PolicyTagTaxonomy: testTaxonomy, Overrides: Overrides{"id": {PolicyTag: "projects/p/policyTags/3"}}},
+	}
+	for name, opts := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ConvertAvroToBigQueryWithOptions(avroSchema, opts); err == nil {
+				t.Fatalf("Expected an error")
+			}
+		})
+	}
+
+	t.Run("several tags", func(t *testing.T) {
+		s := bigquery.Schema{{Name: "a", Type: bigquery.StringFieldType, PolicyTags: &bigquery.PolicyTagList{Names: []string{"1", "2"}}}}
+		if err := ResolvePolicyTags(s, testTaxonomy); err == nil {
+			t.Fatalf("Expected an error for two policy tags on one column")
+		}
+	})
+}
+
+func TestLostPolicyTags(t *testing.T) {
+	tag := func(id string) *bigquery.PolicyTagList {
+		return &bigquery.PolicyTagList{Names: []string{testTaxonomy + "/policyTags/" + id}}
+	}
+	before := bigquery.Schema{
+		{Name: "email", Type: bigquery.StringFieldType, PolicyTags: tag("2")},
+		{Name: "phone", Type: bigquery.StringFieldType, PolicyTags: tag("3")},
+		{Name: "gone", Type: bigquery.StringFieldType, PolicyTags: tag("4")},
+		{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "street", Type: bigquery.StringFieldType, PolicyTags: tag("5")},
+		}},
+		{Name: "id", Type: bigquery.IntegerFieldType},
+	}
+	after := bigquery.Schema{
+		{Name: "email", Type: bigquery.StringFieldType, PolicyTags: tag("2")},
+		{Name: "phone", Type: bigquery.StringFieldType, PolicyTags: tag("6")},
+		{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "street", Type: bigquery.StringFieldType},
+		}},
+		{Name: "id", Type: bigquery.IntegerFieldType, PolicyTags: tag("7")},
+	}
+
+	lost := LostPolicyTags(before, after)
+	if len(lost) != 2 {
+		t.Fatalf("Expected 2 lost policy tags, but got %v", lost)
+	}
+	if lost[0].Path != "phone" || lost[0].Actual != testTaxonomy+"/policyTags/6" {
+		t.Fatalf("Expected the changed tag of phone, but got %v", lost[0])
+	}
+	if lost[1].Path != "address.street" || lost[1].Kind != DiffPolicyTagMismatch || lost[1].Actual != "" {
+		t.Fatalf("Expected the lost tag of address.street, but got %v", lost[1])
+	}
+}
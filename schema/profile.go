@@ -0,0 +1,173 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// Names of the built-in mapping profiles.
+const (
+	// ProfileBQLoadLogicalTypes matches a BigQuery load job of Avro files
+	// with use_avro_logical_types enabled: logical types map to DATE, TIME,
+	// TIMESTAMP and DATETIME, uuid to STRING, a string with logical type
+	// datetime to DATETIME, and decimals to NUMERIC, or to STRING when they
+	// do not fit, as with the default decimal target types.
+	ProfileBQLoadLogicalTypes = "bq-load-logical-types"
+	// ProfileBQLoadLegacy matches a BigQuery load job of Avro files without
+	// use_avro_logical_types: logical types are ignored.
+	ProfileBQLoadLegacy = "bq-load-legacy"
+	// ProfileKafkaConnect matches the Kafka Connect BigQuery sink fed by
+	// the Avro converter: date, time-millis and timestamp-millis map to
+	// DATE, TIME and TIMESTAMP, decimals to FLOAT, and the microsecond and
+	// local timestamp logical types, which Kafka Connect lacks, to INTEGER.
+	ProfileKafkaConnect = "kafka-connect"
+	// ProfilePubSubSubscription matches a Pub/Sub BigQuery subscription
+	// writing with the topic schema: logical types map to DATE, TIME,
+	// TIMESTAMP and DATETIME, and decimals to NUMERIC or BIGNUMERIC. Unions
+	// of several non-null types are rejected.
+	ProfilePubSubSubscription = "pubsub-subscription"
+)
+
+// Profile reproduces how another tool maps Avro schemas to BigQuery. All
+// built-in profiles map arrays to REPEATED columns of the element type,
+// maps to a REPEATED RECORD of key and value, enums to STRING, and do not
+// set default values.
+type Profile struct {
+	Name       string
+	TypeMapper TypeMapper
+	// NativeArrays maps an array of non-record elements to a REPEATED
+	// column of the element type instead of a REPEATED RECORD holding a
+	// single column.
+	NativeArrays bool
+	// UnionRecords maps a union of several non-null types to a RECORD with
+	// one nullable field per type, named after the type. Otherwise such
+	// unions are rejected.
+	UnionRecords bool
+}
+
+var profiles = map[string]Profile{
+	ProfileBQLoadLogicalTypes: {
+		TypeMapper:   TypeMapperFunc(bqLoadMapType),
+		NativeArrays: true,
+		UnionRecords: true,
+	},
+	ProfileBQLoadLegacy: {
+		TypeMapper:   TypeMapperFunc(rawMapType),
+		NativeArrays: true,
+		UnionRecords: true,
+	},
+	ProfileKafkaConnect: {
+		TypeMapper:   TypeMapperFunc(kafkaConnectMapType),
+		NativeArrays: true,
+		UnionRecords: true,
+	},
+	ProfilePubSubSubscription: {
+		TypeMapper:   TypeMapperFunc(pubSubMapType),
+		NativeArrays: true,
+	},
+}
+
+// ProfileByName returns the built-in profile with the given name.
+func ProfileByName(name string) (*Profile, error) {
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mapping profile %q", name)
+	}
+	p.Name = name
+	return &p, nil
+}
+
+// ProfileNames returns the names of the built-in profiles, sorted.
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rawMapType maps Avro types without considering logical types.
+func rawMapType(t AvroType) (BigQueryType, error) {
+	switch t.Type {
+	case "boolean":
+		return BigQueryType{Type: bigquery.BooleanFieldType}, nil
+	case "int", "long":
+		return BigQueryType{Type: bigquery.IntegerFieldType}, nil
+	case "float", "double":
+		return BigQueryType{Type: bigquery.FloatFieldType}, nil
+	case "bytes", "fixed":
+		return BigQueryType{Type: bigquery.BytesFieldType}, nil
+	case "record", "error", "array", "map":
+		return BigQueryType{Type: bigquery.RecordFieldType}, nil
+	}
+	// null, string, enum and types referenced by name.
+	return BigQueryType{Type: bigquery.StringFieldType}, nil
+}
+
+func bqLoadMapType(t AvroType) (BigQueryType, error) {
+	switch t.LogicalType {
+	case "decimal":
+		if t.Type == "bytes" || t.Type == "fixed" {
+			d, err := decimalType(t)
+			if err != nil || d.Type != bigquery.NumericFieldType {
+				return BigQueryType{Type: bigquery.StringFieldType}, nil
+			}
+			return BigQueryType{Type: bigquery.NumericFieldType}, nil
+		}
+	case "datetime":
+		if t.Type == "string" {
+			return BigQueryType{Type: bigquery.DateTimeFieldType}, nil
+		}
+	}
+	if mapped, ok := temporalType(t); ok {
+		return mapped, nil
+	}
+	return rawMapType(t)
+}
+
+func kafkaConnectMapType(t AvroType) (BigQueryType, error) {
+	switch {
+	case t.LogicalType == "decimal" && (t.Type == "bytes" || t.Type == "fixed"):
+		return BigQueryType{Type: bigquery.FloatFieldType}, nil
+	case t.LogicalType == "date" && t.Type == "int":
+		return BigQueryType{Type: bigquery.DateFieldType}, nil
+	case t.LogicalType == "time-millis" && t.Type == "int":
+		return BigQueryType{Type: bigquery.TimeFieldType}, nil
+	case t.LogicalType == "timestamp-millis" && t.Type == "long":
+		return BigQueryType{Type: bigquery.TimestampFieldType}, nil
+	}
+	return rawMapType(t)
+}
+
+func pubSubMapType(t AvroType) (BigQueryType, error) {
+	if t.LogicalType == "decimal" && (t.Type == "bytes" || t.Type == "fixed") {
+		d, err := decimalType(t)
+		if err != nil {
+			return BigQueryType{}, err
+		}
+		return BigQueryType{Type: d.Type}, nil
+	}
+	if mapped, ok := temporalType(t); ok {
+		return mapped, nil
+	}
+	return rawMapType(t)
+}
+
+// temporalType maps the date, time and timestamp logical types on their
+// specified underlying types.
+func temporalType(t AvroType) (BigQueryType, bool) {
+	switch {
+	case t.Type == "int" && t.LogicalType == "date":
+		return BigQueryType{Type: bigquery.DateFieldType}, true
+	case t.Type == "int" && t.LogicalType == "time-millis", t.Type == "long" && t.LogicalType == "time-micros":
+		return BigQueryType{Type: bigquery.TimeFieldType}, true
+	case t.Type == "long" && (t.LogicalType == "timestamp-millis" || t.LogicalType == "timestamp-micros"):
+		return BigQueryType{Type: bigquery.TimestampFieldType}, true
+	case t.Type == "long" && (t.LogicalType == "local-timestamp-millis" || t.LogicalType == "local-timestamp-micros"):
+		return BigQueryType{Type: bigquery.DateTimeFieldType}, true
+	}
+	return BigQueryType{}, false
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestConvertAvroToBigQueryWithProfiles(t *testing.T) {
+	logical := func(typ, logicalType string) map[string]interface{} {
+		return map[string]interface{}{"type": typ, "logicalType": logicalType}
+	}
+	field := func(name string, typ interface{}) map[string]interface{} {
+		return map[string]interface{}{"name": name, "type": typ, "default": "x"}
+	}
+	decimal := map[string]interface{}{"type": "bytes", "logicalType": "decimal", "precision": float64(40), "scale": float64(2)}
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Event",
+		"fields": []interface{}{
+			field("day", logical("int", "date")),
+			field("at_millis", logical("long", "timestamp-millis")),
+			field("at_micros", logical("long", "timestamp-micros")),
+			field("local", logical("long", "local-timestamp-micros")),
+			field("amount", decimal),
+			field("tags", map[string]interface{}{"type": "array", "items": "string", "name": "tag"}),
+			field("attributes", map[string]interface{}{"type": "map", "values": []interface{}{"null", "long"}}),
+			field("status", map[string]interface{}{"type": "enum", "name": "Status", "symbols": []interface{}{"A", "B"}}),
+		},
+	}
+	convert := func(t *testing.T, name string) bigquery.Schema {
+		t.Helper()
+		profile, err := ProfileByName(name)
+		if err != nil {
+			t.Fatalf("Error loading profile: %v", err)
+		}
+		conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Profile: profile})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		return conversion.Schema
+	}
+
+	want := map[string][]bigquery.FieldType{
+		ProfileBQLoadLogicalTypes: {"DATE", "TIMESTAMP", "TIMESTAMP", "DATETIME", "STRING"},
+		ProfileBQLoadLegacy:       {"INTEGER", "INTEGER", "INTEGER", "INTEGER", "BYTES"},
+		ProfileKafkaConnect:       {"DATE", "TIMESTAMP", "INTEGER", "INTEGER", "FLOAT"},
+		ProfilePubSubSubscription: {"DATE", "TIMESTAMP", "TIMESTAMP", "DATETIME", "BIGNUMERIC"},
+	}
+	for name, types := range want {
+		t.Run(name, func(t *testing.T) {
+			s := convert(t, name)
+			for i, typ := range types {
+				if s[i].Type != typ || s[i].Precision != 0 {
+					t.Fatalf("Expected %s to be %s, but got %s", s[i].Name, typ, FieldTypeString(s[i]))
+				}
+			}
+			for _, f := range s {
+				if f.DefaultValueExpression != "" {
+					t.Fatalf("Expected no default value on %s, but got %q", f.Name, f.DefaultValueExpression)
+				}
+			}
+			if tags := s[5]; tags.Type != bigquery.StringFieldType || !tags.Repeated || tags.Schema != nil {
+				t.Fatalf("Expected a REPEATED STRING column, but got %+v", tags)
+			}
+			attributes := s[6]
+			if !attributes.Repeated || len(attributes.Schema) != 2 || !attributes.Schema[0].Required ||
+				attributes.Schema[1].Required || attributes.Schema[1].Type != bigquery.IntegerFieldType {
+				t.Fatalf("Expected a REPEATED RECORD of key and nullable value, but got %+v", attributes.Schema)
+			}
+			if s[7].Type != bigquery.StringFieldType {
+				t.Fatalf("Expected a STRING enum, but got %s", s[7].Type)
+			}
+		})
+	}
+
+	t.Run("unions", func(t *testing.T) {
+		unionSchema := map[string]interface{}{
+			"type": "record",
+			"name": "U",
+			"fields": []interface{}{
+				field("value", []interface{}{"null", "long", "string", map[string]interface{}{
+					"type": "record", "name": "com.example.Point", "fields": []interface{}{field("x", "double")},
+				}}),
+			},
+		}
+		profile, _ := ProfileByName(ProfileBQLoadLogicalTypes)
+		conversion, err := ConvertAvroToBigQueryWithOptions(unionSchema, ConvertOptions{Profile: profile})
+		if err != nil {
+			t.Fatalf("Error converting Avro schema: %v", err)
+		}
+		value := conversion.Schema[0]
+		if value.Type != bigquery.RecordFieldType || value.Required || len(value.Schema) != 3 {
+			t.Fatalf("Expected a nullable RECORD with 3 fields, but got %+v", value)
+		}
+		if names := fieldNames(value.Schema); names[0] != "long" || names[1] != "string" || names[2] != "Point" {
+			t.Fatalf("Expected fields long, string and Point, but got %v", names)
+		}
+
+		profile, _ = ProfileByName(ProfilePubSubSubscription)
+		expected := "value: the pubsub-subscription profile rejects unions with several non-null types"
+		if _, err := ConvertAvroToBigQueryWithOptions(unionSchema, ConvertOptions{Profile: profile}); err == nil || err.Error() != expected {
+			t.Fatalf("Expected error %q, but got %v", expected, err)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := ProfileByName("spark"); err == nil {
+			t.Fatalf("Expected an error for an unknown profile")
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InlineReferences returns a copy of avroSchema that stands alone: the
+// first reference to each named type defined in types, keyed by full
+// name, is replaced with a copy of its definition, whose own references
+// are inlined in turn. Later references stay names, since Avro allows a
+// type to be defined only once; the converter expands every reference
+// like the definition it names. Types that avroSchema defines itself are
+// never replaced. Inlined definitions are named by their full name, so
+// they keep their namespace wherever they land.
+//
+// It fails on references to types that are neither defined in avroSchema
+// nor in types, and on types defined twice.
+func InlineReferences(avroSchema map[string]interface{}, types map[string]map[string]interface{}) (map[string]interface{}, error) {
+	in := &inliner{types: types, defined: make(map[string]bool)}
+	if err := in.collect(avroSchema, ""); err != nil {
+		return nil, err
+	}
+	out, err := in.inline(avroSchema, "", false)
+	if err != nil {
+		return nil, err
+	}
+	return out.(map[string]interface{}), nil
+}
+
+// inliner replaces references to external named types with their
+// definitions.
+type inliner struct {
+	types map[string]map[string]interface{}
+	// defined holds the full names of the types defined so far: those of
+	// the root schema and those inlined.
+	defined map[string]bool
+}
+
+// definedName returns the full name of the named type t defined in
+// namespace ns.
+func definedName(t map[string]interface{}, ns string) string {
+	name, _ := t["name"].(string)
+	if explicit, ok := t["namespace"].(string); ok {
+		ns = explicit
+	}
+	return fullName(name, ns)
+}
+
+// collect registers the named types defined in t.
+func (in *inliner) collect(t interface{}, ns string) error {
+	switch t := t.(type) {
+	case []interface{}:
+		for _, branch := range t {
+			if err := in.collect(branch, ns); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		typ, ok := t["type"].(string)
+		if !ok {
+			return in.collect(t["type"], ns)
+		}
+		switch typ {
+		case "record", "error", "enum", "fixed":
+			if _, ok := t["name"].(string); !ok {
+				return fmt.Errorf("%s without a name", typ)
+			}
+			full := definedName(t, ns)
+			if in.defined[full] {
+				return fmt.Errorf("%s is defined twice", full)
+			}
+			in.defined[full] = true
+			ns = namespaceOf(full)
+		}
+		switch typ {
+		case "record", "error":
+			fields, _ := t["fields"].([]interface{})
+			for _, f := range fields {
+				field, _ := f.(map[string]interface{})
+				if field["type"] == "record" {
+					// A record declared directly on the field.
+					if err := in.collect(field, ns); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := in.collect(field["type"], ns); err != nil {
+					return err
+				}
+			}
+		case "array":
+			return in.collect(t["items"], ns)
+		case "map":
+			return in.collect(t["values"], ns)
+		}
+	}
+	return nil
+}
+
+// inline returns a copy of t, defined in namespace ns, with references
+// inlined. Within an inlined definition, inlined is set and the named
+// types it defines are registered.
+func (in *inliner) inline(t interface{}, ns string, inlined bool) (interface{}, error) {
+	switch t := t.(type) {
+	case string:
+		if primitiveTypes[t] {
+			return t, nil
+		}
+		full := fullName(t, ns)
+		if in.defined[full] || in.defined[t] {
+			return t, nil
+		}
+		def, ok := in.types[full]
+		if !ok {
+			if def, ok = in.types[t]; !ok {
+				return nil, fmt.Errorf("unknown type %s", t)
+			}
+			full = t
+		}
+		if def["type"] != "record" && def["type"] != "error" && def["type"] != "enum" && def["type"] != "fixed" {
+			return nil, fmt.Errorf("%s is not a named type", full)
+		}
+		c := copyMap(def)
+		c["name"] = full
+		delete(c, "namespace")
+		return in.inline(c, ns, true)
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, branch := range t {
+			var err error
+			if out[i], err = in.inline(branch, ns, inlined); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case map[string]interface{}:
+		typ, ok := t["type"].(string)
+		if !ok {
+			nested, err := in.inline(t["type"], ns, inlined)
+			if err != nil {
+				return nil, err
+			}
+			out := copyMap(t)
+			out["type"] = nested
+			return out, nil
+		}
+		out := copyMap(t)
+		switch typ {
+		case "record", "error", "enum", "fixed":
+			if _, ok := t["name"].(string); !ok {
+				return nil, fmt.Errorf("%s without a name", typ)
+			}
+			full := definedName(t, ns)
+			if inlined {
+				if in.defined[full] {
+					return nil, fmt.Errorf("%s is defined twice", full)
+				}
+				in.defined[full] = true
+			}
+			ns = namespaceOf(full)
+		}
+		switch typ {
+		case "record", "error":
+			fields, ok := t["fields"].([]interface{})
+			if !ok {
+				return nil, errors.New("invalid avro record fields")
+			}
+			outFields := make([]interface{}, len(fields))
+			for i, f := range fields {
+				field, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, errors.New("invalid avro record field")
+				}
+				var err error
+				if field["type"] == "record" {
+					// A record declared directly on the field.
+					outFields[i], err = in.inline(field, ns, inlined)
+				} else {
+					outField := copyMap(field)
+					outField["type"], err = in.inline(field["type"], ns, inlined)
+					outFields[i] = outField
+				}
+				if err != nil {
+					name, _ := field["name"].(string)
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			out["fields"] = outFields
+		case "array":
+			items, err := in.inline(t["items"], ns, inlined)
+			if err != nil {
+				return nil, err
+			}
+			out["items"] = items
+		case "map":
+			values, err := in.inline(t["values"], ns, inlined)
+			if err != nil {
+				return nil, err
+			}
+			out["values"] = values
+		case "enum", "fixed":
+		default:
+			if !primitiveTypes[typ] {
+				// A reference wrapped in an object, e.g. {"type": "Address"}.
+				nested, err := in.inline(typ, ns, inlined)
+				if err != nil {
+					return nil, err
+				}
+				out["type"] = nested
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("invalid avro type %v", t)
+}
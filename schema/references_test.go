@@ -0,0 +1,143 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func parseSchema(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatalf("Error parsing schema: %v", err)
+	}
+	return m
+}
+
+func TestInlineReferences(t *testing.T) {
+	order := parseSchema(t, `{"type": "record", "name": "Order", "namespace": "com.shop", "fields": [
+		{"name": "customer", "type": "com.crm.Customer"},
+		{"name": "shipping", "type": ["null", "com.common.Address"]},
+		{"name": "total", "type": {"type": "com.common.Money"}},
+		{"name": "lines", "type": {"type": "array", "items": {"type": "record", "name": "Line", "fields": [{"name": "price", "type": "com.common.Money"}]}}}
+	]}`)
+	types := map[string]map[string]interface{}{
+		"com.crm.Customer":    parseSchema(t, `{"type": "record", "name": "Customer", "namespace": "com.crm", "fields": [{"name": "home", "type": "com.common.Address"}]}`),
+		"com.common.Address":  parseSchema(t, `{"type": "record", "name": "Address", "namespace": "com.common", "fields": [{"name": "city", "type": "string"}]}`),
+		"com.common.Money":    parseSchema(t, `{"type": "record", "name": "Money", "namespace": "com.common", "fields": [{"name": "units", "type": "long"}, {"name": "currency", "type": "Currency"}]}`),
+		"com.common.Currency": parseSchema(t, `{"type": "enum", "name": "Currency", "namespace": "com.common", "symbols": ["EUR", "USD"]}`),
+	}
+
+	inlined, err := InlineReferences(order, types)
+	if err != nil {
+		t.Fatalf("Error inlining references: %v", err)
+	}
+	fields := inlined["fields"].([]interface{})
+	customer := fields[0].(map[string]interface{})["type"].(map[string]interface{})
+	if customer["name"] != "com.crm.Customer" || customer["namespace"] != nil {
+		t.Fatalf("Expected the Customer definition named by its full name, but got %v", customer)
+	}
+	home := customer["fields"].([]interface{})[0].(map[string]interface{})["type"].(map[string]interface{})
+	if home["name"] != "com.common.Address" {
+		t.Fatalf("Expected Address to be defined at its first use, but got %v", home)
+	}
+	shipping := fields[1].(map[string]interface{})["type"]
+	if !reflect.DeepEqual(shipping, []interface{}{"null", "com.common.Address"}) {
+		t.Fatalf("Expected the second Address reference to stay a name, but got %v", shipping)
+	}
+	if order["fields"].([]interface{})[0].(map[string]interface{})["type"] != "com.crm.Customer" {
+		t.Fatalf("Expected the input schema to be left unchanged")
+	}
+	if _, err := CanonicalForm(inlined); err != nil {
+		t.Fatalf("Expected a valid schema, got %v", err)
+	}
+
+	s, err := ConvertAvroToBigQuery(inlined)
+	if err != nil {
+		t.Fatalf("Error converting schema: %v", err)
+	}
+	if s[0].Type != bigquery.RecordFieldType || s[0].Schema[0].Type != bigquery.RecordFieldType {
+		t.Fatalf("Expected customer and its address to be records, but got %v", s[0])
+	}
+	if s[1].Type != bigquery.RecordFieldType || !reflect.DeepEqual(s[1].Schema, s[0].Schema[0].Schema) {
+		t.Fatalf("Expected shipping to be an address record like the customer's, but got %v", s[1])
+	}
+	if s[2].Type != bigquery.RecordFieldType || s[2].Schema[1].Type != bigquery.StringFieldType {
+		t.Fatalf("Expected total to be a record with a currency enum, but got %v", s[2])
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := InlineReferences(order, map[string]map[string]interface{}{}); err == nil {
+			t.Fatalf("Expected an error for an unknown type")
+		}
+		dup := parseSchema(t, `{"type": "record", "name": "R", "fields": [
+			{"name": "a", "type": "Ext"},
+			{"name": "b", "type": {"type": "record", "name": "Inner", "fields": []}}
+		]}`)
+		ext := map[string]map[string]interface{}{
+			"Ext": parseSchema(t, `{"type": "record", "name": "Ext", "fields": [{"name": "i", "type": {"type": "record", "name": "Inner", "fields": []}}]}`),
+		}
+		if _, err := InlineReferences(dup, ext); err == nil {
+			t.Fatalf("Expected an error for a type defined twice")
+		}
+	})
+}
+
+func TestConvertAvroToBigQueryWithReferences(t *testing.T) {
+	order := parseSchema(t, `{"type": "record", "name": "Order", "namespace": "com.shop", "fields": [
+		{"name": "billing", "type": {"type": "record", "name": "Address", "namespace": "com.common", "fields": [
+			{"name": "city", "type": "string"},
+			{"name": "zip_code", "type": ["null", "string"], "bq.maxLength": 5}
+		]}},
+		{"name": "shipping", "type": ["null", "com.common.Address"]},
+		{"name": "lines", "type": {"type": "array", "items": {"type": "record", "name": "Line", "fields": [
+			{"name": "sku", "type": "string"}
+		]}}},
+		{"name": "returns", "type": {"type": "array", "items": "Line"}}
+	]}`)
+
+	conversion, err := ConvertAvroToBigQueryWithOptions(order, ConvertOptions{
+		Naming:    NamingLowerCamelCase,
+		Overrides: Overrides{"shipping.city": {Description: "Delivery city."}},
+	})
+	if err != nil {
+		t.Fatalf("Error converting schema: %v", err)
+	}
+	billing, shipping, returns := conversion.Schema[0], conversion.Schema[1], conversion.Schema[3]
+	if shipping.Type != bigquery.RecordFieldType || shipping.Required || len(shipping.Schema) != 2 {
+		t.Fatalf("Expected a nullable address record, but got %+v", shipping)
+	}
+	if zip := shipping.Schema[1]; zip.Name != "zipCode" || zip.MaxLength != 5 {
+		t.Fatalf("Expected the renamed and annotated zipCode column, but got %+v", zip)
+	}
+	if shipping.Schema[0].Description != "Delivery city." || billing.Schema[0].Description != "" {
+		t.Fatalf("Expected the override to apply to the shipping address only, but got %+v and %+v", shipping.Schema[0], billing.Schema[0])
+	}
+	if returns.Type != bigquery.RecordFieldType || !returns.Repeated || returns.Schema[0].Name != "sku" {
+		t.Fatalf("Expected repeated line records, but got %+v", returns)
+	}
+	if m := conversion.Mapping(); m["shipping.zip_code"] != "shipping.zipCode" || m["returns.sku"] != "returns.sku" {
+		t.Fatalf("Expected the fields of references to be mapped, but got %v", m)
+	}
+
+	normalized, err := ConvertAvroToBigQueryWithOptions(order, ConvertOptions{Normalize: &NormalizeOptions{RootTable: "orders"}})
+	if err != nil {
+		t.Fatalf("Error normalizing schema: %v", err)
+	}
+	if len(normalized.Tables) != 3 {
+		t.Fatalf("Expected the arrays of declared and referenced lines in child tables, but got %d tables", len(normalized.Tables))
+	}
+
+	t.Run("recursive", func(t *testing.T) {
+		node := parseSchema(t, `{"type": "record", "name": "Node", "fields": [
+			{"name": "value", "type": "long"},
+			{"name": "next", "type": ["null", "Node"]}
+		]}`)
+		if _, err := ConvertAvroToBigQuery(node); err == nil || err.Error() != "next: recursive reference to Node is not supported" {
+			t.Fatalf("Expected a recursive reference error, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package schema
+
+import "fmt"
+
+// fieldRenamer rewrites the field names of an Avro schema before it is
+// converted and records the resulting mapping from Avro field paths to
+// BigQuery column paths. The input schema is never modified; every map on
+// the path to a renamed field is copied.
+type fieldRenamer struct {
+	naming   NamingStrategy
+	sanitize *SanitizeOptions
+	mappings []FieldMapping
+	// recordArrays holds the column paths of fields that are arrays of
+	// records, as opposed to arrays of primitives.
+	recordArrays map[string]bool
+	// enums holds the enum-typed fields by column path.
+	enums map[string]*enumField
+	// annotations holds the bq.* properties of fields by column path.
+	annotations map[string]map[string]interface{}
+	// fields holds the Avro fields by Avro path.
+	fields map[string]map[string]interface{}
+	// namedEnums holds the enums declared so far by name and full name, to
+	// resolve references to them.
+	namedEnums map[string]map[string]interface{}
+	// namedRecords holds the records declared so far by name and full
+	// name, so that the fields of every record reference are mapped.
+	namedRecords map[string]map[string]interface{}
+	// open holds the full names of the records being renamed, whose
+	// recursive references are left to the converter to reject.
+	open map[string]bool
+	// err is the first error met while renaming.
+	err error
+}
+
+func newFieldRenamer(opts ConvertOptions) *fieldRenamer {
+	r := &fieldRenamer{
+		naming:       opts.Naming,
+		recordArrays: make(map[string]bool),
+		enums:        make(map[string]*enumField),
+		annotations:  make(map[string]map[string]interface{}),
+		fields:       make(map[string]map[string]interface{}),
+		namedEnums:   make(map[string]map[string]interface{}),
+		namedRecords: make(map[string]map[string]interface{}),
+		open:         make(map[string]bool),
+	}
+	if r.naming == nil {
+		r.naming = NamingAsIs
+	}
+	if opts.Sanitize != nil {
+		sanitize := opts.Sanitize.withDefaults()
+		r.sanitize = &sanitize
+	}
+	return r
+}
+
+// columnName returns the BigQuery column name for an Avro field name.
+func (r *fieldRenamer) columnName(name string) string {
+	name = r.naming.ColumnName(name)
+	if r.sanitize != nil {
+		name = r.sanitize.sanitizeName(name)
+	}
+	return name
+}
+
+// renameRecord returns a copy of the record schema with its fields renamed.
+// Schemas without a "fields" list are returned unchanged.
+func (r *fieldRenamer) renameRecord(record map[string]interface{}, avroPrefix, bqPrefix string) map[string]interface{} {
+	fields, ok := record["fields"].([]interface{})
+	if !ok {
+		return record
+	}
+	if name, ok := record["name"].(string); ok {
+		full := SchemaFullName(record)
+		r.namedRecords[name] = record
+		r.namedRecords[full] = record
+		r.open[full] = true
+		defer delete(r.open, full)
+	}
+	out := copyMap(record)
+	out["fields"] = r.renameFields(fields, avroPrefix, bqPrefix)
+	return out
+}
+
+func (r *fieldRenamer) renameFields(fields []interface{}, avroPrefix, bqPrefix string) []interface{} {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		if field, ok := f.(map[string]interface{}); ok {
+			if name, ok := field["name"].(string); ok {
+				names[i] = r.columnName(name)
+			}
+		}
+	}
+	if r.sanitize != nil {
+		deduped, err := r.sanitize.dedupe(names)
+		if err != nil {
+			if r.err == nil && bqPrefix != "" {
+				err = fmt.Errorf("%s: %w", bqPrefix, err)
+			}
+			if r.err == nil {
+				r.err = err
+			}
+			return fields
+		}
+		names = deduped
+	}
+
+	out := make([]interface{}, len(fields))
+	for i, f := range fields {
+		field, ok := f.(map[string]interface{})
+		if !ok {
+			out[i] = f
+			continue
+		}
+		name, ok := field["name"].(string)
+		if !ok {
+			out[i] = f
+			continue
+		}
+
+		aliases := stringList(field["aliases"])
+		avroPath, bqPath := joinPath(avroPrefix, name), joinPath(bqPrefix, names[i])
+		r.mappings = append(r.mappings, FieldMapping{AvroPath: avroPath, ColumnPath: bqPath, Aliases: aliases})
+		r.fields[avroPath] = field
+		if r.isRecordArrayType(field["type"]) {
+			r.recordArrays[bqPath] = true
+		}
+
+		renamed := copyMap(field)
+		renamed["name"] = names[i]
+		if names[i] != name && !containsString(aliases, name) {
+			// Keep the original name resolvable for Avro readers.
+			renamedAliases := make([]interface{}, 0, len(aliases)+1)
+			for _, alias := range aliases {
+				renamedAliases = append(renamedAliases, alias)
+			}
+			renamed["aliases"] = append(renamedAliases, name)
+		}
+		if renamed["type"] == "record" {
+			// A record declared directly on the field.
+			renamed = r.renameRecord(renamed, avroPath, bqPath)
+		} else {
+			renamed["type"] = r.renameType(field["type"], avroPath, bqPath)
+		}
+		if enum := r.enumOf(field["type"]); enum != nil {
+			r.enums[bqPath] = newEnumField(enum, field)
+		}
+		if props := fieldAnnotations(field); len(props) > 0 {
+			r.annotations[bqPath] = props
+		}
+		out[i] = renamed
+	}
+	return out
+}
+
+// renameType renames the fields of any records nested in the Avro type t.
+func (r *fieldRenamer) renameType(t interface{}, avroPath, bqPath string) interface{} {
+	switch t := t.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, branch := range t {
+			out[i] = r.renameType(branch, avroPath, bqPath)
+		}
+		return out
+	case map[string]interface{}:
+		switch t["type"] {
+		case "record":
+			return r.renameRecord(t, avroPath, bqPath)
+		case "array":
+			out := copyMap(t)
+			out["items"] = r.renameType(t["items"], avroPath, bqPath)
+			if name, ok := t["name"].(string); ok {
+				// The element column of an array of primitives.
+				out["name"] = r.columnName(name)
+			}
+			return out
+		case "map":
+			out := copyMap(t)
+			out["values"] = r.renameType(t["values"], avroPath, bqPath)
+			return out
+		case "enum":
+			if name, ok := t["name"].(string); ok {
+				r.namedEnums[name] = t
+				r.namedEnums[SchemaFullName(t)] = t
+			}
+		}
+	case string:
+		if record := r.namedRecords[t]; record != nil && !r.open[SchemaFullName(record)] {
+			// A reference converts like the record it names, so its
+			// fields are mapped under this field too. The reference
+			// itself is kept, as Avro defines a type only once.
+			r.renameRecord(record, avroPath, bqPath)
+		}
+	}
+	return t
+}
+
+// enumOf returns the enum declared or referenced by the Avro type t, by
+// the only non-null branch of the union t, or by the items of the array t,
+// or nil.
+func (r *fieldRenamer) enumOf(t interface{}) map[string]interface{} {
+	switch t := t.(type) {
+	case []interface{}:
+		var branch interface{}
+		for _, b := range t {
+			if b == "null" {
+				continue
+			}
+			if branch != nil {
+				return nil
+			}
+			branch = b
+		}
+		return r.enumOf(branch)
+	case map[string]interface{}:
+		switch t["type"] {
+		case "enum":
+			return t
+		case "array":
+			if items, ok := t["items"].(map[string]interface{}); ok && items["type"] == "array" {
+				return nil
+			}
+			return r.enumOf(t["items"])
+		}
+	case string:
+		return r.namedEnums[t]
+	}
+	return nil
+}
+
+// isRecordArrayType reports whether the Avro type t, or a branch of the
+// union t, is an array of records, declared or referenced.
+func (r *fieldRenamer) isRecordArrayType(t interface{}) bool {
+	switch t := t.(type) {
+	case []interface{}:
+		for _, branch := range t {
+			if r.isRecordArrayType(branch) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		if t["type"] == "array" {
+			switch items := t["items"].(type) {
+			case map[string]interface{}:
+				return items["type"] == "record"
+			case string:
+				return r.namedRecords[items] != nil
+			}
+		}
+	}
+	return false
+}
+
+// stringList returns the strings of a JSON array, ignoring other values.
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var out []string
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
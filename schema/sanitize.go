@@ -0,0 +1,130 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SanitizeOptions configures the field name sanitization stage of
+// ConvertAvroToBigQueryWithOptions. Empty values select the defaults
+// documented on each field.
+type SanitizeOptions struct {
+	// Replacement replaces every run of characters that are not letters,
+	// digits or underscores. Defaults to "_".
+	Replacement string
+	// DigitPrefix is prepended to names starting with a digit. Defaults to
+	// "_".
+	DigitPrefix string
+	// ReservedPrefix is prepended to names starting with a prefix BigQuery
+	// reserves, such as _PARTITION or _TABLE_. Defaults to "f".
+	ReservedPrefix string
+	// MaxLength truncates longer names. Defaults to MaxColumnNameLength.
+	MaxLength int
+}
+
+func (o SanitizeOptions) withDefaults() SanitizeOptions {
+	if o.Replacement == "" || !isValidColumnName("a"+o.Replacement) {
+		o.Replacement = "_"
+	}
+	if o.DigitPrefix == "" || !isValidColumnName(o.DigitPrefix) {
+		o.DigitPrefix = "_"
+	}
+	if o.ReservedPrefix == "" || !isValidColumnName(o.ReservedPrefix) {
+		o.ReservedPrefix = "f"
+	}
+	if o.MaxLength <= 0 || o.MaxLength > MaxColumnNameLength {
+		o.MaxLength = MaxColumnNameLength
+	}
+	return o
+}
+
+// SanitizeName rewrites name into a valid BigQuery column name using the
+// options in o.
+func (o SanitizeOptions) SanitizeName(name string) string {
+	o = o.withDefaults()
+	return o.sanitizeName(name)
+}
+
+// sanitizeName expects o to have its defaults applied.
+func (o SanitizeOptions) sanitizeName(name string) string {
+	var b strings.Builder
+	invalidRun := false
+	for _, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			invalidRun = false
+			continue
+		}
+		if !invalidRun {
+			b.WriteString(o.Replacement)
+		}
+		invalidRun = true
+	}
+	s := b.String()
+
+	switch {
+	case s == "":
+		s = o.DigitPrefix
+	case s[0] >= '0' && s[0] <= '9':
+		s = o.DigitPrefix + s
+	}
+	if isReservedName(s) {
+		s = o.ReservedPrefix + s
+	}
+	if len(s) > o.MaxLength {
+		s = s[:o.MaxLength]
+	}
+	return s
+}
+
+// dedupe resolves case-insensitive collisions between sibling names. The
+// first occurrence of a name keeps it; later occurrences get the smallest
+// numeric suffix ("_2", "_3", ...) that collides with no other name. It
+// fails when MaxLength leaves no room for a unique name.
+func (o SanitizeOptions) dedupe(names []string) ([]string, error) {
+	used := make(map[string]bool, len(names))
+	first := make([]bool, len(names))
+	for i, name := range names {
+		key := strings.ToLower(name)
+		if !used[key] {
+			used[key] = true
+			first[i] = true
+		}
+	}
+
+	out := make([]string, len(names))
+	for i, name := range names {
+		if first[i] {
+			out[i] = name
+			continue
+		}
+		for n := 2; ; n++ {
+			suffix := "_" + strconv.Itoa(n)
+			if len(suffix) > o.MaxLength {
+				return nil, fmt.Errorf("no unique column name of at most %d characters for field %s", o.MaxLength, name)
+			}
+			base := name
+			if len(base)+len(suffix) > o.MaxLength {
+				base = base[:o.MaxLength-len(suffix)]
+			}
+			candidate := base + suffix
+			if key := strings.ToLower(candidate); !used[key] {
+				used[key] = true
+				out[i] = candidate
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func isReservedName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, prefix := range reservedColumnPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := map[string]string{
+		"userId":         "userId",
+		"user-id":        "user_id",
+		"first name!!":   "first_name_",
+		"1stPlace":       "_1stPlace",
+		"_PARTITIONTIME": "f_PARTITIONTIME",
+		"_table_suffix":  "f_table_suffix",
+		"名前":             "_",
+		"":               "_",
+	}
+	for in, want := range tests {
+		if got := (SanitizeOptions{}).SanitizeName(in); got != want {
+			t.Fatalf("SanitizeName(%q): Expected %q, but got %q", in, want, got)
+		}
+	}
+
+	custom := SanitizeOptions{Replacement: "x", DigitPrefix: "n", ReservedPrefix: "r", MaxLength: 5}
+	if got := custom.SanitizeName("9a.b"); got != "n9axb" {
+		t.Fatalf("Expected n9axb, but got %q", got)
+	}
+	if got := (SanitizeOptions{}).SanitizeName(strings.Repeat("a", 400)); len(got) != MaxColumnNameLength {
+		t.Fatalf("Expected name truncated to %d characters, but got %d", MaxColumnNameLength, len(got))
+	}
+}
+
+func TestConvertAvroToBigQueryWithSanitize(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "User",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "userId", "type": "string"},
+			map[string]interface{}{"name": "userID", "type": "string"},
+			map[string]interface{}{"name": "userId_2", "type": "string"},
+			map[string]interface{}{"name": "home-address", "type": map[string]interface{}{
+				"type": "record",
+				"name": "Address",
+				"fields": []interface{}{
+					map[string]interface{}{"name": "zip code", "type": "string"},
+				},
+			}},
+			map[string]interface{}{"name": "orders", "type": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "record",
+					"name": "Order",
+					"fields": []interface{}{
+						map[string]interface{}{"name": "2fa", "type": "boolean"},
+					},
+				},
+			}},
+		},
+	}
+
+	if _, err := ConvertAvroToBigQuery(avroSchema); err == nil {
+		t.Fatalf("Expected the unsanitized schema to fail validation")
+	}
+
+	conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Sanitize: &SanitizeOptions{}})
+	if err != nil {
+		t.Fatalf("Error converting Avro schema: %v", err)
+	}
+
+	want := map[string]string{
+		"userId":                "userId",
+		"userID":                "userID_3",
+		"userId_2":              "userId_2",
+		"home-address":          "home_address",
+		"home-address.zip code": "home_address.zip_code",
+		"orders":                "orders",
+		"orders.2fa":            "orders._2fa",
+	}
+	mapping := conversion.Mapping()
+	if len(mapping) != len(want) {
+		t.Fatalf("Expected %d mapped fields, but got %v", len(want), mapping)
+	}
+	for avroPath, columnPath := range want {
+		if mapping[avroPath] != columnPath {
+			t.Fatalf("%s: Expected column %s, but got %s", avroPath, columnPath, mapping[avroPath])
+		}
+	}
+	if conversion.Schema[3].Schema[0].Name != "zip_code" || conversion.Schema[4].Schema[0].Name != "_2fa" {
+		t.Fatalf("Nested fields were not renamed: %+v", conversion.Schema)
+	}
+
+	// The input schema is left untouched.
+	if avroSchema["fields"].([]interface{})[3].(map[string]interface{})["name"] != "home-address" {
+		t.Fatalf("Input Avro schema was modified")
+	}
+}
+
+func TestSanitizeMaxLengthCollisions(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "R",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "a", "type": "string"},
+			map[string]interface{}{"name": "A", "type": "string"},
+		},
+	}
+
+	_, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Sanitize: &SanitizeOptions{MaxLength: 1}})
+	if err == nil || !strings.Contains(err.Error(), "no unique column name of at most 1 characters for field A") {
+		t.Fatalf("Expected an error for names that cannot be made unique, but got %v", err)
+	}
+
+	conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{Sanitize: &SanitizeOptions{MaxLength: 2}})
+	if err != nil {
+		t.Fatalf("Error converting Avro schema: %v", err)
+	}
+	if conversion.Schema[0].Name != "a" || conversion.Schema[1].Name != "_2" {
+		t.Fatalf("Expected columns a and _2, but got %s and %s", conversion.Schema[0].Name, conversion.Schema[1].Name)
+	}
+}
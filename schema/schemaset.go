@@ -0,0 +1,332 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SchemaSet is a registry of the named types defined across a set of Avro
+// schema files, which may reference each other's types by name. Each type
+// is held by its full name with the named types it defines replaced by
+// references, so that Resolve can define every type once, at its first
+// use.
+type SchemaSet struct {
+	types   map[string]map[string]interface{}
+	sources map[string]string
+	order   []string
+}
+
+// NewSchemaSet returns an empty schema set.
+func NewSchemaSet() *SchemaSet {
+	return &SchemaSet{types: make(map[string]map[string]interface{}), sources: make(map[string]string)}
+}
+
+// LoadSchemaSet loads the Avro schema files at paths into a new set with
+// Load, and checks it. It fails on types defined differently in two
+// places and on references to types defined nowhere.
+func LoadSchemaSet(paths ...string) (*SchemaSet, error) {
+	s := NewSchemaSet()
+	if err := s.Load(paths...); err != nil {
+		return nil, err
+	}
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load adds the types of the Avro schema files at paths, in order. Each
+// directory is walked for .avsc files in lexical order, so the load order,
+// and with it the errors reported, is deterministic. A file holds a schema
+// or a JSON array of schemas.
+func (s *SchemaSet) Load(paths ...string) error {
+	for _, path := range paths {
+		files, err := schemaFiles(path)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			var avroSchema interface{}
+			if err := json.Unmarshal(content, &avroSchema); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			if err := s.Add(file, avroSchema); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// schemaFiles returns path, or the .avsc files under the directory path.
+func schemaFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".avsc") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s holds no .avsc files", path)
+	}
+	return files, nil
+}
+
+// Add registers the named types defined in avroSchema, a schema or an
+// array of schemas, read from source. A type already registered must have
+// the same canonical form.
+func (s *SchemaSet) Add(source string, avroSchema interface{}) error {
+	schemas, ok := avroSchema.([]interface{})
+	if !ok {
+		schemas = []interface{}{avroSchema}
+	}
+	for _, t := range schemas {
+		def, ok := t.(map[string]interface{})
+		if !ok || !isNamedDefinition(def) {
+			return fmt.Errorf("%s: top-level schema is not a named type", source)
+		}
+		if _, err := s.hoist(source, def, ""); err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+	}
+	return nil
+}
+
+func isNamedDefinition(t map[string]interface{}) bool {
+	switch t["type"] {
+	case "record", "error", "enum", "fixed":
+		return true
+	}
+	return false
+}
+
+// hoist returns t, defined in namespace ns, with the named types it
+// defines registered and replaced by their full names.
+func (s *SchemaSet) hoist(source string, t interface{}, ns string) (interface{}, error) {
+	switch t := t.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, branch := range t {
+			var err error
+			if out[i], err = s.hoist(source, branch, ns); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case map[string]interface{}:
+		typ, ok := t["type"].(string)
+		if !ok {
+			nested, err := s.hoist(source, t["type"], ns)
+			if err != nil {
+				return nil, err
+			}
+			out := copyMap(t)
+			out["type"] = nested
+			return out, nil
+		}
+		out := copyMap(t)
+		switch typ {
+		case "record", "error":
+			if _, ok := t["name"].(string); !ok {
+				return nil, fmt.Errorf("%s without a name", typ)
+			}
+			full := definedName(t, ns)
+			fields, ok := t["fields"].([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: invalid avro record fields", full)
+			}
+			outFields := make([]interface{}, len(fields))
+			for i, f := range fields {
+				field, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%s: invalid avro record field", full)
+				}
+				outField := copyMap(field)
+				var err error
+				if outField["type"], err = s.hoist(source, field["type"], namespaceOf(full)); err != nil {
+					return nil, err
+				}
+				outFields[i] = outField
+			}
+			out["fields"] = outFields
+			return full, s.register(source, full, out)
+		case "enum", "fixed":
+			if _, ok := t["name"].(string); !ok {
+				return nil, fmt.Errorf("%s without a name", typ)
+			}
+			full := definedName(t, ns)
+			return full, s.register(source, full, out)
+		case "array":
+			items, err := s.hoist(source, t["items"], ns)
+			if err != nil {
+				return nil, err
+			}
+			out["items"] = items
+		case "map":
+			values, err := s.hoist(source, t["values"], ns)
+			if err != nil {
+				return nil, err
+			}
+			out["values"] = values
+		}
+		return out, nil
+	}
+	return t, nil
+}
+
+// register adds the definition def of the type full read from source.
+func (s *SchemaSet) register(source, full string, def map[string]interface{}) error {
+	def["name"] = full[strings.LastIndex(full, ".")+1:]
+	if ns := namespaceOf(full); ns != "" {
+		def["namespace"] = ns
+	} else {
+		delete(def, "namespace")
+	}
+	prev, ok := s.types[full]
+	if !ok {
+		s.types[full] = def
+		s.sources[full] = source
+		s.order = append(s.order, full)
+		return nil
+	}
+	if !sameCanonicalForm(prev, def) {
+		return fmt.Errorf("%s conflicts with its definition in %s", full, s.sources[full])
+	}
+	return nil
+}
+
+func sameCanonicalForm(a, b map[string]interface{}) bool {
+	ca, errA := CanonicalForm(a)
+	cb, errB := CanonicalForm(b)
+	return errA == nil && errB == nil && string(ca) == string(cb)
+}
+
+// Names returns the full names of the types in the set, in load order.
+func (s *SchemaSet) Names() []string {
+	return append([]string(nil), s.order...)
+}
+
+// Source returns the source the type of full name name was first read
+// from, or "" when the set has no such type.
+func (s *SchemaSet) Source(name string) string {
+	return s.sources[name]
+}
+
+// Check reports references to types defined nowhere in the set.
+func (s *SchemaSet) Check() error {
+	var missing []string
+	for _, name := range s.order {
+		def := s.types[name]
+		for _, ref := range references(def, namespaceOf(name)) {
+			if _, ok := s.types[ref]; !ok {
+				missing = append(missing, fmt.Sprintf("%s (referenced by %s in %s)", ref, name, s.sources[name]))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("unknown types: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// references returns the full names of the named types referenced by t,
+// defined in namespace ns, which defines no named types of its own
+// beyond itself.
+func references(t interface{}, ns string) []string {
+	switch t := t.(type) {
+	case string:
+		if primitiveTypes[t] {
+			return nil
+		}
+		return []string{fullName(t, ns)}
+	case []interface{}:
+		var refs []string
+		for _, branch := range t {
+			refs = append(refs, references(branch, ns)...)
+		}
+		return refs
+	case map[string]interface{}:
+		typ, ok := t["type"].(string)
+		if !ok {
+			return references(t["type"], ns)
+		}
+		switch typ {
+		case "record", "error":
+			var refs []string
+			fields, _ := t["fields"].([]interface{})
+			for _, f := range fields {
+				field, _ := f.(map[string]interface{})
+				if field["type"] == "record" {
+					// A record declared directly on the field.
+					continue
+				}
+				refs = append(refs, references(field["type"], ns)...)
+			}
+			return refs
+		case "enum", "fixed":
+			return nil
+		case "array":
+			return references(t["items"], ns)
+		case "map":
+			return references(t["values"], ns)
+		}
+		return references(typ, ns)
+	}
+	return nil
+}
+
+// Resolve returns the type name, a full name or a name unique among the
+// types of the set, as a standalone schema: its references are inlined
+// with InlineReferences, so each type is defined at its first use.
+func (s *SchemaSet) Resolve(name string) (map[string]interface{}, error) {
+	full, err := s.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return InlineReferences(s.types[full], s.types)
+}
+
+// lookup returns the full name of the type name.
+func (s *SchemaSet) lookup(name string) (string, error) {
+	if _, ok := s.types[name]; ok {
+		return name, nil
+	}
+	var matches []string
+	for _, full := range s.order {
+		if full[strings.LastIndex(full, ".")+1:] == name {
+			matches = append(matches, full)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("unknown type %s", name)
+	case 1:
+		return matches[0], nil
+	}
+	sort.Strings(matches)
+	return "", fmt.Errorf("type name %s is ambiguous: %s", name, strings.Join(matches, ", "))
+}
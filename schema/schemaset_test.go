@@ -0,0 +1,145 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func writeSchemaFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadSchemaSet(t *testing.T) {
+	dir := writeSchemaFiles(t, map[string]string{
+		"order.avsc": `{"type": "record", "name": "Order", "namespace": "com.shop", "fields": [
+			{"name": "id", "type": "string"},
+			{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "SHIPPED"]}},
+			{"name": "customer", "type": "com.crm.Customer"},
+			{"name": "shipping", "type": ["null", "com.common.Address"]},
+			{"name": "total", "type": "com.common.Money"}
+		]}`,
+		"crm/customer.avsc": `{"type": "record", "name": "Customer", "namespace": "com.crm", "fields": [
+			{"name": "name", "type": "string"},
+			{"name": "home", "type": "com.common.Address"},
+			{"name": "lastStatus", "type": ["null", "com.shop.Status"]}
+		]}`,
+		"common/types.avsc": `[
+			{"type": "record", "name": "Address", "namespace": "com.common", "fields": [{"name": "city", "type": "string"}]},
+			{"type": "record", "name": "Money", "namespace": "com.common", "fields": [
+				{"name": "units", "type": "long"},
+				{"name": "currency", "type": {"type": "enum", "name": "Currency", "symbols": ["EUR", "USD"]}}
+			]}
+		]`,
+		"README.md": "not a schema",
+	})
+
+	set, err := LoadSchemaSet(dir)
+	if err != nil {
+		t.Fatalf("Error loading schema set: %v", err)
+	}
+	expected := []string{"com.common.Address", "com.common.Currency", "com.common.Money", "com.crm.Customer", "com.shop.Status", "com.shop.Order"}
+	if !reflect.DeepEqual(set.Names(), expected) {
+		t.Fatalf("Expected types %v in load order, but got %v", expected, set.Names())
+	}
+	if source := set.Source("com.crm.Customer"); source != filepath.Join(dir, "crm", "customer.avsc") {
+		t.Fatalf("Expected Customer to come from crm/customer.avsc, but got %s", source)
+	}
+
+	order, err := set.Resolve("Order")
+	if err != nil {
+		t.Fatalf("Error resolving Order: %v", err)
+	}
+	if _, err := CanonicalForm(order); err != nil {
+		t.Fatalf("Expected a valid schema, got %v", err)
+	}
+	s, err := ConvertAvroToBigQuery(order)
+	if err != nil {
+		t.Fatalf("Error converting schema: %v", err)
+	}
+	if len(s) != 5 || s[2].Type != bigquery.RecordFieldType || s[2].Schema[1].Type != bigquery.RecordFieldType {
+		t.Fatalf("Expected customer and its home address to be records, but got %v", s)
+	}
+	if s[3].Type != bigquery.RecordFieldType || !reflect.DeepEqual(s[3].Schema, s[2].Schema[1].Schema) {
+		// The Address definition came with the customer, so the shipping
+		// address is a reference, which converts like the definition.
+		t.Fatalf("Expected shipping to be an address record like the home address of customer, but got %v", s[3])
+	}
+	if s[4].Type != bigquery.RecordFieldType || s[4].Schema[1].Type != bigquery.StringFieldType {
+		t.Fatalf("Expected total to be a record with a currency enum, but got %v", s[4])
+	}
+
+	money, err := set.Resolve("com.common.Money")
+	if err != nil {
+		t.Fatalf("Error resolving Money: %v", err)
+	}
+	if money["name"] != "Money" || money["namespace"] != "com.common" {
+		t.Fatalf("Expected Money to keep its name and namespace, but got %v", money)
+	}
+
+	t.Run("files", func(t *testing.T) {
+		set, err := LoadSchemaSet(filepath.Join(dir, "common", "types.avsc"), filepath.Join(dir, "order.avsc"), filepath.Join(dir, "crm"))
+		if err != nil {
+			t.Fatalf("Error loading schema set: %v", err)
+		}
+		expected := []string{"com.common.Address", "com.common.Currency", "com.common.Money", "com.shop.Status", "com.shop.Order", "com.crm.Customer"}
+		if !reflect.DeepEqual(set.Names(), expected) {
+			t.Fatalf("Expected types %v in argument order, but got %v", expected, set.Names())
+		}
+		customer, err := set.Resolve("Customer")
+		if err != nil {
+			t.Fatalf("Error resolving Customer: %v", err)
+		}
+		s, err := ConvertAvroToBigQuery(customer)
+		if err != nil || s[1].Type != bigquery.RecordFieldType {
+			t.Fatalf("Expected home to be a record, but got %v (%v)", s, err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := LoadSchemaSet(filepath.Join(dir, "order.avsc")); err == nil || !strings.Contains(err.Error(), "com.crm.Customer (referenced by com.shop.Order") {
+			t.Fatalf("Expected an error naming the missing type, but got %v", err)
+		}
+		conflict := writeSchemaFiles(t, map[string]string{
+			"a.avsc": `{"type": "record", "name": "Address", "namespace": "com.common", "fields": [{"name": "city", "type": "string"}]}`,
+			"b.avsc": `{"type": "record", "name": "Address", "namespace": "com.common", "doc": "Same", "fields": [{"name": "city", "type": "string"}]}`,
+			"c.avsc": `{"type": "record", "name": "Holder", "fields": [{"name": "a", "type": {"type": "record", "name": "Address", "namespace": "com.common", "fields": [{"name": "zip", "type": "string"}]}}]}`,
+		})
+		_, err := LoadSchemaSet(conflict)
+		if err == nil || !strings.Contains(err.Error(), "c.avsc: com.common.Address conflicts with its definition in "+filepath.Join(conflict, "a.avsc")) {
+			t.Fatalf("Expected a conflict error, but got %v", err)
+		}
+		ambiguous := writeSchemaFiles(t, map[string]string{
+			"a.avsc": `{"type": "enum", "name": "Kind", "namespace": "a", "symbols": ["X"]}`,
+			"b.avsc": `{"type": "enum", "name": "Kind", "namespace": "b", "symbols": ["Y"]}`,
+		})
+		set, err := LoadSchemaSet(ambiguous)
+		if err != nil {
+			t.Fatalf("Error loading schema set: %v", err)
+		}
+		if _, err := set.Resolve("Kind"); err == nil {
+			t.Fatalf("Expected an error for an ambiguous name")
+		}
+		if _, err := LoadSchemaSet(filepath.Join(dir, "missing")); err == nil {
+			t.Fatalf("Expected an error for a missing path")
+		}
+		if err := NewSchemaSet().Add("inline", "string"); err == nil {
+			t.Fatalf("Expected an error for a schema that is not a named type")
+		}
+	})
+}
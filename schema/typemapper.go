@@ -0,0 +1,194 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// AvroType is a node of an Avro schema handed to a TypeMapper.
+type AvroType struct {
+	// Type is a primitive type name such as "long", a complex type name
+	// such as "record", "enum" or "array", or the name of a named type
+	// referenced by name but defined nowhere in the schema. References to
+	// defined types are handed over as their definition.
+	Type string
+	// LogicalType is the logicalType property of the type, if any.
+	LogicalType string
+	// Schema is the type object, or nil when the type was given by name.
+	Schema map[string]interface{}
+}
+
+// Property returns the property name of the type object, or nil.
+func (t AvroType) Property(name string) interface{} {
+	return t.Schema[name]
+}
+
+// BigQueryType is the column type a TypeMapper maps an Avro type to.
+// Precision, Scale and MaxLength are left zero when they do not apply.
+type BigQueryType struct {
+	Type      bigquery.FieldType
+	Precision int64
+	Scale     int64
+	MaxLength int64
+}
+
+// TypeMapper maps Avro types to BigQuery column types. The converter
+// consults it for every type of every field, including array items and the
+// branches of unions, but not for unions themselves. When a record, array
+// or map is mapped to RECORD, the converter builds the nested schema: the
+// fields of a record, the element of an array as a REPEATED column, and
+// the entries of a map as a REPEATED RECORD of key and value. Mapping them
+// to any other type, such as JSON, stores the whole value in one column.
+type TypeMapper interface {
+	MapType(t AvroType) (BigQueryType, error)
+}
+
+// TypeMapperFunc adapts an ordinary function to a TypeMapper.
+type TypeMapperFunc func(t AvroType) (BigQueryType, error)
+
+// MapType returns f(t).
+func (f TypeMapperFunc) MapType(t AvroType) (BigQueryType, error) {
+	return f(t)
+}
+
+// DefaultTypeMapper is the mapping used by ConvertAvroToBigQuery. Logical
+// types map to DATE, TIME, TIMESTAMP, DATETIME, and decimals on bytes or
+// fixed to NUMERIC(P, S) or BIGNUMERIC(P, S). Strings with sqlType JSON map
+// to JSON, enums to STRING and fixed to BYTES(size); a maxLength property
+// on a string or bytes type sets the maximum length of the column.
+// Unknown logical types are ignored, as the Avro specification requires,
+// and types referenced by name map to STRING. Avro maps are not supported.
+var DefaultTypeMapper TypeMapper = TypeMapperFunc(defaultMapType)
+
+// WithLogicalTypes returns a TypeMapper that maps types carrying one of the
+// logical types in handlers with its handler, and every other type with
+// base, or DefaultTypeMapper when base is nil. This registers custom
+// logical types, e.g. a "money" logical type mapped to NUMERIC.
+func WithLogicalTypes(base TypeMapper, handlers map[string]TypeMapperFunc) TypeMapper {
+	if base == nil {
+		base = DefaultTypeMapper
+	}
+	return TypeMapperFunc(func(t AvroType) (BigQueryType, error) {
+		if handler, ok := handlers[t.LogicalType]; ok && t.LogicalType != "" {
+			return handler(t)
+		}
+		return base.MapType(t)
+	})
+}
+
+func defaultMapType(t AvroType) (BigQueryType, error) {
+	switch t.Type {
+	case "null":
+		// A placeholder; the null branch of a union only makes a column
+		// nullable.
+		return BigQueryType{Type: bigquery.StringFieldType}, nil
+	case "boolean":
+		return BigQueryType{Type: bigquery.BooleanFieldType}, nil
+	case "int":
+		switch t.LogicalType {
+		case "date":
+			return BigQueryType{Type: bigquery.DateFieldType}, nil
+		case "time-millis":
+			return BigQueryType{Type: bigquery.TimeFieldType}, nil
+		}
+		return BigQueryType{Type: bigquery.IntegerFieldType}, nil
+	case "long":
+		switch t.LogicalType {
+		case "time-micros":
+			return BigQueryType{Type: bigquery.TimeFieldType}, nil
+		case "timestamp-millis", "timestamp-micros":
+			return BigQueryType{Type: bigquery.TimestampFieldType}, nil
+		case "local-timestamp-millis", "local-timestamp-micros":
+			return BigQueryType{Type: bigquery.DateTimeFieldType}, nil
+		}
+		return BigQueryType{Type: bigquery.IntegerFieldType}, nil
+	case "float", "double":
+		return BigQueryType{Type: bigquery.FloatFieldType}, nil
+	case "bytes":
+		if t.LogicalType == "decimal" {
+			return decimalType(t)
+		}
+		return lengthType(t, bigquery.BytesFieldType)
+	case "fixed":
+		size, err := fixedSize(t)
+		if err != nil {
+			return BigQueryType{}, err
+		}
+		if t.LogicalType == "decimal" {
+			d, err := decimalType(t)
+			if err == nil && d.Precision > maxFixedPrecision(size) {
+				err = fmt.Errorf("decimal precision %d does not fit in fixed size %d", d.Precision, size)
+			}
+			return d, err
+		}
+		return BigQueryType{Type: bigquery.BytesFieldType, MaxLength: size}, nil
+	case "string":
+		if sqlType, _ := t.Property("sqlType").(string); strings.EqualFold(sqlType, "json") {
+			return BigQueryType{Type: bigquery.JSONFieldType}, nil
+		}
+		return lengthType(t, bigquery.StringFieldType)
+	case "enum":
+		return BigQueryType{Type: bigquery.StringFieldType}, nil
+	case "record", "error", "array":
+		return BigQueryType{Type: bigquery.RecordFieldType}, nil
+	case "map":
+		return BigQueryType{}, fmt.Errorf("unsupported avro type: map")
+	}
+	return BigQueryType{Type: bigquery.StringFieldType}, nil
+}
+
+// lengthType returns typ with the maximum length given by the maxLength
+// property of t, if any.
+func lengthType(t AvroType, typ bigquery.FieldType) (BigQueryType, error) {
+	mapped := BigQueryType{Type: typ}
+	if v := t.Property("maxLength"); v != nil {
+		n, ok := v.(float64)
+		if !ok || n <= 0 || n != float64(int64(n)) {
+			return BigQueryType{}, fmt.Errorf("invalid maxLength %v", v)
+		}
+		mapped.MaxLength = int64(n)
+	}
+	return mapped, nil
+}
+
+func fixedSize(t AvroType) (int64, error) {
+	size, ok := t.Property("size").(float64)
+	if !ok || size <= 0 || size != float64(int64(size)) {
+		return 0, fmt.Errorf("invalid fixed size %v", t.Property("size"))
+	}
+	return int64(size), nil
+}
+
+// maxFixedPrecision returns the number of decimal digits a two's-complement
+// integer of size bytes can hold, floor(log10(2^(8*size-1) - 1)).
+func maxFixedPrecision(size int64) int64 {
+	if size > 32 {
+		// Beyond BIGNUMERIC anyway.
+		return math.MaxInt64
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(8*size-1))
+	limit.Sub(limit, big.NewInt(1))
+	return int64(len(limit.String()) - 1)
+}
+
+// decimalType maps a decimal to NUMERIC when its precision and scale fit,
+// and to BIGNUMERIC otherwise.
+func decimalType(t AvroType) (BigQueryType, error) {
+	precision, _ := t.Property("precision").(float64)
+	scale, _ := t.Property("scale").(float64)
+	d := BigQueryType{Precision: int64(precision), Scale: int64(scale)}
+	integerDigits := d.Precision - d.Scale
+	switch {
+	case integerDigits >= 0 && integerDigits <= 29 && d.Scale <= 9 && d.Precision <= 38:
+		d.Type = bigquery.NumericFieldType
+	case integerDigits >= 0 && integerDigits <= 38 && d.Scale <= 38 && d.Precision <= 76:
+		d.Type = bigquery.BigNumericFieldType
+	default:
+		return BigQueryType{}, fmt.Errorf("precision and scale are out of bounds")
+	}
+	return d, nil
+}
@@ -0,0 +1,161 @@
+package schema
+
+import (
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestDefaultTypeMapperConsistency(t *testing.T) {
+	date := map[string]interface{}{"type": "int", "logicalType": "date"}
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "R",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "required", "type": date},
+			map[string]interface{}{"name": "optional", "type": []interface{}{"null", date}},
+			map[string]interface{}{"name": "dates", "type": map[string]interface{}{"type": "array", "items": date}},
+			map[string]interface{}{"name": "names", "type": map[string]interface{}{"type": "array", "items": []interface{}{"null", "string"}, "name": "name"}},
+			map[string]interface{}{"name": "nested", "type": map[string]interface{}{"type": map[string]interface{}{"type": "long"}}},
+			map[string]interface{}{"name": "plain", "type": map[string]interface{}{"type": "long"}},
+		},
+	}
+	s, err := ConvertAvroToBigQuery(avroSchema)
+	if err != nil {
+		t.Fatalf("Error converting Avro schema: %v", err)
+	}
+	if s[0].Type != bigquery.DateFieldType || !s[0].Required || s[1].Type != bigquery.DateFieldType || s[1].Required {
+		t.Fatalf("Expected DATE columns for the date logical type, but got %s and %s", s[0].Type, s[1].Type)
+	}
+	if dates := s[2]; !dates.Repeated || len(dates.Schema) != 1 || dates.Schema[0].Name != "element" || dates.Schema[0].Type != bigquery.DateFieldType {
+		t.Fatalf("Expected a repeated DATE element, but got %+v", dates.Schema)
+	}
+	if names := s[3]; len(names.Schema) != 1 || names.Schema[0].Name != "name" || names.Schema[0].Type != bigquery.StringFieldType {
+		t.Fatalf("Expected a STRING element named name, but got %+v", names.Schema)
+	}
+	if s[4].Type != bigquery.IntegerFieldType || s[5].Type != bigquery.IntegerFieldType {
+		t.Fatalf("Expected INTEGER columns, but got %s and %s", s[4].Type, s[5].Type)
+	}
+}
+
+func TestWithLogicalTypes(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Payment",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "paid_at", "type": map[string]interface{}{"type": "string", "logicalType": "iso-datetime"}},
+			map[string]interface{}{"name": "amount", "type": []interface{}{"null", map[string]interface{}{"type": "bytes", "logicalType": "money", "currency": "EUR"}}},
+			map[string]interface{}{"name": "created", "type": map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}},
+			map[string]interface{}{"name": "tags", "type": map[string]interface{}{"type": "map", "values": "string"}},
+		},
+	}
+	mapper := WithLogicalTypes(TypeMapperFunc(func(t AvroType) (BigQueryType, error) {
+		if t.Type == "map" {
+			return BigQueryType{Type: bigquery.RecordFieldType}, nil
+		}
+		return DefaultTypeMapper.MapType(t)
+	}), map[string]TypeMapperFunc{
+		"iso-datetime": func(t AvroType) (BigQueryType, error) {
+			return BigQueryType{Type: bigquery.DateTimeFieldType}, nil
+		},
+		"money": func(t AvroType) (BigQueryType, error) {
+			if t.Property("currency") != "EUR" {
+				return BigQueryType{}, fmt.Errorf("unsupported currency %v", t.Property("currency"))
+			}
+			return BigQueryType{Type: bigquery.NumericFieldType, Precision: 18, Scale: 2}, nil
+		},
+	})
+
+	conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{TypeMapper: mapper})
+	if err != nil {
+		t.Fatalf("Error converting Avro schema: %v", err)
+	}
+	s := conversion.Schema
+	if s[0].Type != bigquery.DateTimeFieldType {
+		t.Fatalf("Expected DATETIME for iso-datetime, but got %s", s[0].Type)
+	}
+	if s[1].Type != bigquery.NumericFieldType || s[1].Precision != 18 || s[1].Scale != 2 || s[1].Required {
+		t.Fatalf("Expected a nullable NUMERIC(18, 2) for money, but got %s", FieldTypeString(s[1]))
+	}
+	if s[2].Type != bigquery.TimestampFieldType {
+		t.Fatalf("Expected the base mapper for timestamp-millis, but got %s", s[2].Type)
+	}
+	if tags := s[3]; !tags.Repeated || len(tags.Schema) != 2 || tags.Schema[0].Name != "key" || tags.Schema[1].Name != "value" {
+		t.Fatalf("Expected a repeated key/value RECORD for the map, but got %+v", tags.Schema)
+	}
+
+	if _, err := ConvertAvroToBigQuery(avroSchema); err == nil {
+		t.Fatalf("Expected the default mapper to reject Avro maps")
+	}
+}
+
+func TestTypeMapperRecordAsJSON(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Event",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "payload", "type": map[string]interface{}{
+				"type": "record", "name": "Payload", "fields": []interface{}{
+					map[string]interface{}{"name": "a", "type": "string"},
+				},
+			}},
+		},
+	}
+	mapper := TypeMapperFunc(func(t AvroType) (BigQueryType, error) {
+		if t.Type == "record" && t.Property("name") == "Payload" {
+			return BigQueryType{Type: bigquery.JSONFieldType}, nil
+		}
+		return DefaultTypeMapper.MapType(t)
+	})
+	conversion, err := ConvertAvroToBigQueryWithOptions(avroSchema, ConvertOptions{TypeMapper: mapper})
+	if err != nil {
+		t.Fatalf("Error converting Avro schema: %v", err)
+	}
+	if payload := conversion.Schema[0]; payload.Type != bigquery.JSONFieldType || payload.Schema != nil {
+		t.Fatalf("Expected a JSON column without fields, but got %+v", payload)
+	}
+}
+
+func TestDefaultTypeMapperParameters(t *testing.T) {
+	field := func(name string, typ map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"name": name, "type": typ}
+	}
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "R",
+		"fields": []interface{}{
+			field("md5", map[string]interface{}{"type": "fixed", "name": "MD5", "size": float64(16)}),
+			field("code", map[string]interface{}{"type": "string", "maxLength": float64(8)}),
+			field("blob", map[string]interface{}{"type": "bytes", "maxLength": float64(1024)}),
+			field("price", map[string]interface{}{"type": "fixed", "name": "Price", "size": float64(8),
+				"logicalType": "decimal", "precision": float64(18), "scale": float64(4)}),
+			field("total", map[string]interface{}{"type": "fixed", "name": "Total", "size": float64(32),
+				"logicalType": "decimal", "precision": float64(40), "scale": float64(10)}),
+		},
+	}
+	s, err := ConvertAvroToBigQuery(avroSchema)
+	if err != nil {
+		t.Fatalf("Error converting Avro schema: %v", err)
+	}
+	want := []string{"BYTES(16)", "STRING(8)", "BYTES(1024)", "NUMERIC(18, 4)", "BIGNUMERIC(40, 10)"}
+	for i, w := range want {
+		if got := FieldTypeString(s[i]); got != w {
+			t.Fatalf("Expected %s to be %s, but got %s", s[i].Name, w, got)
+		}
+	}
+
+	invalid := map[string]map[string]interface{}{
+		"fixed without size":    {"type": "fixed", "name": "F"},
+		"fractional maxLength":  {"type": "string", "maxLength": 1.5},
+		"precision beyond size": {"type": "fixed", "name": "F", "size": float64(4), "logicalType": "decimal", "precision": float64(10)},
+	}
+	for name, typ := range invalid {
+		t.Run(name, func(t *testing.T) {
+			r := map[string]interface{}{"type": "record", "name": "R", "fields": []interface{}{field("f", typ)}}
+			if _, err := ConvertAvroToBigQuery(r); err == nil {
+				t.Fatalf("Expected an error for %v", typ)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// BigQuery schema limits checked by ValidateBigQuerySchema.
+const (
+	// MaxColumns is the maximum number of columns in a table, counting
+	// nested fields.
+	MaxColumns = 10000
+	// MaxNestingDepth is the maximum nesting depth of RECORD columns.
+	MaxNestingDepth = 15
+	// MaxColumnNameLength is the maximum length of a column name.
+	MaxColumnNameLength = 300
+	// MaxDescriptionLength is the maximum length of a column description.
+	MaxDescriptionLength = 1024
+)
+
+// reservedColumnPrefixes are the prefixes BigQuery reserves for pseudo
+// columns and system use. They are matched case-insensitively.
+var reservedColumnPrefixes = []string{
+	"_TABLE_",
+	"_FILE_",
+	"_PARTITION",
+	"_ROW_TIMESTAMP",
+	"__ROOT__",
+	"_COLIDENTIFIER",
+	"_CHANGE_SEQUENCE_NUMBER",
+	"_CHANGE_TYPE",
+	"_CHANGE_TIMESTAMP",
+}
+
+// ValidationError is a single violation of a BigQuery schema limit or
+// naming rule. Path is the dot separated path of the offending field, or
+// empty for violations of the schema as a whole.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors is the list of violations returned by
+// ValidateBigQuerySchema.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Error()
+	}
+	noun := "violations"
+	if len(e) == 1 {
+		noun = "violation"
+	}
+	return fmt.Sprintf("invalid BigQuery schema: %d %s:\n%s", len(e), noun, strings.Join(msgs, "\n"))
+}
+
+// ValidateBigQuerySchema checks the schema against the BigQuery schema
+// limits and column naming rules: at most MaxColumns columns, RECORD
+// nesting of at most MaxNestingDepth levels, names of at most
+// MaxColumnNameLength characters made of letters, digits and underscores
+// and not starting with a digit, no case-insensitive duplicate names within
+// a record, no reserved name prefixes such as _PARTITION or _TABLE_,
+// descriptions of at most MaxDescriptionLength characters, maximum lengths
+// only on STRING and BYTES columns, and precision and scale only on
+// NUMERIC and BIGNUMERIC columns and within their bounds.
+//
+// It returns nil if the schema is valid, or ValidationErrors listing every
+// violation.
+func ValidateBigQuerySchema(s bigquery.Schema) error {
+	v := &schemaValidator{}
+	v.validateFields("", s, 1)
+	if v.columns > MaxColumns {
+		v.errs = append(v.errs, ValidationError{
+			Message: fmt.Sprintf("schema has %d columns, more than the maximum of %d", v.columns, MaxColumns),
+		})
+	}
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	columns int
+	errs    ValidationErrors
+}
+
+func (v *schemaValidator) addf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) validateFields(prefix string, fields bigquery.Schema, depth int) {
+	seen := make(map[string]string, len(fields))
+	for _, f := range fields {
+		v.columns++
+		path := joinPath(prefix, f.Name)
+
+		v.validateName(path, f.Name)
+		key := strings.ToLower(f.Name)
+		if first, ok := seen[key]; ok {
+			v.addf(path, "duplicate column name, conflicts with %q", first)
+		} else {
+			seen[key] = f.Name
+		}
+		if n := len([]rune(f.Description)); n > MaxDescriptionLength {
+			v.addf(path, "description has %d characters, more than the maximum of %d", n, MaxDescriptionLength)
+		}
+		v.validateParameters(path, f)
+
+		if !isRecord(f) {
+			continue
+		}
+		if depth > MaxNestingDepth {
+			v.addf(path, "RECORD nested %d levels deep, more than the maximum of %d", depth, MaxNestingDepth)
+			continue
+		}
+		if len(f.Schema) == 0 {
+			v.addf(path, "RECORD must have at least one field")
+			continue
+		}
+		v.validateFields(path, f.Schema, depth+1)
+	}
+}
+
+// validateParameters checks the parameters of parameterized types:
+// STRING(L), BYTES(L), NUMERIC(P, S) and BIGNUMERIC(P, S).
+func (v *schemaValidator) validateParameters(path string, f *bigquery.FieldSchema) {
+	if f.MaxLength < 0 {
+		v.addf(path, "maximum length must be positive")
+	} else if f.MaxLength > 0 && f.Type != bigquery.StringFieldType && f.Type != bigquery.BytesFieldType {
+		v.addf(path, "maximum length is only allowed on STRING and BYTES columns, not %s", f.Type)
+	}
+	if f.Precision == 0 && f.Scale == 0 {
+		return
+	}
+	var maxScale, maxIntegerDigits int64
+	switch f.Type {
+	case bigquery.NumericFieldType:
+		maxScale, maxIntegerDigits = 9, 29
+	case bigquery.BigNumericFieldType:
+		maxScale, maxIntegerDigits = 38, 38
+	default:
+		v.addf(path, "precision and scale are only allowed on NUMERIC and BIGNUMERIC columns, not %s", f.Type)
+		return
+	}
+	minPrecision := f.Scale
+	if minPrecision < 1 {
+		minPrecision = 1
+	}
+	if f.Scale < 0 || f.Scale > maxScale || f.Precision < minPrecision || f.Precision > f.Scale+maxIntegerDigits {
+		v.addf(path, "%s is out of bounds: scale must be between 0 and %d and precision between max(1, scale) and scale + %d",
+			FieldTypeString(f), maxScale, maxIntegerDigits)
+	}
+}
+
+func (v *schemaValidator) validateName(path, name string) {
+	if name == "" {
+		v.addf(path, "column name is empty")
+		return
+	}
+	if n := len([]rune(name)); n > MaxColumnNameLength {
+		v.addf(path, "column name has %d characters, more than the maximum of %d", n, MaxColumnNameLength)
+	}
+	if !isValidColumnName(name) {
+		v.addf(path, "column name must contain only letters, numbers and underscores and must not start with a number")
+	}
+	upper := strings.ToUpper(name)
+	for _, prefix := range reservedColumnPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			v.addf(path, "column name uses the reserved prefix %s", prefix)
+			break
+		}
+	}
+}
+
+// isValidColumnName reports whether name follows the BigQuery column
+// naming rules.
+func isValidColumnName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestValidateBigQuerySchema(t *testing.T) {
+	t.Run("valid schema", func(t *testing.T) {
+		s := bigquery.Schema{
+			{Name: "id", Type: bigquery.IntegerFieldType},
+			{Name: "_private", Type: bigquery.StringFieldType},
+			{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+				{Name: "id", Type: bigquery.StringFieldType},
+			}},
+		}
+		if err := ValidateBigQuerySchema(s); err != nil {
+			t.Fatalf("Expected a valid schema, but got %v", err)
+		}
+	})
+
+	t.Run("reports every violation with its path", func(t *testing.T) {
+		s := bigquery.Schema{
+			{Name: "userId", Type: bigquery.StringFieldType},
+			{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+				{Name: "_PARTITIONTIME", Type: bigquery.TimestampFieldType},
+				{Name: "city", Type: bigquery.StringFieldType, Description: strings.Repeat("x", MaxDescriptionLength+1)},
+			}},
+			{Name: "userID", Type: bigquery.StringFieldType},
+			{Name: "1st-name", Type: bigquery.StringFieldType},
+			{Name: strings.Repeat("n", MaxColumnNameLength+1), Type: bigquery.StringFieldType},
+			{Name: "empty", Type: bigquery.RecordFieldType},
+		}
+		err := ValidateBigQuerySchema(s)
+		var verrs ValidationErrors
+		if !errors.As(err, &verrs) {
+			t.Fatalf("Expected ValidationErrors, but got %v", err)
+		}
+		wantPaths := []string{"address._PARTITIONTIME", "address.city", "userID", "1st-name", strings.Repeat("n", MaxColumnNameLength+1), "empty"}
+		if len(verrs) != len(wantPaths) {
+			t.Fatalf("Expected %d violations, but got %d:\n%v", len(wantPaths), len(verrs), err)
+		}
+		for i, path := range wantPaths {
+			if verrs[i].Path != path {
+				t.Fatalf("Violation %d: Expected path %s, but got %s", i, path, verrs[i].Path)
+			}
+		}
+	})
+
+	t.Run("error message", func(t *testing.T) {
+		err := ValidateBigQuerySchema(bigquery.Schema{{Name: "1st", Type: bigquery.StringFieldType}})
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid BigQuery schema: 1 violation:\n") {
+			t.Fatalf("Expected a single violation, but got %v", err)
+		}
+		err = ValidateBigQuerySchema(bigquery.Schema{{Name: "1st", Type: bigquery.StringFieldType}, {Name: "2nd", Type: bigquery.StringFieldType}})
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid BigQuery schema: 2 violations:\n") {
+			t.Fatalf("Expected two violations, but got %v", err)
+		}
+	})
+
+	t.Run("type parameters", func(t *testing.T) {
+		valid := bigquery.Schema{
+			{Name: "code", Type: bigquery.StringFieldType, MaxLength: 10},
+			{Name: "hash", Type: bigquery.BytesFieldType, MaxLength: 16},
+			{Name: "price", Type: bigquery.NumericFieldType, Precision: 2, Scale: 2},
+			{Name: "balance", Type: bigquery.BigNumericFieldType, Precision: 76, Scale: 38},
+		}
+		if err := ValidateBigQuerySchema(valid); err != nil {
+			t.Fatalf("Expected a valid schema, but got %v", err)
+		}
+		invalid := bigquery.Schema{
+			{Name: "count", Type: bigquery.IntegerFieldType, MaxLength: 10},
+			{Name: "ratio", Type: bigquery.FloatFieldType, Precision: 10},
+			{Name: "price", Type: bigquery.NumericFieldType, Precision: 40, Scale: 2},
+			{Name: "rate", Type: bigquery.NumericFieldType, Scale: 2},
+			{Name: "balance", Type: bigquery.BigNumericFieldType, Precision: 40, Scale: 39},
+		}
+		var verrs ValidationErrors
+		if err := ValidateBigQuerySchema(invalid); !errors.As(err, &verrs) || len(verrs) != len(invalid) {
+			t.Fatalf("Expected %d violations, but got %v", len(invalid), err)
+		}
+	})
+
+	t.Run("nesting depth", func(t *testing.T) {
+		leaf := bigquery.Schema{{Name: "leaf", Type: bigquery.StringFieldType}}
+		for i := 0; i < MaxNestingDepth+1; i++ {
+			leaf = bigquery.Schema{{Name: fmt.Sprintf("level%d", i), Type: bigquery.RecordFieldType, Schema: leaf}}
+		}
+		err := ValidateBigQuerySchema(leaf)
+		if err == nil || !strings.Contains(err.Error(), "nested 16 levels deep") {
+			t.Fatalf("Expected a nesting depth violation, but got %v", err)
+		}
+	})
+
+	t.Run("column count", func(t *testing.T) {
+		var s bigquery.Schema
+		for i := 0; i <= MaxColumns; i++ {
+			s = append(s, &bigquery.FieldSchema{Name: fmt.Sprintf("c%d", i), Type: bigquery.StringFieldType})
+		}
+		err := ValidateBigQuerySchema(s)
+		if err == nil || !strings.Contains(err.Error(), "10001 columns") {
+			t.Fatalf("Expected a column count violation, but got %v", err)
+		}
+	})
+}
+
+func TestConvertAvroToBigQueryValidates(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Event",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "_TABLE_SUFFIX", "type": "string"},
+		},
+	}
+	if _, err := ConvertAvroToBigQuery(avroSchema); err == nil {
+		t.Fatalf("Expected conversion to fail for a reserved column name")
+	}
+}
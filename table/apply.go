@@ -0,0 +1,247 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// DefaultConcurrency is the number of tables ApplyManifest creates in
+// parallel when ApplyOptions.Concurrency is not set.
+const DefaultConcurrency = 4
+
+// ApplyOptions controls how ApplyManifest creates the tables of a manifest.
+type ApplyOptions struct {
+	// ServiceAccount is the path to a service account credentials file.
+	// When empty, Application Default Credentials are used.
+	ServiceAccount string
+	// Concurrency bounds the number of tables created in parallel.
+	Concurrency int
+	// ContinueOnError keeps applying the remaining entries after a table
+	// fails. Otherwise entries not yet started are skipped.
+	ContinueOnError bool
+}
+
+// ResultStatus is the outcome of applying a single manifest entry.
+type ResultStatus string
+
+const (
+	// StatusCreated means the table was created.
+	StatusCreated ResultStatus = "created"
+	// StatusExists means the table already existed, was created from the
+	// same schema version and was left untouched.
+	StatusExists ResultStatus = "exists"
+	// StatusOutdated means the table already existed but its fingerprint
+	// labels do not match the schema (see SchemaUpToDate). The table is
+	// left untouched.
+	StatusOutdated ResultStatus = "outdated"
+	// StatusFailed means the table could not be created.
+	StatusFailed ResultStatus = "failed"
+	// StatusSkipped means the entry was not applied because an earlier
+	// entry failed and ContinueOnError was not set.
+	StatusSkipped ResultStatus = "skipped"
+)
+
+// TableResult is the result of applying a single manifest entry.
+type TableResult struct {
+	Entry    ManifestEntry
+	Status   ResultStatus
+	Err      error
+	Duration time.Duration
+}
+
+// ApplyReport holds the per-table results of ApplyManifest in manifest
+// order.
+type ApplyReport struct {
+	Results []TableResult
+}
+
+// Count returns the number of results with the given status.
+func (r *ApplyReport) Count(status ResultStatus) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteSummary writes one line per table followed by a summary line with
+// the number of tables per status.
+func (r *ApplyReport) WriteSummary(w io.Writer) error {
+	for _, res := range r.Results {
+		var err error
+		switch res.Status {
+		case StatusFailed:
+			_, err = fmt.Fprintf(w, "%-8s %s: %v\n", res.Status, res.Entry, res.Err)
+		case StatusSkipped:
+			_, err = fmt.Fprintf(w, "%-8s %s\n", res.Status, res.Entry)
+		default:
+			_, err = fmt.Fprintf(w, "%-8s %s (%s)\n", res.Status, res.Entry, res.Duration.Round(time.Millisecond))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d tables: %d created, %d exists, %d outdated, %d failed, %d skipped\n",
+		len(r.Results), r.Count(StatusCreated), r.Count(StatusExists), r.Count(StatusOutdated),
+		r.Count(StatusFailed), r.Count(StatusSkipped))
+	return err
+}
+
+// ApplyManifest creates every table of the manifest, running at most
+// opts.Concurrency creations at a time. Tables that already exist are
+// reported with StatusExists or, when their fingerprint labels do not
+// match the schema, StatusOutdated. The returned report always contains one
+// result per entry; a non-nil error indicates that at least one table
+// failed.
+func ApplyManifest(ctx context.Context, m *Manifest, opts ApplyOptions) (*ApplyReport, error) {
+	clients := newClientPool(opts.ServiceAccount)
+	defer clients.close()
+
+	return applyManifest(ctx, m, opts, func(ctx context.Context, e ManifestEntry) (ResultStatus, error) {
+		client, err := clients.get(ctx, e.Project)
+		if err != nil {
+			return StatusFailed, err
+		}
+		avroSchema, err := ReadAvroSchemaFile(e.Schema)
+		if err != nil {
+			return StatusFailed, err
+		}
+		err = createTable(ctx, client, e.Dataset, e.Table, avroSchema, &e.Options)
+		if !isAlreadyExists(err) {
+			return StatusCreated, err
+		}
+		upToDate, err := tableUpToDate(ctx, client, e.Dataset, e.Table, avroSchema)
+		if err != nil {
+			return StatusFailed, err
+		}
+		if !upToDate {
+			return StatusOutdated, nil
+		}
+		return StatusExists, nil
+	})
+}
+
+// createFunc creates the table described by a manifest entry and reports
+// the resulting status. The status is ignored when the error is non-nil.
+type createFunc func(ctx context.Context, e ManifestEntry) (ResultStatus, error)
+
+func applyManifest(ctx context.Context, m *Manifest, opts ApplyOptions, create createFunc) (*ApplyReport, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	report := &ApplyReport{Results: make([]TableResult, len(m.Tables))}
+	sem := make(chan struct{}, concurrency)
+	stopped := make(chan struct{})
+	var stopOnce sync.Once
+	var wg sync.WaitGroup
+
+	for i, e := range m.Tables {
+		report.Results[i] = TableResult{Entry: e, Status: StatusSkipped}
+
+		select {
+		case sem <- struct{}{}:
+		case <-stopped:
+			continue
+		}
+		select {
+		case <-stopped:
+			<-sem
+			continue
+		default:
+		}
+
+		wg.Add(1)
+		go func(i int, e ManifestEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			status, err := create(ctx, e)
+			res := TableResult{Entry: e, Status: status, Duration: time.Since(start)}
+			if err != nil {
+				res.Status = StatusFailed
+				res.Err = err
+				if !opts.ContinueOnError {
+					stopOnce.Do(func() { close(stopped) })
+				}
+			}
+			report.Results[i] = res
+		}(i, e)
+	}
+	wg.Wait()
+
+	if failed := report.Count(StatusFailed); failed > 0 {
+		return report, fmt.Errorf("%d of %d tables failed", failed, len(report.Results))
+	}
+	return report, nil
+}
+
+// isAlreadyExists reports whether err is the BigQuery API error returned
+// when creating a table that already exists.
+func isAlreadyExists(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict
+}
+
+// clientPool lazily creates and caches one BigQuery client per project.
+// Clients are created outside the lock, once per project, so that a slow
+// client creation does not hold up tables of other projects.
+type clientPool struct {
+	serviceAccount string
+
+	mu      sync.Mutex
+	clients map[string]*pooledClient
+}
+
+// pooledClient is the client of one project, created by the first get.
+type pooledClient struct {
+	once   sync.Once
+	client *bigquery.Client
+	err    error
+}
+
+func newClientPool(serviceAccount string) *clientPool {
+	return &clientPool{serviceAccount: serviceAccount, clients: make(map[string]*pooledClient)}
+}
+
+func (p *clientPool) get(ctx context.Context, projectID string) (*bigquery.Client, error) {
+	p.mu.Lock()
+	c, ok := p.clients[projectID]
+	if !ok {
+		c = &pooledClient{}
+		p.clients[projectID] = c
+	}
+	p.mu.Unlock()
+
+	c.once.Do(func() {
+		var clientOpts []option.ClientOption
+		if p.serviceAccount != "" {
+			clientOpts = append(clientOpts, option.WithCredentialsFile(p.serviceAccount))
+		}
+		c.client, c.err = bigquery.NewClient(ctx, projectID, clientOpts...)
+	})
+	return c.client, c.err
+}
+
+func (p *clientPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.clients {
+		if c.client != nil {
+			c.client.Close()
+		}
+	}
+}
@@ -7,8 +7,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"path/filepath"
+	"strings"
 
 	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/idl"
+	"github.com/go-syar/avro-schema-bq/ocf"
 	"github.com/go-syar/avro-schema-bq/schema"
 	"google.golang.org/api/option"
 )
@@ -26,32 +30,76 @@ func CreateBQTableWithSA(projectID, datasetID, tableID, serviceAccount, schemaFi
 	}
 	defer client.Close()
 
-	// Read the contents of the Avro schema file from the specified path (schemaFilePath).
-	avroSchemaContent, err := ioutil.ReadFile(schemaFilePath)
+	avroSchema, err := ReadAvroSchemaFile(schemaFilePath)
 	if err != nil {
-		fmt.Println("Error reading Avro schema file:", err)
-		return err
-	}
-
-	var avroSchema map[string]interface{}
-	// Unmarshal the Avro schema content into a map structure (avroSchema map[string]interface{}).
-	err = json.Unmarshal(avroSchemaContent, &avroSchema)
-	if err != nil {
-		fmt.Println("Error parsing Avro schema:", err)
 		return err
 	}
+	return createTable(ctx, client, datasetID, tableID, avroSchema, nil)
+}
 
+// createTable converts avroSchema to a BigQuery schema and creates the
+// table datasetID.tableID with it. Any non-nil opts are applied to the
+// table metadata before creation, and the table is labeled with the
+// schema's fingerprint and full name (see SchemaLabels).
+func createTable(ctx context.Context, client *bigquery.Client, datasetID, tableID string, avroSchema map[string]interface{}, opts *TableOptions) error {
 	// Convert the Avro schema (avroSchema map[string]interface{}) to BigQuery schema format (bqFields []*bigquery.FieldSchema).
 	bqFields, err := schema.ConvertAvroToBigQuery(avroSchema)
 	if err != nil {
-		fmt.Println("Error:", err)
-		return err
+		return fmt.Errorf("%s.%s: %w", datasetID, tableID, err)
 	}
 
 	// Create BigQuery table metadata (metadata) with the converted schema (bqFields bigquery.Schema).
 	metadata := &bigquery.TableMetadata{
 		Schema: bqFields,
 	}
+	return createTableWithMetadata(ctx, client, datasetID, tableID, avroSchema, metadata, opts)
+}
+
+// CreateTableWithOptions converts avroSchema with convertOpts and creates
+// the table datasetID.tableID with the resulting schema, applying
+// tableOpts. When convertOpts.Classify flags fields, the table is also
+// labeled with schema.ClassificationLabels; labels in tableOpts take
+// precedence. Normalization, which produces several tables, is not
+// supported; use CreateNormalizedTables. It returns the conversion, whose
+// classifications report the flagged fields.
+func CreateTableWithOptions(ctx context.Context, client *bigquery.Client, datasetID, tableID string, avroSchema map[string]interface{}, convertOpts schema.ConvertOptions, tableOpts *TableOptions) (*schema.Conversion, error) {
+	if convertOpts.Normalize != nil {
+		return nil, fmt.Errorf("normalization creates several tables, use CreateNormalizedTables")
+	}
+	conversion, err := schema.ConvertAvroToBigQueryWithOptions(avroSchema, convertOpts)
+	if err != nil {
+		return nil, err
+	}
+	var opts TableOptions
+	if tableOpts != nil {
+		opts = *tableOpts
+	}
+	opts = opts.merge(TableOptions{Labels: schema.ClassificationLabels(conversion.Classifications)})
+	metadata := &bigquery.TableMetadata{
+		Schema: conversion.Schema,
+	}
+	if err := createTableWithMetadata(ctx, client, datasetID, tableID, avroSchema, metadata, &opts); err != nil {
+		return nil, err
+	}
+	return conversion, nil
+}
+
+// createTableWithMetadata creates the table datasetID.tableID from
+// metadata, applying opts and stamping the labels identifying avroSchema.
+func createTableWithMetadata(ctx context.Context, client *bigquery.Client, datasetID, tableID string, avroSchema map[string]interface{}, metadata *bigquery.TableMetadata, opts *TableOptions) error {
+	opts.apply(metadata)
+
+	// Stamp the schema identity onto the table so later runs can tell whether it is up to date.
+	schemaLabels, err := SchemaLabels(avroSchema)
+	if err != nil {
+		return err
+	}
+	if metadata.Labels == nil {
+		metadata.Labels = make(map[string]string, len(schemaLabels))
+	}
+	for k, v := range schemaLabels {
+		metadata.Labels[k] = v
+	}
 
 	// Create a reference to the BigQuery table using the specified dataset (datasetID) and table ID (tableID).
 	tableRef := client.Dataset(datasetID).Table(tableID)
@@ -61,5 +109,43 @@ func CreateBQTableWithSA(projectID, datasetID, tableID, serviceAccount, schemaFi
 	}
 
 	return nil
+}
+
+// ReadAvroSchemaFile reads and unmarshals the Avro schema (.avsc) file at
+// schemaFilePath into the map representation used by the schema package.
+// An Avro data file (.avro) is accepted as well; its writer schema is
+// read from the file header. So is an Avro IDL file (.avdl), whose main
+// schema is used.
+func ReadAvroSchemaFile(schemaFilePath string) (map[string]interface{}, error) {
+	if strings.EqualFold(filepath.Ext(schemaFilePath), ".avdl") {
+		return idl.LoadSchema(schemaFilePath)
+	}
+	isContainer, err := ocf.IsContainerFile(schemaFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", schemaFilePath, err)
+	}
+	if isContainer {
+		header, err := ocf.ReadFileHeader(schemaFilePath)
+		if err != nil {
+			return nil, err
+		}
+		avroSchema, err := header.Schema()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", schemaFilePath, err)
+		}
+		return avroSchema, nil
+	}
+
+	// Read the contents of the Avro schema file from the specified path (schemaFilePath).
+	avroSchemaContent, err := ioutil.ReadFile(schemaFilePath)
+	if err != nil {
+		return nil, err
+	}
 
+	var avroSchema map[string]interface{}
+	// Unmarshal the Avro schema content into a map structure (avroSchema map[string]interface{}).
+	if err := json.Unmarshal(avroSchemaContent, &avroSchema); err != nil {
+		return nil, fmt.Errorf("%s: %w", schemaFilePath, err)
+	}
+	return avroSchema, nil
 }
@@ -0,0 +1,159 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// DetectDrift fetches the live schema of datasetID.tableID, converts
+// avroSchema to a BigQuery schema and returns the differences between the
+// two. Columns present in the table but not in the Avro schema are
+// reported as schema.DiffExtraColumn.
+func DetectDrift(ctx context.Context, client *bigquery.Client, datasetID, tableID string, avroSchema map[string]interface{}) ([]schema.SchemaDifference, error) {
+	expected, err := schema.ConvertAvroToBigQuery(avroSchema)
+	if err != nil {
+		return nil, err
+	}
+	md, err := client.Dataset(datasetID).Table(tableID).Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return schema.DiffSchemas(expected, md.Schema), nil
+}
+
+// DriftResult is the drift detected for a single manifest entry. Err is set
+// when the table could not be checked.
+type DriftResult struct {
+	Entry       ManifestEntry
+	Differences []schema.SchemaDifference
+	Err         error
+}
+
+// DriftReport holds the drift results of every manifest entry in manifest
+// order.
+type DriftReport struct {
+	Results []DriftResult
+}
+
+// HasDrift reports whether any table differs from its Avro schema.
+func (r *DriftReport) HasDrift() bool {
+	for _, res := range r.Results {
+		if len(res.Differences) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasErrors reports whether any table could not be checked.
+func (r *DriftReport) HasErrors() bool {
+	for _, res := range r.Results {
+		if res.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes a human readable report listing the differences of
+// every table.
+func (r *DriftReport) WriteText(w io.Writer) error {
+	drifted := 0
+	for _, res := range r.Results {
+		var err error
+		switch {
+		case res.Err != nil:
+			_, err = fmt.Fprintf(w, "%s: error: %v\n", res.Entry, res.Err)
+		case len(res.Differences) == 0:
+			_, err = fmt.Fprintf(w, "%s: no drift\n", res.Entry)
+		default:
+			drifted++
+			_, err = fmt.Fprintf(w, "%s: %d differences\n", res.Entry, len(res.Differences))
+			for _, d := range res.Differences {
+				if err == nil {
+					_, err = fmt.Fprintf(w, "  %s\n", d)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d tables checked, %d drifted\n", len(r.Results), drifted)
+	return err
+}
+
+// WriteJSON writes the report as a JSON array with one object per table.
+func (r *DriftReport) WriteJSON(w io.Writer) error {
+	type jsonResult struct {
+		Table       string                    `json:"table"`
+		Differences []schema.SchemaDifference `json:"differences"`
+		Error       string                    `json:"error,omitempty"`
+	}
+	out := make([]jsonResult, 0, len(r.Results))
+	for _, res := range r.Results {
+		jr := jsonResult{Table: res.Entry.String(), Differences: res.Differences}
+		if jr.Differences == nil {
+			jr.Differences = []schema.SchemaDifference{}
+		}
+		if res.Err != nil {
+			jr.Error = res.Err.Error()
+		}
+		out = append(out, jr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(out)
+}
+
+// DetectManifestDrift runs DetectDrift for every entry of the manifest,
+// checking at most opts.Concurrency tables at a time. Errors are recorded
+// per table; every entry is checked regardless of opts.ContinueOnError.
+func DetectManifestDrift(ctx context.Context, m *Manifest, opts ApplyOptions) *DriftReport {
+	clients := newClientPool(opts.ServiceAccount)
+	defer clients.close()
+
+	return detectManifestDrift(ctx, m, opts.Concurrency, func(ctx context.Context, e ManifestEntry) ([]schema.SchemaDifference, error) {
+		client, err := clients.get(ctx, e.Project)
+		if err != nil {
+			return nil, err
+		}
+		avroSchema, err := ReadAvroSchemaFile(e.Schema)
+		if err != nil {
+			return nil, err
+		}
+		return DetectDrift(ctx, client, e.Dataset, e.Table, avroSchema)
+	})
+}
+
+// driftFunc detects the drift of the table described by a manifest entry.
+type driftFunc func(ctx context.Context, e ManifestEntry) ([]schema.SchemaDifference, error)
+
+func detectManifestDrift(ctx context.Context, m *Manifest, concurrency int, detect driftFunc) *DriftReport {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	report := &DriftReport{Results: make([]DriftResult, len(m.Tables))}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, e := range m.Tables {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, e ManifestEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			diffs, err := detect(ctx, e)
+			report.Results[i] = DriftResult{Entry: e, Differences: diffs, Err: err}
+		}(i, e)
+	}
+	wg.Wait()
+	return report
+}
@@ -0,0 +1,59 @@
+package table
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+func TestDetectManifestDrift(t *testing.T) {
+	m := &Manifest{Tables: []ManifestEntry{
+		{Project: "p", Dataset: "d", Table: "clean", Schema: "clean.avsc"},
+		{Project: "p", Dataset: "d", Table: "drifted", Schema: "drifted.avsc"},
+		{Project: "p", Dataset: "d", Table: "broken", Schema: "broken.avsc"},
+	}}
+	report := detectManifestDrift(context.Background(), m, 2, func(ctx context.Context, e ManifestEntry) ([]schema.SchemaDifference, error) {
+		switch e.Table {
+		case "drifted":
+			return []schema.SchemaDifference{{Path: "note", Kind: schema.DiffExtraColumn, Actual: "STRING NULLABLE"}}, nil
+		case "broken":
+			return nil, errors.New("not found")
+		}
+		return nil, nil
+	})
+
+	if !report.HasDrift() || !report.HasErrors() {
+		t.Fatalf("Expected drift and errors in report: %+v", report.Results)
+	}
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatalf("Error writing text report: %v", err)
+	}
+	for _, want := range []string{"p.d.clean: no drift", "  note: extra column (STRING NULLABLE)", "p.d.broken: error: not found", "3 tables checked, 1 drifted"} {
+		if !strings.Contains(text.String(), want) {
+			t.Fatalf("Expected text report to contain %q:\n%s", want, text.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("Error writing JSON report: %v", err)
+	}
+	var decoded []struct {
+		Table       string                    `json:"table"`
+		Differences []schema.SchemaDifference `json:"differences"`
+		Error       string                    `json:"error"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Error decoding JSON report: %v", err)
+	}
+	if len(decoded) != 3 || len(decoded[1].Differences) != 1 || decoded[2].Error != "not found" {
+		t.Fatalf("Unexpected JSON report: %s", buf.String())
+	}
+}
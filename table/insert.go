@@ -0,0 +1,117 @@
+package table
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/ocf"
+	"github.com/go-syar/avro-schema-bq/row"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// DefaultInsertBatchSize is the number of rows InsertContainerFile and
+// InsertJSONFile send per streaming insert request when no batch size is
+// given.
+const DefaultInsertBatchSize = 500
+
+// InsertContainerFile streams the records of the Avro Object Container
+// File at path into the existing table datasetID.tableID, batchSize rows
+// per request. Records are decoded into rows of the live table schema, so
+// opts.Convert must name fields the way the table was created; opts.Schema
+// is ignored. Without opts.SkipCorrupt, a corrupt block stops the insert
+// after the rows read before it. It returns the number of rows inserted
+// and the corrupt blocks skipped with opts.SkipCorrupt.
+func InsertContainerFile(ctx context.Context, client *bigquery.Client, datasetID, tableID, path string, opts ocf.ReaderOptions, batchSize int) (int, []*ocf.CorruptBlockError, error) {
+	t := client.Dataset(datasetID).Table(tableID)
+	md, err := t.Metadata(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	opts.Schema = md.Schema
+	r, err := ocf.OpenFile(path, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer r.Close()
+	inserted, err := insertRows(ctx, t, md.Schema, r.Read, batchSize)
+	return inserted, r.Corruptions(), err
+}
+
+// InsertJSONFile streams the records in the Avro JSON encoding of the
+// file at path, such as newline-delimited JSON, into the existing table
+// datasetID.tableID, batchSize rows per request. Records are written with
+// avroSchema and decoded into rows of the live table schema, so
+// convertOpts must name fields the way the table was created. A record
+// that does not decode stops the insert after the rows read before it. It
+// returns the number of rows inserted.
+func InsertJSONFile(ctx context.Context, client *bigquery.Client, datasetID, tableID, path string, avroSchema map[string]interface{}, convertOpts schema.ConvertOptions, batchSize int) (int, error) {
+	t := client.Dataset(datasetID).Table(tableID)
+	md, err := t.Metadata(ctx)
+	if err != nil {
+		return 0, err
+	}
+	conversion, err := schema.ConvertAvroToBigQueryWithOptions(avroSchema, convertOpts)
+	if err != nil {
+		return 0, err
+	}
+	d, err := row.NewDecoder(conversion.AvroSchema, md.Schema)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	inserted, err := insertRows(ctx, t, md.Schema, row.NewJSONReader(bufio.NewReader(f), d).Read, batchSize)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", path, err)
+	}
+	return inserted, err
+}
+
+// insertRows streams the rows of s returned by read, until io.EOF, into t
+// in batches of batchSize rows. On a read error, the rows read before it
+// are inserted, as the batches sent already are.
+func insertRows(ctx context.Context, t *bigquery.Table, s bigquery.Schema, read func() (row.Row, error), batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultInsertBatchSize
+	}
+	inserter := t.Inserter()
+	inserted := 0
+	batch := make([]bigquery.ValueSaver, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := inserter.Put(ctx, batch); err != nil {
+			return err
+		}
+		inserted += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for {
+		rw, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ferr := flush(); ferr != nil {
+				return inserted, ferr
+			}
+			return inserted, err
+		}
+		batch = append(batch, row.Saver(rw, s))
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return inserted, err
+			}
+		}
+	}
+	return inserted, flush()
+}
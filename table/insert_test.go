@@ -0,0 +1,91 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+	"google.golang.org/api/option"
+)
+
+func TestInsertJSONFileEncodesDecimals(t *testing.T) {
+	var payload struct {
+		Rows []struct {
+			JSON map[string]interface{} `json:"json"`
+		} `json:"rows"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/projects/p/datasets/d/tables/t"):
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"tableReference": map[string]string{"projectId": "p", "datasetId": "d", "tableId": "t"},
+				"schema": map[string]interface{}{"fields": []map[string]interface{}{
+					{"name": "price", "type": "NUMERIC", "mode": "REQUIRED"},
+					{"name": "total", "type": "BIGNUMERIC", "mode": "REQUIRED"},
+					{"name": "history", "type": "NUMERIC", "mode": "REPEATED"},
+				}},
+			})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/projects/p/datasets/d/tables/t/insertAll"):
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Errorf("Invalid insert request %s: %v", body, err)
+			}
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := bigquery.NewClient(ctx, "p", option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+	decimal := func(precision, scale int) map[string]interface{} {
+		return map[string]interface{}{"type": "bytes", "logicalType": "decimal", "precision": float64(precision), "scale": float64(scale)}
+	}
+	avroSchema := map[string]interface{}{
+		"type": "record",
+		"name": "Sale",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "price", "type": decimal(5, 2)},
+			map[string]interface{}{"name": "total", "type": decimal(40, 10)},
+			map[string]interface{}{"name": "history", "type": map[string]interface{}{"type": "array", "items": decimal(5, 2)}},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "sales.json")
+	// 12.34 and 1.5 as big-endian two's complement bytes, in the ISO-8859-1
+	// characters of the Avro JSON encoding.
+	record := `{"price": "\u0004Ò", "total": "\u0003~\u0011Ö\u0000", "history": ["\u0000\u0096"]}`
+	if err := os.WriteFile(path, []byte(record+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, _ := schema.ProfileByName(schema.ProfilePubSubSubscription)
+	inserted, err := InsertJSONFile(ctx, client, "d", "t", path, avroSchema, schema.ConvertOptions{Profile: profile}, 0)
+	if err != nil || inserted != 1 {
+		t.Fatalf("Expected 1 row inserted, but got %d (%v)", inserted, err)
+	}
+	if len(payload.Rows) != 1 {
+		t.Fatalf("Expected 1 row in the insert request, but got %d", len(payload.Rows))
+	}
+	got := payload.Rows[0].JSON
+	if got["price"] != "12.340000000" {
+		t.Fatalf("Expected price as a NUMERIC string, but got %#v", got["price"])
+	}
+	if got["total"] != "1.50000000000000000000000000000000000000" {
+		t.Fatalf("Expected total as a BIGNUMERIC string, but got %#v", got["total"])
+	}
+	if history, ok := got["history"].([]interface{}); !ok || len(history) != 1 || history[0] != "1.500000000" {
+		t.Fatalf("Expected history as NUMERIC strings, but got %#v", got["history"])
+	}
+}
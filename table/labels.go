@@ -0,0 +1,108 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+const (
+	// LabelSchemaFingerprint is the table label holding the hex encoded
+	// CRC-64-AVRO fingerprint of the Avro schema the table was created from.
+	LabelSchemaFingerprint = "avro_fingerprint"
+	// LabelSchemaContentFingerprint is the table label holding the hex
+	// encoded CRC-64-AVRO fingerprint of the full JSON of the Avro schema,
+	// which unlike the Parsing Canonical Form keeps logical types, docs,
+	// defaults and custom properties such as bq.* annotations, all of
+	// which change the converted table.
+	LabelSchemaContentFingerprint = "avro_content_fingerprint"
+	// LabelSchemaName is the table label holding the full name of the Avro
+	// schema the table was created from, in label form.
+	LabelSchemaName = "avro_schema"
+)
+
+// maxLabelLength is the maximum length of a BigQuery label value.
+const maxLabelLength = 63
+
+// SchemaLabels returns the labels identifying avroSchema that are stamped
+// onto every table created by this package.
+func SchemaLabels(avroSchema map[string]interface{}) (map[string]string, error) {
+	fp, err := schema.Fingerprint(avroSchema)
+	if err != nil {
+		return nil, err
+	}
+	content, err := contentFingerprint(avroSchema)
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{
+		LabelSchemaFingerprint:        formatFingerprint(fp),
+		LabelSchemaContentFingerprint: formatFingerprint(content),
+	}
+	if name := schema.SchemaFullName(avroSchema); name != "" {
+		labels[LabelSchemaName] = labelValue(name)
+	}
+	return labels, nil
+}
+
+// SchemaUpToDate reports whether the table metadata carries the fingerprint
+// labels of avroSchema, i.e. whether the table was created from the same
+// version of the schema. Both fingerprints must match, so that a change
+// of logical type, doc or annotation, which the Parsing Canonical Form
+// drops, makes the table outdated.
+func SchemaUpToDate(md *bigquery.TableMetadata, avroSchema map[string]interface{}) (bool, error) {
+	labels, err := SchemaLabels(avroSchema)
+	if err != nil {
+		return false, err
+	}
+	return md.Labels[LabelSchemaFingerprint] == labels[LabelSchemaFingerprint] &&
+		md.Labels[LabelSchemaContentFingerprint] == labels[LabelSchemaContentFingerprint], nil
+}
+
+// contentFingerprint returns the CRC-64-AVRO fingerprint of the JSON of
+// avroSchema, whose object keys encoding/json writes sorted, so that the
+// fingerprint does not depend on formatting or key order.
+func contentFingerprint(avroSchema map[string]interface{}) (uint64, error) {
+	data, err := json.Marshal(avroSchema)
+	if err != nil {
+		return 0, err
+	}
+	return schema.FingerprintCRC64(data), nil
+}
+
+// tableUpToDate fetches the metadata of datasetID.tableID and compares its
+// fingerprint label with avroSchema.
+func tableUpToDate(ctx context.Context, client *bigquery.Client, datasetID, tableID string, avroSchema map[string]interface{}) (bool, error) {
+	md, err := client.Dataset(datasetID).Table(tableID).Metadata(ctx)
+	if err != nil {
+		return false, err
+	}
+	return SchemaUpToDate(md, avroSchema)
+}
+
+func formatFingerprint(fp uint64) string {
+	return fmt.Sprintf("%016x", fp)
+}
+
+// labelValue turns s into a valid label value: lowercase letters, digits,
+// underscores and dashes only, at most 63 characters long.
+func labelValue(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	v := b.String()
+	if len(v) > maxLabelLength {
+		v = v[:maxLabelLength]
+	}
+	return v
+}
@@ -0,0 +1,48 @@
+package table
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestSchemaLabels(t *testing.T) {
+	avroSchema := map[string]interface{}{
+		"type":      "record",
+		"name":      "User",
+		"namespace": "com.Example.avro",
+		"fields": []interface{}{
+			map[string]interface{}{"name": "id", "type": "long"},
+		},
+	}
+
+	labels, err := SchemaLabels(avroSchema)
+	if err != nil {
+		t.Fatalf("Error computing schema labels: %v", err)
+	}
+	if labels[LabelSchemaName] != "com_example_avro_user" {
+		t.Fatalf("Expected schema name label com_example_avro_user, but got %q", labels[LabelSchemaName])
+	}
+	if len(labels[LabelSchemaFingerprint]) != 16 {
+		t.Fatalf("Expected a 16 character fingerprint label, but got %q", labels[LabelSchemaFingerprint])
+	}
+
+	upToDate, err := SchemaUpToDate(&bigquery.TableMetadata{Labels: labels}, avroSchema)
+	if err != nil || !upToDate {
+		t.Fatalf("Expected table to be up to date, got %v (%v)", upToDate, err)
+	}
+
+	avroSchema["fields"] = []interface{}{
+		map[string]interface{}{"name": "id", "type": map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}},
+	}
+	upToDate, err = SchemaUpToDate(&bigquery.TableMetadata{Labels: labels}, avroSchema)
+	if err != nil || upToDate {
+		t.Fatalf("Expected table to be outdated after a logical type change, got %v (%v)", upToDate, err)
+	}
+
+	avroSchema["fields"] = append(avroSchema["fields"].([]interface{}), map[string]interface{}{"name": "email", "type": "string"})
+	upToDate, err = SchemaUpToDate(&bigquery.TableMetadata{Labels: labels}, avroSchema)
+	if err != nil || upToDate {
+		t.Fatalf("Expected table to be outdated after a schema change, got %v (%v)", upToDate, err)
+	}
+}
@@ -0,0 +1,235 @@
+package table
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest describes a set of BigQuery tables to create from Avro schema
+// files. Values in Defaults are used for any entry that leaves the
+// corresponding field empty.
+//
+// A manifest can be written in YAML or JSON:
+//
+//	defaults:
+//	  project: my-project
+//	  dataset: events
+//	  options:
+//	    labels: {team: data}
+//	tables:
+//	  - table: orders
+//	    schema: schemas/orders.avsc
+//	    options:
+//	      partitionField: created_at
+//	      clustering: [customer_id]
+type Manifest struct {
+	Defaults ManifestDefaults `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	Tables   []ManifestEntry  `json:"tables" yaml:"tables"`
+}
+
+// ManifestDefaults holds the values shared by every entry of a Manifest.
+type ManifestDefaults struct {
+	Project string       `json:"project,omitempty" yaml:"project,omitempty"`
+	Dataset string       `json:"dataset,omitempty" yaml:"dataset,omitempty"`
+	Options TableOptions `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// ManifestEntry describes a single table of a Manifest. Schema is the path
+// to the Avro schema (.avsc) file; relative paths are resolved against the
+// directory of the manifest file.
+type ManifestEntry struct {
+	Project string       `json:"project,omitempty" yaml:"project,omitempty"`
+	Dataset string       `json:"dataset,omitempty" yaml:"dataset,omitempty"`
+	Table   string       `json:"table" yaml:"table"`
+	Schema  string       `json:"schema" yaml:"schema"`
+	Options TableOptions `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// String returns the fully qualified table name of the entry.
+func (e ManifestEntry) String() string {
+	return e.Project + "." + e.Dataset + "." + e.Table
+}
+
+// TableOptions holds the table settings that can be configured per
+// manifest entry in addition to the schema.
+type TableOptions struct {
+	// Description is a pointer so that an entry can clear the description
+	// of the defaults with an empty one.
+	Description *string           `json:"description,omitempty" yaml:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// PartitionType is one of DAY, HOUR, MONTH or YEAR. It defaults to DAY
+	// when PartitionField is set.
+	PartitionType           string `json:"partitionType,omitempty" yaml:"partitionType,omitempty"`
+	PartitionField          string `json:"partitionField,omitempty" yaml:"partitionField,omitempty"`
+	PartitionExpirationDays int    `json:"partitionExpirationDays,omitempty" yaml:"partitionExpirationDays,omitempty"`
+	// RequirePartitionFilter is a pointer so that an entry can turn off a
+	// filter required by the defaults.
+	RequirePartitionFilter *bool `json:"requirePartitionFilter,omitempty" yaml:"requirePartitionFilter,omitempty"`
+	// Clustering is inherited from the defaults when nil; an empty list
+	// turns clustering off.
+	Clustering []string `json:"clustering,omitempty" yaml:"clustering,omitempty"`
+}
+
+// merge returns o with every unset value taken from defaults. Labels are
+// combined, with the entry's own labels taking precedence.
+func (o TableOptions) merge(defaults TableOptions) TableOptions {
+	if o.Description == nil {
+		o.Description = defaults.Description
+	}
+	if len(defaults.Labels) > 0 {
+		labels := make(map[string]string, len(defaults.Labels)+len(o.Labels))
+		for k, v := range defaults.Labels {
+			labels[k] = v
+		}
+		for k, v := range o.Labels {
+			labels[k] = v
+		}
+		o.Labels = labels
+	}
+	if o.PartitionType == "" {
+		o.PartitionType = defaults.PartitionType
+	}
+	if o.PartitionField == "" {
+		o.PartitionField = defaults.PartitionField
+	}
+	if o.PartitionExpirationDays == 0 {
+		o.PartitionExpirationDays = defaults.PartitionExpirationDays
+	}
+	if o.RequirePartitionFilter == nil {
+		o.RequirePartitionFilter = defaults.RequirePartitionFilter
+	}
+	if o.Clustering == nil {
+		o.Clustering = defaults.Clustering
+	}
+	return o
+}
+
+// apply copies the options onto the table metadata. A nil receiver leaves
+// the metadata unchanged.
+func (o *TableOptions) apply(md *bigquery.TableMetadata) {
+	if o == nil {
+		return
+	}
+	if o.Description != nil {
+		md.Description = *o.Description
+	}
+	if len(o.Labels) > 0 {
+		md.Labels = make(map[string]string, len(o.Labels))
+		for k, v := range o.Labels {
+			md.Labels[k] = v
+		}
+	}
+	if o.PartitionField != "" || o.PartitionType != "" {
+		partitionType := bigquery.DayPartitioningType
+		if o.PartitionType != "" {
+			partitionType = bigquery.TimePartitioningType(strings.ToUpper(o.PartitionType))
+		}
+		md.TimePartitioning = &bigquery.TimePartitioning{
+			Type:       partitionType,
+			Field:      o.PartitionField,
+			Expiration: time.Duration(o.PartitionExpirationDays) * 24 * time.Hour,
+		}
+	}
+	md.RequirePartitionFilter = o.RequirePartitionFilter != nil && *o.RequirePartitionFilter
+	if len(o.Clustering) > 0 {
+		md.Clustering = &bigquery.Clustering{Fields: o.Clustering}
+	}
+}
+
+func (o TableOptions) validate() error {
+	switch strings.ToUpper(o.PartitionType) {
+	case "", "DAY", "HOUR", "MONTH", "YEAR":
+	default:
+		return fmt.Errorf("invalid partitionType %q", o.PartitionType)
+	}
+	if o.PartitionExpirationDays < 0 {
+		return fmt.Errorf("partitionExpirationDays must not be negative")
+	}
+	if len(o.Clustering) > 4 {
+		return fmt.Errorf("at most 4 clustering fields are allowed, got %d", len(o.Clustering))
+	}
+	return nil
+}
+
+// LoadManifest reads the manifest file at path. Files ending in ".json" are
+// parsed as JSON, anything else as YAML. Relative schema paths are resolved
+// against the directory containing the manifest.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	m, err := ParseManifest(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for i := range m.Tables {
+		if !filepath.IsAbs(m.Tables[i].Schema) {
+			m.Tables[i].Schema = filepath.Join(dir, m.Tables[i].Schema)
+		}
+	}
+	return m, nil
+}
+
+// ParseManifest parses manifest data in the given format ("json" or
+// "yaml"), applies the manifest defaults to every entry and validates the
+// result.
+func ParseManifest(data []byte, format string) (*Manifest, error) {
+	var m Manifest
+	switch strings.ToLower(format) {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported manifest format %q", format)
+	}
+
+	if len(m.Tables) == 0 {
+		return nil, fmt.Errorf("manifest contains no tables")
+	}
+	seen := make(map[string]int, len(m.Tables))
+	for i := range m.Tables {
+		e := &m.Tables[i]
+		if e.Project == "" {
+			e.Project = m.Defaults.Project
+		}
+		if e.Dataset == "" {
+			e.Dataset = m.Defaults.Dataset
+		}
+		e.Options = e.Options.merge(m.Defaults.Options)
+
+		if e.Project == "" || e.Dataset == "" || e.Table == "" || e.Schema == "" {
+			return nil, fmt.Errorf("tables[%d]: project, dataset, table and schema are required", i)
+		}
+		if err := e.Options.validate(); err != nil {
+			return nil, fmt.Errorf("tables[%d] (%s): %w", i, e, err)
+		}
+		if j, ok := seen[e.String()]; ok {
+			return nil, fmt.Errorf("tables[%d]: %s is already defined by tables[%d]", i, e, j)
+		}
+		seen[e.String()] = i
+	}
+	return &m, nil
+}
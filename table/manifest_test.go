@@ -0,0 +1,202 @@
+package table
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+const testManifestYAML = `
+defaults:
+  project: my-project
+  dataset: events
+  options:
+    labels: {team: data, env: prod}
+tables:
+  - table: orders
+    schema: schemas/orders.avsc
+    options:
+      partitionField: created_at
+      clustering: [customer_id]
+      labels: {env: dev}
+  - project: other-project
+    dataset: raw
+    table: payments
+    schema: /abs/payments.avsc
+`
+
+func TestParseManifest(t *testing.T) {
+	t.Run("yaml manifest applies defaults", func(t *testing.T) {
+		m, err := ParseManifest([]byte(testManifestYAML), "yaml")
+		if err != nil {
+			t.Fatalf("Error parsing manifest: %v", err)
+		}
+		if len(m.Tables) != 2 {
+			t.Fatalf("Expected 2 tables, but got %d", len(m.Tables))
+		}
+		orders := m.Tables[0]
+		if orders.String() != "my-project.events.orders" {
+			t.Fatalf("Expected my-project.events.orders, but got %s", orders)
+		}
+		if orders.Options.Labels["team"] != "data" || orders.Options.Labels["env"] != "dev" {
+			t.Fatalf("Unexpected labels: %v", orders.Options.Labels)
+		}
+		if orders.Options.PartitionField != "created_at" || len(orders.Options.Clustering) != 1 {
+			t.Fatalf("Unexpected options: %+v", orders.Options)
+		}
+		if m.Tables[1].String() != "other-project.raw.payments" {
+			t.Fatalf("Expected other-project.raw.payments, but got %s", m.Tables[1])
+		}
+	})
+
+	t.Run("json manifest", func(t *testing.T) {
+		data := `{"tables": [{"project": "p", "dataset": "d", "table": "t", "schema": "t.avsc",
+			"options": {"partitionType": "hour", "requirePartitionFilter": true}}]}`
+		m, err := ParseManifest([]byte(data), "json")
+		if err != nil {
+			t.Fatalf("Error parsing manifest: %v", err)
+		}
+		if filter := m.Tables[0].Options.RequirePartitionFilter; filter == nil || !*filter || m.Tables[0].Options.PartitionType != "hour" {
+			t.Fatalf("Unexpected options: %+v", m.Tables[0].Options)
+		}
+	})
+
+	t.Run("entry turns off a required partition filter", func(t *testing.T) {
+		data := `
+defaults:
+  project: p
+  dataset: d
+  options: {requirePartitionFilter: true}
+tables:
+  - {table: a, schema: a.avsc}
+  - {table: b, schema: b.avsc, options: {requirePartitionFilter: false}}
+`
+		m, err := ParseManifest([]byte(data), "yaml")
+		if err != nil {
+			t.Fatalf("Error parsing manifest: %v", err)
+		}
+		for i, want := range []bool{true, false} {
+			var md bigquery.TableMetadata
+			m.Tables[i].Options.apply(&md)
+			if md.RequirePartitionFilter != want {
+				t.Fatalf("Expected requirePartitionFilter %v for %s, but got %v", want, m.Tables[i].Table, md.RequirePartitionFilter)
+			}
+		}
+	})
+
+	t.Run("entry clears inherited description and clustering", func(t *testing.T) {
+		data := `
+defaults:
+  project: p
+  dataset: d
+  options: {description: Events, clustering: [customer_id]}
+tables:
+  - {table: a, schema: a.avsc}
+  - {table: b, schema: b.avsc, options: {description: "", clustering: []}}
+`
+		m, err := ParseManifest([]byte(data), "yaml")
+		if err != nil {
+			t.Fatalf("Error parsing manifest: %v", err)
+		}
+		var inherited, cleared bigquery.TableMetadata
+		m.Tables[0].Options.apply(&inherited)
+		m.Tables[1].Options.apply(&cleared)
+		if inherited.Description != "Events" || inherited.Clustering == nil {
+			t.Fatalf("Expected the description and clustering of the defaults, but got %+v", inherited)
+		}
+		if cleared.Description != "" || cleared.Clustering != nil {
+			t.Fatalf("Expected no description or clustering, but got %+v", cleared)
+		}
+	})
+
+	invalid := map[string]string{
+		"missing dataset":   `tables: [{project: p, table: t, schema: t.avsc}]`,
+		"no tables":         `tables: []`,
+		"unknown field":     `tables: [{project: p, dataset: d, table: t, schema: t.avsc, colour: red}]`,
+		"duplicate table":   `tables: [{project: p, dataset: d, table: t, schema: a.avsc}, {project: p, dataset: d, table: t, schema: b.avsc}]`,
+		"bad partitionType": `tables: [{project: p, dataset: d, table: t, schema: t.avsc, options: {partitionType: WEEK}}]`,
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseManifest([]byte(data), "yaml"); err == nil {
+				t.Fatalf("Expected an error for %s", name)
+			}
+		})
+	}
+}
+
+func TestApplyManifest(t *testing.T) {
+	m := &Manifest{}
+	for _, name := range []string{"a", "exists", "fail", "b", "outdated"} {
+		m.Tables = append(m.Tables, ManifestEntry{Project: "p", Dataset: "d", Table: name, Schema: name + ".avsc"})
+	}
+	create := func(ctx context.Context, e ManifestEntry) (ResultStatus, error) {
+		switch e.Table {
+		case "exists":
+			return StatusExists, nil
+		case "outdated":
+			return StatusOutdated, nil
+		case "fail":
+			return StatusFailed, errors.New("boom")
+		}
+		return StatusCreated, nil
+	}
+
+	t.Run("continue on error", func(t *testing.T) {
+		report, err := applyManifest(context.Background(), m, ApplyOptions{Concurrency: 2, ContinueOnError: true}, create)
+		if err == nil {
+			t.Fatalf("Expected an error when a table fails")
+		}
+		want := []ResultStatus{StatusCreated, StatusExists, StatusFailed, StatusCreated, StatusOutdated}
+		for i, res := range report.Results {
+			if res.Status != want[i] {
+				t.Fatalf("Result %d: Expected %s, but got %s", i, want[i], res.Status)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := report.WriteSummary(&buf); err != nil {
+			t.Fatalf("Error writing summary: %v", err)
+		}
+		if !strings.Contains(buf.String(), "5 tables: 2 created, 1 exists, 1 outdated, 1 failed, 0 skipped") {
+			t.Fatalf("Unexpected summary:\n%s", buf.String())
+		}
+	})
+
+	t.Run("stop on first error", func(t *testing.T) {
+		report, err := applyManifest(context.Background(), m, ApplyOptions{Concurrency: 1}, create)
+		if err == nil {
+			t.Fatalf("Expected an error when a table fails")
+		}
+		if report.Count(StatusFailed) != 1 || report.Count(StatusSkipped) != 2 {
+			t.Fatalf("Expected 1 failed and 2 skipped tables, but got %+v", report.Results)
+		}
+	})
+
+	t.Run("bounded concurrency", func(t *testing.T) {
+		var running, peak int32
+		_, err := applyManifest(context.Background(), m, ApplyOptions{Concurrency: 2, ContinueOnError: true},
+			func(ctx context.Context, e ManifestEntry) (ResultStatus, error) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				atomic.AddInt32(&running, -1)
+				return StatusCreated, nil
+			})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if peak > 2 {
+			t.Fatalf("Expected at most 2 concurrent creations, but got %d", peak)
+		}
+	})
+}
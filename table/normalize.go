@@ -0,0 +1,72 @@
+package table
+
+import (
+	"context"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// CreateNormalizedTables converts avroSchema with normalization, moving
+// every array of records into a child table, and creates the root table
+// opts.RootTable and all child tables in datasetID. The root table gets a
+// primary key constraint and every child table a primary key and a foreign
+// key referencing its parent; BigQuery does not enforce either. tableOpts
+// apply to the root table; child tables, which lack the partitioning and
+// clustering columns, only get its description and labels. Tables are
+// created parents first and the function stops at the first error. It
+// returns the created table schemas.
+func CreateNormalizedTables(ctx context.Context, client *bigquery.Client, datasetID string, avroSchema map[string]interface{}, opts schema.NormalizeOptions, tableOpts *TableOptions) ([]schema.TableSchema, error) {
+	conversion, err := schema.ConvertAvroToBigQueryWithOptions(avroSchema, schema.ConvertOptions{Normalize: &opts})
+	if err != nil {
+		return nil, err
+	}
+
+	dataset := client.Dataset(datasetID)
+	for _, t := range conversion.Tables {
+		metadata := &bigquery.TableMetadata{
+			Schema:           t.Schema,
+			TableConstraints: tableConstraints(dataset, t),
+		}
+		opts := tableOpts
+		if t.Parent != "" {
+			opts = childTableOptions(tableOpts)
+		}
+		if err := createTableWithMetadata(ctx, client, datasetID, t.Name, avroSchema, metadata, opts); err != nil {
+			return nil, err
+		}
+	}
+	return conversion.Tables, nil
+}
+
+// childTableOptions returns the options of the root table that apply to
+// its child tables.
+func childTableOptions(o *TableOptions) *TableOptions {
+	if o == nil {
+		return nil
+	}
+	return &TableOptions{Description: o.Description, Labels: o.Labels}
+}
+
+// tableConstraints returns the primary and foreign key constraints of a
+// normalized table.
+func tableConstraints(dataset *bigquery.Dataset, t schema.TableSchema) *bigquery.TableConstraints {
+	constraints := &bigquery.TableConstraints{
+		PrimaryKey: &bigquery.PrimaryKey{Columns: t.PrimaryKey},
+	}
+	if t.Parent == "" {
+		return constraints
+	}
+	fk := &bigquery.ForeignKey{
+		Name:            "fk_" + t.Name + "_" + t.Parent,
+		ReferencedTable: dataset.Table(t.Parent),
+	}
+	for _, column := range t.ForeignKey {
+		fk.ColumnReferences = append(fk.ColumnReferences, &bigquery.ColumnReference{
+			ReferencingColumn: column,
+			ReferencedColumn:  column,
+		})
+	}
+	constraints.ForeignKeys = []*bigquery.ForeignKey{fk}
+	return constraints
+}
@@ -0,0 +1,64 @@
+package table
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+func TestTableConstraints(t *testing.T) {
+	dataset := &bigquery.Dataset{ProjectID: "p", DatasetID: "d"}
+
+	t.Run("root table", func(t *testing.T) {
+		c := tableConstraints(dataset, schema.TableSchema{Name: "orders", PrimaryKey: []string{"order_id"}})
+		if len(c.PrimaryKey.Columns) != 1 || c.PrimaryKey.Columns[0] != "order_id" || len(c.ForeignKeys) != 0 {
+			t.Fatalf("Expected only a primary key on order_id, but got %+v", c)
+		}
+	})
+
+	t.Run("child table", func(t *testing.T) {
+		c := tableConstraints(dataset, schema.TableSchema{
+			Name:       "orders_items",
+			PrimaryKey: []string{"order_id", "items_ordinal"},
+			Parent:     "orders",
+			ForeignKey: []string{"order_id"},
+		})
+		if len(c.ForeignKeys) != 1 {
+			t.Fatalf("Expected 1 foreign key, but got %d", len(c.ForeignKeys))
+		}
+		fk := c.ForeignKeys[0]
+		if fk.Name != "fk_orders_items_orders" || fk.ReferencedTable.TableID != "orders" ||
+			fk.ReferencedTable.DatasetID != "d" || fk.ReferencedTable.ProjectID != "p" {
+			t.Fatalf("Expected a foreign key referencing p.d.orders, but got %+v", fk)
+		}
+		if len(fk.ColumnReferences) != 1 || fk.ColumnReferences[0].ReferencingColumn != "order_id" ||
+			fk.ColumnReferences[0].ReferencedColumn != "order_id" {
+			t.Fatalf("Expected order_id to reference order_id, but got %+v", fk.ColumnReferences)
+		}
+	})
+}
+
+func TestChildTableOptions(t *testing.T) {
+	if childTableOptions(nil) != nil {
+		t.Fatalf("Expected no options for child tables without root options")
+	}
+	requireFilter, description := true, "Orders"
+	root := &TableOptions{
+		Description:            &description,
+		Labels:                 map[string]string{"team": "sales"},
+		PartitionField:         "created_at",
+		PartitionType:          "DAY",
+		RequirePartitionFilter: &requireFilter,
+		Clustering:             []string{"customer_id"},
+	}
+	child := childTableOptions(root)
+	if child.Description == nil || *child.Description != "Orders" || child.Labels["team"] != "sales" {
+		t.Fatalf("Expected the description and labels of the root table, but got %+v", child)
+	}
+	md := &bigquery.TableMetadata{}
+	child.apply(md)
+	if md.TimePartitioning != nil || md.Clustering != nil || md.RequirePartitionFilter {
+		t.Fatalf("Expected no partitioning or clustering on child tables, but got %+v", md)
+	}
+}
@@ -0,0 +1,39 @@
+package table
+
+import (
+	"context"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+)
+
+// VerifyPolicyTags fetches the live schema of datasetID.tableID and reports
+// every column of expected, e.g. converted with policy tags, whose policy
+// tags were not applied to the table as schema.DiffPolicyTagMismatch.
+// Missing columns are not reported; DetectDrift reports them.
+func VerifyPolicyTags(ctx context.Context, client *bigquery.Client, datasetID, tableID string, expected bigquery.Schema) ([]schema.SchemaDifference, error) {
+	md, err := client.Dataset(datasetID).Table(tableID).Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return schema.LostPolicyTags(expected, md.Schema), nil
+}
+
+// UpdateSchema replaces the schema of datasetID.tableID with s and reports
+// the columns whose policy tags were lost or changed by the update, as
+// schema.DiffPolicyTagMismatch. BigQuery drops the policy tags of columns
+// that are updated without them, so s should carry the tags that are
+// meant to be kept. The update fails if the table changed since its
+// schema was read.
+func UpdateSchema(ctx context.Context, client *bigquery.Client, datasetID, tableID string, s bigquery.Schema) ([]schema.SchemaDifference, error) {
+	t := client.Dataset(datasetID).Table(tableID)
+	md, err := t.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := t.Update(ctx, bigquery.TableMetadataToUpdate{Schema: s}, md.ETag)
+	if err != nil {
+		return nil, err
+	}
+	return schema.LostPolicyTags(md.Schema, updated.Schema), nil
+}
@@ -0,0 +1,140 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-syar/avro-schema-bq/schema"
+	"google.golang.org/api/option"
+)
+
+// fakeTable serves the metadata of the table p.d.t with the schema fields,
+// given in the JSON form of the API, and applies schema updates to it.
+func fakeTable(t *testing.T, fields string) (*bigquery.Client, func()) {
+	t.Helper()
+	current := json.RawMessage(fields)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/projects/p/datasets/d/tables/t") {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPatch, http.MethodPut:
+			if r.Header.Get("If-Match") != "etag-1" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			var update struct {
+				Schema struct {
+					Fields json.RawMessage `json:"fields"`
+				} `json:"schema"`
+			}
+			if err := json.Unmarshal(body, &update); err != nil {
+				t.Errorf("Invalid update request %s: %v", body, err)
+			}
+			current = update.Schema.Fields
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tableReference": map[string]string{"projectId": "p", "datasetId": "d", "tableId": "t"},
+			"etag":           "etag-1",
+			"schema":         map[string]interface{}{"fields": current},
+		})
+	}))
+	client, err := bigquery.NewClient(context.Background(), "p", option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		server.Close()
+		t.Fatalf("Error creating client: %v", err)
+	}
+	return client, func() {
+		client.Close()
+		server.Close()
+	}
+}
+
+func tagged(name string, typ bigquery.FieldType, tags ...string) *bigquery.FieldSchema {
+	f := &bigquery.FieldSchema{Name: name, Type: typ}
+	if len(tags) > 0 {
+		f.PolicyTags = &bigquery.PolicyTagList{Names: tags}
+	}
+	return f
+}
+
+func TestVerifyPolicyTags(t *testing.T) {
+	client, closeFake := fakeTable(t, `[
+		{"name": "ssn", "type": "STRING"},
+		{"name": "email", "type": "STRING", "policyTags": {"names": ["tags/2"]}},
+		{"name": "contact", "type": "RECORD", "fields": [
+			{"name": "phone", "type": "STRING", "policyTags": {"names": ["tags/4"]}},
+			{"name": "fax", "type": "STRING", "policyTags": {"names": ["tags/5"]}}
+		]}
+	]`)
+	defer closeFake()
+
+	contact := tagged("contact", bigquery.RecordFieldType)
+	contact.Schema = bigquery.Schema{
+		tagged("phone", bigquery.StringFieldType, "tags/3"),
+		tagged("fax", bigquery.StringFieldType, "tags/5"),
+	}
+	expected := bigquery.Schema{
+		tagged("ssn", bigquery.StringFieldType, "tags/1"),
+		tagged("email", bigquery.StringFieldType),
+		contact,
+		tagged("gone", bigquery.StringFieldType, "tags/6"),
+	}
+	diffs, err := VerifyPolicyTags(context.Background(), client, "d", "t", expected)
+	if err != nil {
+		t.Fatalf("Error verifying policy tags: %v", err)
+	}
+	// The extra tag of email is applied outside of the schema, and the
+	// missing column gone is left to drift detection.
+	want := []schema.SchemaDifference{
+		{Path: "ssn", Kind: schema.DiffPolicyTagMismatch, Expected: "tags/1"},
+		{Path: "contact.phone", Kind: schema.DiffPolicyTagMismatch, Expected: "tags/3", Actual: "tags/4"},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Fatalf("Expected %+v, but got %+v", want, diffs)
+	}
+}
+
+func TestUpdateSchema(t *testing.T) {
+	client, closeFake := fakeTable(t, `[
+		{"name": "ssn", "type": "STRING", "policyTags": {"names": ["tags/1"]}},
+		{"name": "contact", "type": "RECORD", "fields": [
+			{"name": "phone", "type": "STRING", "policyTags": {"names": ["tags/3"]}}
+		]}
+	]`)
+	defer closeFake()
+
+	contact := tagged("contact", bigquery.RecordFieldType)
+	contact.Schema = bigquery.Schema{
+		tagged("phone", bigquery.StringFieldType, "tags/3"),
+		tagged("fax", bigquery.StringFieldType),
+	}
+	s := bigquery.Schema{
+		tagged("ssn", bigquery.StringFieldType),
+		contact,
+		tagged("email", bigquery.StringFieldType),
+	}
+	lost, err := UpdateSchema(context.Background(), client, "d", "t", s)
+	if err != nil {
+		t.Fatalf("Error updating schema: %v", err)
+	}
+	want := []schema.SchemaDifference{
+		{Path: "ssn", Kind: schema.DiffPolicyTagMismatch, Expected: "tags/1"},
+	}
+	if !reflect.DeepEqual(lost, want) {
+		t.Fatalf("Expected the policy tag of ssn to be reported lost, but got %+v", lost)
+	}
+}